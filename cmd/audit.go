@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dollarshaveclub/psst/pkg/audit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditSinks []string
+	auditActor string
+
+	// loadedAuditSinks are built the first time an event is recorded
+	loadedAuditSinks []audit.Sink
+	// auditKey is read the first time a value is hashed or an event recorded
+	auditKey       []byte
+	auditKeyErr    error
+	auditKeyLoaded bool
+)
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditVerifyCmd)
+
+	auditVerifyCmd.Flags().StringArrayVar(&auditSinks, "sink", []string{}, "sink to verify instead of the configured ones (use multiple times for multiple sinks)")
+	auditVerifyCmd.Flags().StringVar(&auditActor, "actor", "", "whose chain to verify in a vault sink, defaults to you")
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Work with the audit trail of psst operations",
+	Long: `Every share, get, delete, forward and generate is recorded with who did it, the secret name and the
+targets, never the value itself. Each event holds the hash of the one before it, so edits show up
+when the chain is verified. With a key the hashes are HMACs and only members who can read the key can
+rebuild the chain, without one whoever can write a file can. Only a vault sink whose policy allows
+creating events but not updating them is tamper proof.
+
+Events go to ~/.psst/audit.jsonl unless the audit section of ~/.psst/config.json lists other sinks:
+file:<path>, syslog[:<tag>] or vault:<path>. With a key, the path in Vault of a secret whose key
+field holds a random string, events also record an HMAC of the value, so the same value can be
+recognized across events without being guessable by anyone reading the trail, and the chain is
+hashed with it:
+
+  {"audit": {"sinks": ["file:~/.psst/audit.jsonl", "syslog", "vault:secret/psst-audit"],
+             "key": "secret/psst-audit-key"}}`,
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that the events of the audit trail form an unbroken chain",
+	Long: `Check that the events of every configured file and vault sink form an unbroken chain. Syslog
+can't be read back and is skipped. Exits with a non-zero status if any chain is broken. The chain is
+checked with the configured key, events recorded without it show up as modified. An intact file chain
+only shows it wasn't edited by someone without the key.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		actor := auditActor
+		if actor == "" {
+			login, err := dirState.Whoami(appCtx)
+			if err != nil {
+				errorAndExit(fmt.Errorf("unable to get login name: %w", err), 1)
+			}
+			actor = login
+		}
+
+		specs := auditSinks
+		if len(specs) == 0 {
+			var err error
+			if specs, err = configuredAuditSinks(); err != nil {
+				errorAndExit(err, 1)
+			}
+		}
+		if len(specs) == 0 {
+			errorAndExit(fmt.Errorf("no audit sinks are configured"), 1)
+		}
+
+		key, err := loadAuditKey()
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+
+		broken := false
+		for _, spec := range specs {
+			sink, err := audit.Parse(spec, vaultStore(), actor, key)
+			if err != nil {
+				errorAndExit(err, 1)
+			}
+			reader, ok := sink.(audit.Reader)
+			if !ok {
+				fmt.Printf("%s: can't be read back, skipped\n", spec)
+				continue
+			}
+			events, err := reader.Events(appCtx)
+			if err == nil {
+				err = audit.Verify(events, key)
+			}
+			if err != nil {
+				fmt.Printf("%s: %v\n", spec, err)
+				broken = true
+				continue
+			}
+			fmt.Printf("%s: %d event(s), chain intact\n", spec, len(events))
+		}
+		if broken {
+			os.Exit(1)
+		}
+	},
+}
+
+// loadAuditKey returns the configured audit key, reading it the first time. It's nil when no key is
+// configured.
+func loadAuditKey() ([]byte, error) {
+	if !auditKeyLoaded {
+		auditKeyLoaded = true
+		conf, err := audit.LoadConfig(configPath)
+		if err == nil {
+			auditKey, err = audit.LoadKey(appCtx, conf, vaultStore())
+		}
+		auditKeyErr = err
+	}
+	return auditKey, auditKeyErr
+}
+
+// hashValue returns the hash of a secret value to record in the audit trail, or nothing when no audit
+// key is configured. A key that can't be read is reported when the event is recorded.
+func hashValue(value string) string {
+	key, _ := loadAuditKey()
+	return audit.HashValue(key, value)
+}
+
+// configuredAuditSinks returns the sink specs of the config, or the local audit file when the config
+// doesn't list any
+func configuredAuditSinks() ([]string, error) {
+	conf, err := audit.LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Sinks == nil {
+		return []string{"file:" + auditPath}, nil
+	}
+	return conf.Sinks, nil
+}
+
+// vaultStore returns the Vault client as an audit store, or nil when storage isn't in Vault
+func vaultStore() audit.Store {
+	if vaultClient == nil {
+		return nil
+	}
+	return vaultClient
+}
+
+// recordAudit records e in every configured sink. The operation already happened, so failures are
+// only reported.
+func recordAudit(e audit.Event) {
+	if err := auditEvent(e); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to record audit event: %v\n", err)
+	}
+}
+
+// auditEvent records e in every configured sink, returning the first failure after trying them all
+func auditEvent(e audit.Event) error {
+	var failed error
+	if loadedAuditSinks == nil {
+		specs, err := configuredAuditSinks()
+		if err != nil {
+			return err
+		}
+		// Without the key events are still recorded, unkeyed, and verifying the chain points them out
+		key, err := loadAuditKey()
+		if err != nil {
+			failed = err
+		}
+		loadedAuditSinks = []audit.Sink{}
+		for _, spec := range specs {
+			sink, err := audit.Parse(spec, vaultStore(), e.Actor, key)
+			if err != nil {
+				failed = err
+				continue
+			}
+			loadedAuditSinks = append(loadedAuditSinks, sink)
+		}
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Host == "" {
+		e.Host, _ = os.Hostname()
+	}
+	for _, sink := range loadedAuditSinks {
+		if err := sink.Record(appCtx, e); err != nil && failed == nil {
+			failed = err
+		}
+	}
+	return failed
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dollarshaveclub/psst/pkg/directory"
+	"github.com/dollarshaveclub/psst/pkg/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	completeCmdName = "__complete"
+
+	completeMembers = "members"
+	completeTeams   = "teams"
+	completeSecrets = "secrets"
+)
+
+var (
+	// flagCompletions lists the flags, by command, whose values are completed from the directory cache
+	flagCompletions = map[string]map[string]string{
+		"share":  {"member": completeMembers, "team": completeTeams},
+		"get":    {"team": completeTeams},
+		"delete": {"team": completeTeams},
+	}
+
+	// argCompletions lists the commands whose arguments are secret names in a drop
+	argCompletions = map[string]string{
+		"get":    completeSecrets,
+		"delete": completeSecrets,
+	}
+
+	completionScripts = map[string]string{
+		"bash": `# bash completion for psst
+_psst() {
+    local IFS=$'\n'
+    COMPREPLY=( $(psst __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null) )
+}
+complete -o default -F _psst psst
+`,
+		"zsh": `#compdef psst
+# zsh completion for psst
+_psst() {
+    local -a completions
+    completions=(${(f)"$(psst __complete "${(@)words[2,$CURRENT]}" 2>/dev/null)"})
+    compadd -a completions
+}
+compdef _psst psst
+`,
+		"fish": `# fish completion for psst
+function __psst_complete
+    set -l args (commandline -opc)
+    set -e args[1]
+    psst __complete $args (commandline -ct) 2>/dev/null
+end
+complete -c psst -f -a '(__psst_complete)'
+`,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(completeCmd)
+}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish]",
+	Short: "Output a shell completion script",
+	Long: `Output a shell completion script. Secret names, members and teams are completed from the
+local cache so completion never waits on the network. Names inside folders such as prod/db are
+completed once psst list --recursive has walked them. Load it with:
+
+  bash: source <(psst completion bash)
+  zsh:  source <(psst completion zsh)
+  fish: psst completion fish | source`,
+	Args: cobra.ExactArgs(1),
+	// Generating the script doesn't need the directory or storage backend
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		script, ok := completionScripts[args[0]]
+		if !ok {
+			errorAndExit(fmt.Errorf("unsupported shell '%s', use one of bash, zsh or fish", args[0]), 1)
+		}
+		fmt.Print(script)
+	},
+}
+
+// completeCmd is called by the completion scripts with the words on the command line, the last
+// being the word to complete. It prints one candidate per line.
+var completeCmd = &cobra.Command{
+	Use:                completeCmdName,
+	Hidden:             true,
+	DisableFlagParsing: true,
+	PersistentPreRun:   func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		// Flag parsing is disabled so the global flags that change where the cache points are read by hand
+		if o := flagValue(args, "", "org"); o != "" {
+			Org = o
+		}
+		if d := flagValue(args, "", "directory-backend"); d != "" {
+			directoryBackend = d
+		}
+
+		for _, c := range complete(args) {
+			fmt.Println(c)
+		}
+	},
+}
+
+// complete returns the candidates for the last word in args
+func complete(args []string) []string {
+	toComplete := ""
+	if len(args) > 0 {
+		toComplete = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+
+	c, rest, err := rootCmd.Find(args)
+	if err != nil {
+		c, rest = rootCmd, args
+	}
+
+	candidates := []string{}
+	switch {
+	case len(rest) > 0 && flagNeedsValue(c, rest[len(rest)-1]):
+		candidates = completeValues(flagCompletions[c.Name()][flagName(c, rest[len(rest)-1])], rest)
+	case strings.HasPrefix(toComplete, "-"):
+		candidates = flagNames(c)
+	case c == rootCmd:
+		for _, sub := range rootCmd.Commands() {
+			if !sub.Hidden {
+				candidates = append(candidates, sub.Name())
+			}
+		}
+	default:
+		candidates = completeValues(argCompletions[c.Name()], rest)
+	}
+
+	matches := []string{}
+	for _, cand := range candidates {
+		if strings.HasPrefix(cand, toComplete) {
+			matches = append(matches, cand)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// completeValues returns the candidates of a given kind using only on-disk caches
+func completeValues(kind string, args []string) []string {
+	if kind == "" {
+		return []string{}
+	}
+	dir, err := cachedDirectory()
+	if err != nil {
+		return []string{}
+	}
+
+	values := []string{}
+	switch kind {
+	case completeMembers:
+		for _, m := range dir.GetMembers() {
+			values = append(values, m.Login)
+		}
+	case completeTeams:
+		for _, t := range dir.GetTeams() {
+			values = append(values, t.Name)
+		}
+	case completeSecrets:
+		entity := flagValue(args, "t", "team")
+		if entity == "" {
+			if entity, err = dir.Whoami(appCtx); err != nil {
+				return []string{}
+			}
+		}
+		values, _ = storage.CachedList(secretsCacheDir, entity)
+	}
+	return values
+}
+
+func cachedDirectory() (directory.Backend, error) {
+	switch directoryBackend {
+	case "github":
+		return directory.NewGitHubFromCache(Org)
+	}
+	return nil, errors.New("you must provide a valid directory backend")
+}
+
+// flagNeedsValue checks if word is a flag expecting its value as the next word
+func flagNeedsValue(c *cobra.Command, word string) bool {
+	if !strings.HasPrefix(word, "-") || strings.Contains(word, "=") {
+		return false
+	}
+	f := lookupFlag(c, word)
+	return f != nil && f.NoOptDefVal == ""
+}
+
+func flagName(c *cobra.Command, word string) string {
+	if f := lookupFlag(c, word); f != nil {
+		return f.Name
+	}
+	return ""
+}
+
+func lookupFlag(c *cobra.Command, word string) *pflag.Flag {
+	if strings.HasPrefix(word, "--") {
+		return c.Flags().Lookup(strings.TrimPrefix(word, "--"))
+	}
+	return c.Flags().ShorthandLookup(strings.TrimPrefix(word, "-"))
+}
+
+func flagNames(c *cobra.Command) []string {
+	names := []string{}
+	seen := make(map[string]struct{})
+	add := func(f *pflag.Flag) {
+		if _, ok := seen[f.Name]; ok || f.Hidden {
+			return
+		}
+		seen[f.Name] = struct{}{}
+		names = append(names, "--"+f.Name)
+		if f.Shorthand != "" {
+			names = append(names, "-"+f.Shorthand)
+		}
+	}
+	c.Flags().VisitAll(add)
+	c.InheritedFlags().VisitAll(add)
+	return names
+}
+
+// flagValue finds the value of a flag in a list of words without parsing them
+func flagValue(args []string, short, long string) string {
+	for i, a := range args {
+		switch {
+		case ((short != "" && a == "-"+short) || a == "--"+long) && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(a, "--"+long+"="):
+			return strings.TrimPrefix(a, "--"+long+"=")
+		}
+	}
+	return ""
+}
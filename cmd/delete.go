@@ -1,43 +1,163 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/dollarshaveclub/psst/pkg/audit"
+	"github.com/dollarshaveclub/psst/pkg/bulk"
+	"github.com/dollarshaveclub/psst/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
+var (
+	deleteAll bool
+	olderThan string
+	yes       bool
+)
+
 func init() {
 	rootCmd.AddCommand(deleteCmd)
 
-	deleteCmd.Flags().StringVarP(&team, "team", "t", "", "the team currently owning the secret")
+	deleteCmd.Flags().StringVarP(&team, "team", "t", "", "the team currently owning the secret, or a pattern such as '*' for every team you are on")
+	deleteCmd.Flags().BoolVar(&deleteAll, "all", false, "delete every secret")
+	deleteCmd.Flags().StringVar(&olderThan, "older-than", "", "only delete secrets shared more than this long ago (e.g. 12h or 30d)")
+	deleteCmd.Flags().BoolVarP(&yes, "yes", "y", false, "don't ask for confirmation")
 }
 
 var deleteCmd = &cobra.Command{
-	Use:   "delete",
+	Use:   "delete [name|pattern]...",
 	Short: "Delete a secret from the current user's drop location",
-	Long:  `Delete a secret from the current user's drop location`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Delete a secret from the current user's drop location. Several names, glob patterns such as
+'tmp-*', --all and --older-than delete many secrets at once after showing what will be removed.
+Secrets shared before psst recorded when they were created are never matched by --older-than.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if deleteAll && len(args) > 0 {
+			errorAndExit(fmt.Errorf("--all can't be used with names or patterns"), 1)
+		}
+		if olderThan != "" {
+			if _, err := parseDuration(olderThan); err != nil {
+				errorAndExit(fmt.Errorf("invalid --older-than: %v", err), 1)
+			}
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		login, err := dirState.Whoami()
+		login, err := dirState.Whoami(appCtx)
 		if err != nil {
-			errorAndExit(fmt.Errorf("unable to get login name: %+v", err), 1)
+			errorAndExit(fmt.Errorf("unable to get login name: %w", err), 1)
 		}
 
-		// cobra.ExactArgs(1) makes sure we have a single argument
-		name := args[0]
-
-		entity := login
+		entities := []string{login}
 		if team != "" {
-			var ok bool
-			entity, ok = dirState.IsTeam(team)
-			if !ok {
-				errorAndExit(fmt.Errorf("unable to find team '%s'", team), 1)
+			if bulk.IsPattern(team) {
+				entities = bulk.MatchEntities(team, dirState.GetActiveMemberTeams())
+				if len(entities) == 0 {
+					errorAndExit(fmt.Errorf("no teams match '%s'", team), 1)
+				}
+			} else {
+				entity, ok := dirState.IsTeam(team)
+				if !ok {
+					errorAndExit(fmt.Errorf("unable to find team '%s'", team), 1)
+				}
+				entities = []string{entity}
+			}
+			for _, e := range entities {
+				requireScope(e, "delete")
 			}
 		}
 
-		path := storageClient.SecretPath(entity, name)
-		if err := storageClient.Delete(path); err != nil {
+		// A single name keeps the original behavior of deleting without asking
+		if len(args) == 1 && !bulk.IsPattern(args[0]) && !deleteAll && olderThan == "" && len(entities) == 1 {
+			path := storageClient.SecretPath(entities[0], args[0])
+			if err := storageClient.Delete(appCtx, path); err != nil {
+				errorAndExit(err, 1)
+			}
+			recordAudit(audit.Event{Actor: login, Action: audit.Delete, Secret: args[0], Targets: []string{entities[0]}})
+			return
+		}
+
+		filter := bulk.Filter{Patterns: args, All: deleteAll || (len(args) == 0 && olderThan != ""), Now: time.Now()}
+		if olderThan != "" {
+			filter.OlderThan, _ = parseDuration(olderThan)
+		}
+		if err := filter.Validate(); err != nil {
+			errorAndExit(err, 1)
+		}
+
+		matched, err := selectSecrets(entities, filter)
+		if err != nil {
 			errorAndExit(err, 1)
 		}
+		if len(matched) == 0 {
+			fmt.Println("No secrets matched")
+			return
+		}
+
+		fmt.Println("The following secrets will be deleted:")
+		for _, s := range matched {
+			fmt.Printf("  %s/%s\n", s.Entity, s.Name)
+		}
+		if !yes && !confirm(fmt.Sprintf("Delete %d secret(s)?", len(matched))) {
+			fmt.Println("Nothing was deleted")
+			return
+		}
+
+		failed := 0
+		for _, s := range matched {
+			if err := storageClient.Delete(appCtx, storageClient.SecretPath(s.Entity, s.Name)); err != nil {
+				fmt.Fprintf(os.Stderr, "%s/%s: %v\n", s.Entity, s.Name, err)
+				failed++
+				continue
+			}
+			recordAudit(audit.Event{Actor: login, Action: audit.Delete, Secret: s.Name, Targets: []string{s.Entity}})
+		}
+		fmt.Printf("Deleted %d of %d secret(s)\n", len(matched)-failed, len(matched))
+		if failed > 0 {
+			os.Exit(1)
+		}
 	},
 }
+
+// selectSecrets lists the drops of entities and returns the secrets matching filter. Metadata is only
+// read when filtering by age.
+func selectSecrets(entities []string, filter bulk.Filter) ([]bulk.Secret, error) {
+	matched := []bulk.Secret{}
+	for _, e := range entities {
+		names, err := storage.Walk(appCtx, storageClient, e)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			if !filter.MatchName(n) {
+				continue
+			}
+			s := bulk.Secret{Entity: e, Name: n}
+			if filter.OlderThan > 0 {
+				secret, err := storageClient.GetSecret(appCtx, storageClient.SecretPath(e, n))
+				if err != nil {
+					return nil, err
+				}
+				s.Created = secret.Created
+			}
+			if filter.Match(s) {
+				matched = append(matched, s)
+			}
+		}
+	}
+	bulk.Sort(matched)
+	return matched, nil
+}
+
+// confirm asks a yes or no question on the terminal, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
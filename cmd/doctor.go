@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dollarshaveclub/psst/pkg/directory"
+	"github.com/dollarshaveclub/psst/pkg/doctor"
+	"github.com/dollarshaveclub/psst/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// otherDropCheck is a made up drop used to check that secrets can be written to other users
+	otherDropCheck = "psst-doctor-other"
+)
+
+var (
+	// requiredGitHubScopes lists the scopes needed to read members and teams, any one scope per group
+	requiredGitHubScopes = [][]string{{"read:org", "write:org", "admin:org"}}
+
+	ownDropCapabilities = []string{"read", "list", "delete"}
+	// Team scopes can limit members to reading or writing, but everyone can share into a team drop
+	teamDropCapabilities  = []string{"create", "update"}
+	otherDropCapabilities = []string{"create", "update"}
+)
+
+// capabilityCheck is a drop and the capabilities the user is expected to have on it
+type capabilityCheck struct {
+	name   string
+	entity string
+	want   []string
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check your psst setup for common problems",
+	Long: `Check your psst setup for common problems. Every check reports PASS, WARN or FAIL along with
+a hint on how to fix anything that isn't passing. Exits with a non-zero status if any check fails.`,
+	// The checks build their own clients so a broken setup can still be diagnosed
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		report := doctor.Report{}
+		report = append(report, checkCache()...)
+
+		dir, dirResults := checkDirectory()
+		report = append(report, dirResults...)
+		report = append(report, checkStorage(dir)...)
+
+		report.Write(os.Stdout)
+		if report.Failed() {
+			os.Exit(1)
+		}
+	},
+}
+
+// checkCache must run before the directory is loaded since loading refreshes a stale cache
+func checkCache() doctor.Report {
+	report := doctor.Report{}
+	paths, ttl := directory.CacheFiles()
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		report = append(report, doctor.CacheFile(p, info, err, ttl, time.Now()))
+	}
+	return report
+}
+
+func checkDirectory() (*directory.GH, doctor.Report) {
+	report := doctor.Report{}
+	if directoryBackend != "github" {
+		return nil, append(report, doctor.Failed("directory backend", "use --directory-backend github",
+			"unknown directory backend '%s'", directoryBackend))
+	}
+
+	if os.Getenv("GITHUB_TOKEN") == "" {
+		return nil, append(report, doctor.Failed("GITHUB_TOKEN", "create a token at https://github.com/settings/tokens and export GITHUB_TOKEN",
+			"GITHUB_TOKEN is not set"))
+	}
+	report = append(report, doctor.Passed("GITHUB_TOKEN", "set"))
+
+	gh, err := directory.NewGitHub(appCtx, Org, updateCache)
+	if err != nil {
+		return nil, append(report, doctor.Failed("GitHub directory", "check your network connection and that --org is correct",
+			"unable to load members and teams: %v", err))
+	}
+
+	scopes, date, err := gh.TokenInfo(appCtx)
+	if err != nil {
+		return nil, append(report, doctor.Failed("GitHub token", "check that GITHUB_TOKEN is valid and has not been revoked", "%v", err))
+	}
+	report = append(report, doctor.Scopes(scopes, requiredGitHubScopes))
+	report = append(report, doctor.ClockSkew("GitHub", time.Now(), date))
+
+	login, err := gh.Whoami(appCtx)
+	if err != nil {
+		return nil, append(report, doctor.Failed("GitHub login", "check that GITHUB_TOKEN belongs to your user", "%v", err))
+	}
+	if _, ok := gh.IsMember(login); !ok {
+		return nil, append(report, doctor.Failed("organization membership",
+			"ask an organization owner to add you, or run with --update-cache if you just joined",
+			"%s is not a member of %s", login, Org))
+	}
+	report = append(report, doctor.Passed("organization membership", "%s is a member of %s", login, Org))
+
+	return gh, report
+}
+
+func checkStorage(dir *directory.GH) doctor.Report {
+	report := doctor.Report{}
+	if storageBackend != "vault" {
+		return append(report, doctor.Failed("storage backend", "use --storage-backend vault",
+			"unknown storage backend '%s'", storageBackend))
+	}
+
+	conf, err := storage.LoadVaultConfig(configPath)
+	if err != nil {
+		return append(report, doctor.Failed("Vault config", "fix the vault section of "+configPath, "%v", err))
+	}
+	report = append(report, doctor.Passed("Vault config", "%s", describeVaultConfig(conf)))
+
+	loginHint := "log in to Vault with: psst login"
+	v, err := storage.NewVault(conf)
+	if err != nil {
+		return append(report, doctor.Failed("Vault token", loginHint, "%v", err))
+	}
+
+	health, err := v.Sys().Health()
+	if err != nil {
+		hint := "check VAULT_ADDR and your VPN connection"
+		if strings.Contains(err.Error(), "x509") || strings.Contains(err.Error(), "tls") {
+			hint = "check the tls section of " + configPath + " or VAULT_CACERT and VAULT_TLS_SERVER_NAME"
+		}
+		return append(report, doctor.Failed("Vault reachability", hint, "unable to reach %s: %v", v.Address(), err))
+	}
+	if health.Sealed {
+		return append(report, doctor.Failed("Vault reachability", "contact your Vault administrators", "%s is sealed", v.Address()))
+	}
+	report = append(report, doctor.Passed("Vault reachability", "%s is up (version %s)", v.Address(), health.Version))
+	report = append(report, doctor.ClockSkew("Vault", time.Now(), time.Unix(health.ServerTimeUTC, 0)))
+
+	self, err := v.Auth().Token().LookupSelf()
+	if err != nil {
+		return append(report, doctor.Failed("Vault token", loginHint, "token is invalid or expired: %v", err))
+	}
+	ttl, err := self.TokenTTL()
+	if err != nil {
+		return append(report, doctor.Failed("Vault token", loginHint, "unable to read token TTL: %v", err))
+	}
+	renewable, _ := self.TokenIsRenewable()
+	report = append(report, doctor.TokenTTL(ttl, renewable))
+
+	// Capabilities depend on knowing who the user is and which teams they are on
+	if dir == nil {
+		return report
+	}
+	login, err := dir.Whoami(appCtx)
+	if err != nil {
+		return report
+	}
+
+	checks := []capabilityCheck{
+		{"capabilities on your drop", login, ownDropCapabilities},
+		{"capabilities on other drops", otherDropCheck, otherDropCapabilities},
+	}
+	for _, t := range dir.GetActiveMemberTeams() {
+		checks = append(checks, capabilityCheck{"capabilities on team " + t, t, teamDropCapabilities})
+	}
+
+	for _, c := range checks {
+		caps, err := v.DropCapabilities(appCtx, c.entity)
+		if err != nil {
+			report = append(report, doctor.Failed(c.name, "check your Vault token and connection", "%v", err))
+			continue
+		}
+		report = append(report, doctor.Capabilities(c.name, caps, c.want))
+	}
+	return report
+}
+
+// describeVaultConfig summarizes the settings of the Vault config that differ from the defaults
+func describeVaultConfig(conf storage.VaultConfig) string {
+	settings := []string{}
+	if conf.Address != "" {
+		settings = append(settings, "address "+conf.Address)
+	}
+	if conf.Namespace != "" {
+		settings = append(settings, "namespace "+conf.Namespace)
+	}
+	if conf.TLS.CACert != "" || conf.TLS.CAPath != "" {
+		settings = append(settings, "custom CA")
+	}
+	if conf.TLS.ClientCert != "" {
+		settings = append(settings, "client certificate")
+	}
+	if conf.TLS.ServerName != "" {
+		settings = append(settings, "server name "+conf.TLS.ServerName)
+	}
+	if conf.Timeout != "" {
+		settings = append(settings, "timeout "+conf.Timeout)
+	}
+	if conf.MaxRetries != nil {
+		settings = append(settings, fmt.Sprintf("%d retries", *conf.MaxRetries))
+	}
+	method := conf.Auth.Method
+	if method == "" {
+		method = storage.AuthGitHub
+	}
+	settings = append(settings, method+" login")
+	return strings.Join(settings, ", ")
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dollarshaveclub/psst/pkg/directory"
+	"github.com/dollarshaveclub/psst/pkg/storage"
+)
+
+const (
+	// exitDrift is used by policy diff when the policies don't match the directory
+	exitDrift       = 2
+	exitNotFound    = 3
+	exitForbidden   = 4
+	exitUnusable    = 5
+	exitUnavailable = 6
+	exitInterrupted = 130
+
+	// cancelGracePeriod is how long commands get to stop after Ctrl-C before psst exits anyway
+	cancelGracePeriod = 2 * time.Second
+)
+
+// exitCode returns the exit code documented for err, or 1 for errors without one
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.Is(err, storage.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, storage.ErrForbidden), errors.Is(err, directory.ErrForbidden):
+		return exitForbidden
+	case errors.Is(err, storage.ErrExpired), errors.Is(err, storage.ErrRevoked):
+		return exitUnusable
+	case errors.Is(err, storage.ErrUnavailable), errors.Is(err, directory.ErrUnavailable):
+		return exitUnavailable
+	}
+	return 1
+}
+
+// errorHint suggests what to do about err, or returns an empty string
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return "Run psst list to see the secrets available to you"
+	case errors.Is(err, storage.ErrForbidden):
+		return "Your Vault token may have expired, log in again with: psst login"
+	case errors.Is(err, directory.ErrForbidden):
+		return "Check that GITHUB_TOKEN is valid and has the read:org scope"
+	case errors.Is(err, storage.ErrExpired), errors.Is(err, storage.ErrRevoked):
+		return "Ask the sender to share it again"
+	case errors.Is(err, storage.ErrUnavailable), errors.Is(err, directory.ErrUnavailable):
+		return "Check your network connection or run psst doctor"
+	}
+	return ""
+}
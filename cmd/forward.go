@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dollarshaveclub/psst/pkg/audit"
+	"github.com/dollarshaveclub/psst/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fromTeam string
+	move     bool
+)
+
+func init() {
+	rootCmd.AddCommand(forwardCmd)
+
+	forwardCmd.Flags().StringVar(&fromTeam, "from-team", "", "the team currently owning the secret")
+	forwardCmd.Flags().StringArrayVarP(&members, "member", "m", []string{}, "members to forward the secret to (use multiple times for multiple members)")
+	forwardCmd.Flags().StringArrayVarP(&teams, "team", "t", []string{}, "teams to forward the secret to (use multiple times for multiple teams)")
+	forwardCmd.Flags().StringVarP(&name, "name", "n", "", "name to forward the secret as (defaults to the current name)")
+	forwardCmd.Flags().BoolVar(&bestEffort, "best-effort", false, "keep the secret with the targets that succeeded instead of rolling back when some fail")
+	forwardCmd.Flags().BoolVar(&move, "move", false, "delete the secret from the current drop once forwarded")
+}
+
+var forwardCmd = &cobra.Command{
+	Use:   "forward",
+	Short: "Forward a secret from your drop to other members or teams",
+	Long: `Forward a secret from your drop, or a team's drop with --from-team, to other members or teams
+without writing it to disk. The original sender and everyone who forwarded the secret are recorded
+with it, and its expiration is kept.`,
+	Args: cobra.ExactArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if len(members) == 0 && len(teams) == 0 {
+			errorAndExit(fmt.Errorf("you must provide either members and/or teams"), 1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		login, err := dirState.Whoami(appCtx)
+		if err != nil {
+			errorAndExit(fmt.Errorf("unable to get login name: %w", err), 1)
+		}
+
+		// cobra.ExactArgs(1) makes sure we have a single argument
+		source := args[0]
+		if name == "" {
+			name = source
+		}
+		if err := storage.ValidateName(name); err != nil {
+			errorAndExit(err, 1)
+		}
+
+		entity := login
+		if fromTeam != "" {
+			var ok bool
+			entity, ok = dirState.IsTeam(fromTeam)
+			if !ok {
+				errorAndExit(fmt.Errorf("could not find team '%s'", fromTeam), 1)
+			}
+			requireScope(entity, "read")
+			// Checked before sharing so a move the scope doesn't allow doesn't leave a copy behind
+			if move {
+				requireScope(entity, "delete")
+			}
+		}
+
+		targets, err := targets(dirState, members, teams)
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+		requireTeamScopes(teams)
+		if _, ok := targets[entity]; ok && name == source {
+			errorAndExit(fmt.Errorf("%s already has %s", entity, source), 1)
+		}
+
+		secret := readSecret(entity, source)
+		meta := secret.Forward(login, time.Now())
+		if secret.Sender != "" && secret.Sender != entity {
+			sendReceipt(secret, entity, source, login)
+		}
+		shareSecret(audit.Event{Action: audit.Forward, Detail: "from " + entity + "/" + source}, secret.Value, name, meta, []string{}, targets)
+
+		if move {
+			if err := storageClient.Delete(appCtx, storageClient.SecretPath(entity, source)); err != nil {
+				errorAndExit(fmt.Errorf("forwarded but unable to delete %s: %v", source, err), 1)
+			}
+			recordAudit(audit.Event{Actor: login, Action: audit.Delete, Secret: source, Targets: []string{entity}, ValueHash: hashValue(secret.Value), Detail: "moved"})
+			fmt.Printf("Deleted %s from %s\n", source, entity)
+		}
+	},
+}
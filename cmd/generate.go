@@ -2,16 +2,34 @@ package cmd
 
 import (
 	"fmt"
-	"path"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
+	"github.com/dollarshaveclub/psst/pkg/audit"
+	"github.com/dollarshaveclub/psst/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
+// terraformFile is written to --policy-dir with --format terraform
+const terraformFile = "psst.tf.json"
+
 var (
 	policyDir   string
 	roleDir     string
 	allTeam     string
 	defaultTeam = "all"
+
+	apply      bool
+	dryRun     bool
+	authPath   string
+	prune      bool
+	pruneDrops string
+	templates  string
+	format     string
+	teamScopes string
 )
 
 func init() {
@@ -19,27 +37,300 @@ func init() {
 	generateCmd.Flags().StringVar(&policyDir, "policy-dir", "", "directory for the generated policy files")
 	generateCmd.Flags().StringVar(&roleDir, "role-dir", "", "directory for the generated roles")
 	generateCmd.Flags().StringVar(&allTeam, "default-team", defaultTeam, "team containing every member of your organization")
+	generateCmd.Flags().BoolVar(&apply, "apply", false, "write the policies and GitHub mappings to Vault")
+	generateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what --apply would change in Vault without changing it")
+	generateCmd.Flags().StringVar(&templates, "templates", "", "directory with general.hcl.tmpl, member.hcl.tmpl and team.hcl.tmpl policy templates")
+	generateCmd.Flags().StringVar(&teamScopes, "team-scopes", "", "JSON file with the capabilities members and maintainers of each team have on the team drop")
+	generateCmd.Flags().StringVar(&format, "format", "files", "output format: files, or terraform for a Terraform JSON configuration")
+	generateCmd.Flags().StringVar(&authPath, "auth-path", storage.DefaultAuthPath, "path the GitHub auth method is mounted at in Vault")
+	generateCmd.Flags().BoolVar(&prune, "prune", false, "remove policies and role entries of members and teams that left the organization")
+	generateCmd.Flags().StringVar(&pruneDrops, "prune-drops", "", "also empty the drops of members and teams that left: archive or wipe")
+	generateCmd.Flags().BoolVarP(&yes, "yes", "y", false, "don't ask for confirmation before pruning")
 }
 
 var generateCmd = &cobra.Command{
 	Use:   "generate",
 	Short: "Generate polices missing for new users in GitHub",
-	Long:  `Generate polices missing for new users in GitHub`,
-	Run: func(cmd *cobra.Command, args []string) {
-		entities := []string{}
-		for _, m := range dirState.GetMembers() {
-			entities = append(entities, m.Login)
+	Long: `Generate polices missing for new users in GitHub. With --apply the policies are written to Vault
+through sys/policies/acl and every member and team is mapped to their policies in the GitHub auth
+method, which needs a token allowed to manage both. Policies mapped by hand are kept. Use --dry-run
+to see the changes first. Files are only written alongside --apply when --policy-dir or --role-dir
+is given.
+
+With --prune, policies and role entries of members and teams that left the organization are
+removed from the files and, with --apply, from Vault. Only policies starting with the "# Generated by
+psst" line psst writes are pruned, policies written by hand are kept even when named psst-*.
+--prune-drops archive moves the secrets left in their drops to secret/psst-archive, which only Vault
+administrators can read, --prune-drops wipe deletes them. A report is shown before anything is
+removed.
+
+--templates replaces the built in policy templates with general.hcl.tmpl, member.hcl.tmpl and
+team.hcl.tmpl from a directory, a missing file keeps the built in template. Templates are Go text
+templates given .Path (the drop), .Mount (the secrets engine), .Entity (the member or team), .Login
+(members only) and .Members (the team, or every member for the general policy, which shouldn't
+grant anything on team drops). Every rendered policy must be valid HCL before anything is written.
+
+--team-scopes limits what members of a team may do in the team drop, for example letting members
+read while only maintainers can delete:
+
+  {"sre": {"members": ["read", "list"], "maintainers": ["create", "update", "read", "list", "delete"]},
+   "*": {"members": ["create", "update", "read", "list", "delete"]}}
+
+"*" applies to teams without their own entry, teams without any entry keep full access. When
+maintainers get different capabilities, a psst-<team>-maintainers policy is mapped to each of them.
+--apply takes it away from users who are no longer maintainers, --prune does so in the role files too.
+
+--format terraform writes the policies and GitHub mappings as vault_policy, vault_github_user and
+vault_github_team resources to psst.tf.json in --policy-dir, or to stdout without it, instead of
+policy and role files. Resources are named after the policy, user or team they manage so re-running
+after the organization changes only adds or removes their resources.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if pruneDrops != "" && pruneDrops != "archive" && pruneDrops != "wipe" {
+			errorAndExit(fmt.Errorf("--prune-drops must be archive or wipe"), 1)
+		}
+		if pruneDrops != "" && !prune {
+			errorAndExit(fmt.Errorf("--prune-drops can only be used with --prune"), 1)
+		}
+		if format != "files" && format != "terraform" {
+			errorAndExit(fmt.Errorf("--format must be files or terraform"), 1)
+		}
+		if format == "terraform" && (apply || dryRun || prune) {
+			errorAndExit(fmt.Errorf("--format terraform leaves applying and pruning to Terraform, it can't be used with --apply, --dry-run or --prune"), 1)
 		}
-		if err := storageClient.GeneratePoliciesAndRoles(directoryBackend, path.Join(roleDir, "users"), policyDir, allTeam, entities); err != nil {
-			errorAndExit(fmt.Errorf("unable to generate policies and roles: %v", err), 1)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		entities, set := renderPolicies()
+		if format == "terraform" {
+			writeTerraform(set)
+			return
 		}
 
-		entities = []string{}
-		for _, t := range dirState.GetTeams() {
-			entities = append(entities, t.Name)
+		files := (!apply && !dryRun) || policyDir != "" || roleDir != ""
+		if files {
+			if err := storageClient.GeneratePoliciesAndRoles(set, roleDir, policyDir); err != nil {
+				errorAndExit(fmt.Errorf("unable to generate policies and roles: %v", err), 1)
+			}
+			recordGenerate("wrote policy and role files", policyNames(set))
+		}
+		if apply || dryRun {
+			applyPolicies(set)
 		}
-		if err := storageClient.GeneratePoliciesAndRoles(directoryBackend, path.Join(roleDir, "teams"), policyDir, allTeam, entities); err != nil {
-			errorAndExit(fmt.Errorf("unable to generate policies and roles: %v", err), 1)
+		if prune {
+			prunePolicies(entities, set, files)
 		}
 	},
 }
+
+// renderPolicies renders the policies for the members and teams of the directory with the templates
+// of the directory backend or from --templates. The members and teams with a drop are returned with
+// the policies.
+func renderPolicies() ([]string, storage.PolicySet) {
+	tmpl, err := storage.Templates(directoryBackend)
+	if err != nil {
+		errorAndExit(err, 1)
+	}
+	if templates != "" {
+		if tmpl, err = storage.LoadTemplates(templates, tmpl); err != nil {
+			errorAndExit(err, 1)
+		}
+	}
+
+	members := []string{}
+	for _, m := range dirState.GetMembers() {
+		members = append(members, m.Login)
+	}
+	scopes := map[string]storage.TeamScope{}
+	if teamScopes != "" {
+		if scopes, err = storage.LoadTeamScopes(teamScopes); err != nil {
+			errorAndExit(err, 1)
+		}
+	}
+
+	entities := append([]string{}, members...)
+	teams := make(map[string]storage.Team)
+	for _, t := range dirState.GetTeams() {
+		teams[t.Name] = storage.Team{Members: t.Members, Maintainers: t.Maintainers, Scope: storage.ScopeFor(scopes, t.Name)}
+		entities = append(entities, t.Name)
+	}
+	set, err := storage.RenderPolicies(tmpl, allTeam, members, teams)
+	if err != nil {
+		errorAndExit(err, 1)
+	}
+	return entities, set
+}
+
+// writeTerraform writes set as a Terraform configuration to --policy-dir or stdout
+func writeTerraform(set storage.PolicySet) {
+	b, err := storage.TerraformConfig(set, authPath)
+	if err != nil {
+		errorAndExit(err, 1)
+	}
+	if policyDir == "" {
+		os.Stdout.Write(b)
+		return
+	}
+	if err := os.MkdirAll(policyDir, 0700); err != nil {
+		errorAndExit(fmt.Errorf("unable to create policy directory: %w", err), 1)
+	}
+	p := filepath.Join(policyDir, terraformFile)
+	if err := ioutil.WriteFile(p, b, 0644); err != nil {
+		errorAndExit(fmt.Errorf("unable to write %s: %w", p, err), 1)
+	}
+	fmt.Printf("Wrote %s\n", p)
+}
+
+// applyPolicies shows the changes needed in Vault and makes them unless this is a dry run
+func applyPolicies(set storage.PolicySet) {
+	changes, err := storageClient.PlanPolicies(appCtx, set, authPath)
+	if err != nil {
+		errorAndExit(err, 1)
+	}
+	if len(changes) == 0 {
+		fmt.Println("Vault is up to date")
+		return
+	}
+
+	printChanges(changes)
+	if dryRun {
+		fmt.Printf("\n%d change(s) would be applied, run with --apply to make them\n", len(changes))
+		return
+	}
+	if err := storageClient.ApplyPolicies(appCtx, changes, authPath); err != nil {
+		errorAndExit(err, 1)
+	}
+	recordGenerate("applied to Vault", changeNames(changes))
+	fmt.Printf("\nApplied %d change(s)\n", len(changes))
+}
+
+func printChanges(changes []storage.Change) {
+	for _, c := range changes {
+		switch c.Kind {
+		case storage.PolicyChange:
+			fmt.Printf("%s policy %s\n", c.Action(), c.Name)
+			for _, l := range storage.DiffLines(c.Old, c.New) {
+				fmt.Printf("    %s\n", l)
+			}
+		default:
+			old := c.Old
+			if old == "" {
+				old = "(none)"
+			}
+			fmt.Printf("%s %s %s: %s -> %s\n", c.Action(), c.Kind, c.Name, old, c.New)
+		}
+	}
+}
+
+// prunePolicies reports the policies, role entries and drops left behind by members and teams that
+// left the organization and removes them once confirmed
+func prunePolicies(entities []string, set storage.PolicySet, files bool) {
+	// An empty directory would make everyone look like they left
+	if len(set.Users) == 0 {
+		errorAndExit(fmt.Errorf("the directory has no members, refusing to prune"), 1)
+	}
+
+	var err error
+	fileChanges := []storage.Change{}
+	if files {
+		if fileChanges, err = storage.PlanFilePrune(policyDir, roleDir, set); err != nil {
+			errorAndExit(err, 1)
+		}
+	}
+	vaultChanges := []storage.Change{}
+	if apply || dryRun {
+		if vaultChanges, err = storageClient.PlanPrune(appCtx, set, authPath); err != nil {
+			errorAndExit(err, 1)
+		}
+	}
+	drops := []string{}
+	if pruneDrops != "" {
+		if drops, err = storage.OrphanedDrops(appCtx, storageClient, entities); err != nil {
+			errorAndExit(err, 1)
+		}
+	}
+
+	if len(fileChanges)+len(vaultChanges)+len(drops) == 0 {
+		fmt.Println("Nothing to prune")
+		return
+	}
+	fmt.Println("Pruning:")
+	for _, c := range fileChanges {
+		fmt.Printf("  %s %s\n", pruneDescription(c), c.File)
+	}
+	for _, c := range vaultChanges {
+		fmt.Printf("  %s in Vault\n", pruneDescription(c))
+	}
+	for _, d := range drops {
+		fmt.Printf("  %s the drop of %s\n", pruneDrops, d)
+	}
+	if dryRun {
+		return
+	}
+	if !yes && !confirm("Prune?") {
+		fmt.Println("Nothing was pruned")
+		return
+	}
+
+	if err := storage.ApplyFileChanges(fileChanges); err != nil {
+		errorAndExit(err, 1)
+	}
+	if err := storageClient.ApplyPolicies(appCtx, vaultChanges, authPath); err != nil {
+		errorAndExit(err, 1)
+	}
+	pruned := append(changeNames(fileChanges), changeNames(vaultChanges)...)
+	for _, d := range drops {
+		pruned = append(pruned, pruneDrops+" drop "+d)
+	}
+	for _, d := range drops {
+		var n int
+		if pruneDrops == "archive" {
+			n, err = storage.ArchiveDrop(appCtx, storageClient, d, time.Now())
+		} else {
+			n, err = storage.WipeDrop(appCtx, storageClient, d)
+		}
+		if err != nil {
+			errorAndExit(fmt.Errorf("unable to %s the drop of %s: %w", pruneDrops, d, err), 1)
+		}
+		fmt.Printf("%s: %d secret(s)\n", d, n)
+	}
+	recordGenerate("pruned", pruned)
+	fmt.Printf("Pruned %d policy and role change(s) and %d drop(s)\n", len(fileChanges)+len(vaultChanges), len(drops))
+}
+
+func pruneDescription(c storage.Change) string {
+	if c.Kind == storage.PolicyChange {
+		return fmt.Sprintf("delete policy %s", c.Name)
+	}
+	if c.New == "" {
+		return fmt.Sprintf("delete %s mapping %s (%s)", c.Kind, c.Name, c.Old)
+	}
+	return fmt.Sprintf("update %s mapping %s: %s -> %s", c.Kind, c.Name, c.Old, c.New)
+}
+
+// recordGenerate records the policies and mappings changed by generate in the audit trail
+func recordGenerate(detail string, targets []string) {
+	login, err := dirState.Whoami(appCtx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to record audit event: %v\n", err)
+		return
+	}
+	recordAudit(audit.Event{Actor: login, Action: audit.Generate, Targets: targets, Detail: detail})
+}
+
+// policyNames returns the sorted names of the policies in set
+func policyNames(set storage.PolicySet) []string {
+	names := []string{}
+	for n := range set.Policies {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// changeNames describes each change in a word or two for the audit trail
+func changeNames(changes []storage.Change) []string {
+	names := []string{}
+	for _, c := range changes {
+		names = append(names, fmt.Sprintf("%s %s %s", c.Action(), c.Kind, c.Name))
+	}
+	return names
+}
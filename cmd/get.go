@@ -2,18 +2,25 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/dollarshaveclub/psst/pkg/audit"
+	"github.com/dollarshaveclub/psst/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
 var (
-	team string
+	team      string
+	noReceipt bool
 )
 
 func init() {
 	rootCmd.AddCommand(getCmd)
 
 	getCmd.Flags().StringVarP(&team, "team", "t", "", "the team currently owning the secret")
+	getCmd.Flags().BoolVar(&noReceipt, "no-receipt", false, "don't let the sender know the secret was retrieved")
 }
 
 var getCmd = &cobra.Command{
@@ -22,9 +29,9 @@ var getCmd = &cobra.Command{
 	Long:  `Get a secret from the current user's drop`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		login, err := dirState.Whoami()
+		login, err := dirState.Whoami(appCtx)
 		if err != nil {
-			errorAndExit(fmt.Errorf("unable to get login name: %+v", err), 1)
+			errorAndExit(fmt.Errorf("unable to get login name: %w", err), 1)
 		}
 
 		// cobra.ExactArgs(1) makes sure we have a single argument
@@ -37,14 +44,66 @@ var getCmd = &cobra.Command{
 			if !ok {
 				errorAndExit(fmt.Errorf("could not find team '%s'", team), 1)
 			}
+			requireScope(entity, "read")
 		}
-		path := storageClient.SecretPath(entity, name)
 
-		data, err := storageClient.Get(path)
-		if err != nil {
-			errorAndExit(err, 1)
+		secret := readSecret(entity, name)
+		fmt.Println(secret.Value)
+		recordAudit(audit.Event{Actor: login, Action: audit.Get, Secret: name, Targets: []string{entity}, ValueHash: hashValue(secret.Value)})
+		if len(secret.Via) > 0 {
+			origin := secret.Origin
+			if origin == "" {
+				origin = "an unknown sender"
+			}
+			fmt.Fprintf(os.Stderr, "shared by %s, forwarded by %s\n", origin, strings.Join(secret.Via, " -> "))
 		}
 
-		fmt.Println(data)
+		if !noReceipt && secret.Sender != "" && secret.Sender != entity {
+			sendReceipt(secret, entity, name, login)
+		}
 	},
 }
+
+// requireScope exits with a clear error when the scope of a team doesn't allow capability on the
+// team drop. Vault enforces the scope either way, this only explains why.
+func requireScope(entity, capability string) {
+	if err := storage.RequireCapability(appCtx, storageClient, entity, capability); err != nil {
+		errorAndExit(err, 1)
+	}
+}
+
+// readSecret gets a secret that can still be used, exiting otherwise. Senders can't delete from other
+// drops, so revoked and expired secrets are cleaned up here.
+func readSecret(entity, name string) *storage.Secret {
+	path := storageClient.SecretPath(entity, name)
+	secret, err := storageClient.GetSecret(appCtx, path)
+	if err != nil {
+		errorAndExit(err, 1)
+	}
+
+	var unusable error
+	switch {
+	case !secret.Revoked.IsZero():
+		unusable = storage.ErrRevoked
+	case secret.Expired(time.Now()):
+		unusable = storage.ErrExpired
+	}
+	if unusable != nil {
+		if err := storageClient.Delete(appCtx, path); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to remove %s: %v\n", name, err)
+		}
+		errorAndExit(fmt.Errorf("%s: %w", name, unusable), 1)
+	}
+	return secret
+}
+
+// sendReceipt lets the sender know a secret was retrieved. The secret has already been printed, so
+// failures are only reported. Senders deliver their own notifications from the receipt, anything
+// else would have the recipient call webhooks and mail servers picked by whoever wrote to the drop.
+func sendReceipt(secret *storage.Secret, entity, name, login string) {
+	host, _ := os.Hostname()
+	receipt := storage.Receipt{By: login, Host: host, At: time.Now()}
+	if err := storageClient.WriteReceipt(appCtx, secret.Sender, entity, name, receipt); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to let %s know the secret was retrieved: %v\n", secret.Sender, err)
+	}
+}
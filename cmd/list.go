@@ -2,30 +2,63 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
 
+	"github.com/dollarshaveclub/psst/pkg/bulk"
+	"github.com/dollarshaveclub/psst/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
+var (
+	recursive bool
+	long      bool
+	page      int
+	pageSize  int
+)
+
 func init() {
 	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().StringVarP(&team, "team", "t", "", "only list the drop of this team")
+	listCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "list the secrets inside folders such as prod/ instead of the folders")
+	listCmd.Flags().BoolVarP(&long, "long", "l", false, "show who shared each secret and when it expires")
+	listCmd.Flags().IntVar(&page, "page", 1, "page of secrets to show for each drop")
+	listCmd.Flags().IntVar(&pageSize, "page-size", 50, "number of secrets shown per page, 0 shows every secret")
 }
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List the set of secrets current available",
-	Long:  `List the set of secrets current available`,
+	Long: `List the set of secrets current available in your drop and the drops of your teams. Secrets
+shared with hierarchical names such as prod/db are listed inside folders, use --recursive to see
+them. Each drop is sorted by name and shown a page at a time.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if page < 1 {
+			errorAndExit(fmt.Errorf("--page must be at least 1"), 1)
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		login, err := dirState.Whoami()
+		login, err := dirState.Whoami(appCtx)
 		if err != nil {
-			errorAndExit(fmt.Errorf("unable to get login name: %+v", err), 1)
+			errorAndExit(fmt.Errorf("unable to get login name: %w", err), 1)
 		}
 
-		if err := listSecrets(login); err != nil {
-			errorAndExit(err, 1)
+		entities := append([]string{login}, dirState.GetActiveMemberTeams()...)
+		if team != "" {
+			entity, ok := dirState.IsTeam(team)
+			if !ok {
+				errorAndExit(fmt.Errorf("could not find team '%s'", team), 1)
+			}
+			requireScope(entity, "list")
+			entities = []string{entity}
 		}
 
-		for _, team := range dirState.GetActiveMemberTeams() {
-			if err := listSecrets(team); err != nil {
+		for _, e := range entities {
+			if err := listSecrets(e); err != nil {
 				errorAndExit(err, 1)
 			}
 		}
@@ -33,19 +66,91 @@ var listCmd = &cobra.Command{
 }
 
 func listSecrets(entity string) error {
-	secret, err := storageClient.List(entity)
+	var names []string
+	var err error
+	if recursive {
+		names, err = storage.Walk(appCtx, storageClient, entity)
+	} else {
+		names, err = storageClient.List(appCtx, entity)
+		sort.Strings(names)
+	}
 	if err != nil {
 		return err
 	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	secrets := []bulk.Secret{}
+	for _, n := range names {
+		secrets = append(secrets, bulk.Secret{Entity: entity, Name: n})
+	}
+	shown, pages := bulk.Page(secrets, page, pageSize)
 
-	if secret != nil && len(secret) > 0 {
-		fmt.Println(entity)
-		fmt.Println("=======")
-		for _, s := range secret {
-			fmt.Printf("  %v\n", s)
+	fmt.Println(entity)
+	fmt.Println("=======")
+	if long {
+		if err := listLong(shown); err != nil {
+			return err
+		}
+	} else {
+		for _, s := range shown {
+			fmt.Printf("  %v\n", s.Name)
 		}
-		fmt.Println()
 	}
+	if pages > 1 {
+		fmt.Printf("  (page %d of %d, %d secrets", page, pages, len(secrets))
+		if page < pages {
+			fmt.Printf(", use --page %d to see more", page+1)
+		}
+		fmt.Println(")")
+	}
+	fmt.Println()
 
 	return nil
 }
+
+// listLong prints each secret with its metadata. Folders and secrets shared before psst recorded
+// metadata have empty columns.
+func listLong(secrets []bulk.Secret) error {
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tFROM\tSHARED\tEXPIRES")
+	for _, s := range secrets {
+		if storage.IsFolder(s.Name) {
+			fmt.Fprintf(w, "  %s\t-\t-\t-\n", s.Name)
+			continue
+		}
+		secret, err := storageClient.GetSecret(appCtx, storageClient.SecretPath(s.Entity, s.Name))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", s.Name, orDash(secret.Sender), formatTime(secret.Created), expiry(secret.Metadata, now))
+	}
+	return w.Flush()
+}
+
+// expiry describes when a secret stops being available
+func expiry(m storage.Metadata, now time.Time) string {
+	switch {
+	case !m.Revoked.IsZero():
+		return "revoked"
+	case m.Expired(now):
+		return "expired"
+	}
+	return formatTime(m.Expires)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(timeFormat)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
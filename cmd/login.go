@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/dollarshaveclub/psst/pkg/storage"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in to Vault",
+	Long: `Log in to Vault and save the token in ~/.vault-token like the Vault CLI does. psst logs in on its
+own when there is no valid token and renews tokens close to expiring, this forces a new login.
+
+The auth method is set in ~/.psst/config.json and defaults to GitHub with GITHUB_TOKEN:
+
+  {"vault": {"auth": {"method": "oidc", "role": "psst"}}}
+
+method is github, oidc, approle or userpass and path overrides where it is mounted (auth/<method>).
+OIDC opens a browser, AppRole uses role as the role ID and the secret ID in VAULT_SECRET_ID and
+userpass needs username and uses VAULT_PASSWORD or asks for the password.
+
+The vault section also sets how psst connects, taking precedence over the VAULT_* variables:
+
+  {"vault": {"address": "https://vault.example.com:8200", "namespace": "eng", "timeout": "30s",
+             "max_retries": 2, "tls": {"ca_cert": "/etc/ssl/vault-ca.pem", "server_name": "vault",
+             "client_cert": "/etc/psst/cert.pem", "client_key": "/etc/psst/key.pem"}}}`,
+	Args:             cobra.NoArgs,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Getenv("VAULT_TOKEN") != "" {
+			errorAndExit(fmt.Errorf("VAULT_TOKEN is set and takes precedence over logging in, unset it first"), 1)
+		}
+		conf, err := storage.LoadVaultConfig(configPath)
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+		v, err := storage.NewVault(conf)
+		if err != nil {
+			errorAndExit(fmt.Errorf("unable to get storage client: %+v", err), 1)
+		}
+		if err := v.Login(appCtx, conf.Auth, terminalPrompter{}); err != nil {
+			errorAndExit(err, 1)
+		}
+		fmt.Printf("Logged in to %s, token saved to %s\n", v.Address(), storage.TokenPath())
+	},
+}
+
+// terminalPrompter asks for what logging in to Vault needs on the terminal
+type terminalPrompter struct{}
+
+func (terminalPrompter) Password(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+func (terminalPrompter) OpenURL(url string) error {
+	fmt.Fprintf(os.Stderr, "Complete the login in your browser, or open:\n\n  %s\n\n", url)
+	var open *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		open = exec.Command("open", url)
+	case "windows":
+		open = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		open = exec.Command("xdg-open", url)
+	}
+	// The URL is printed for when no browser can be opened
+	_ = open.Start()
+	return nil
+}
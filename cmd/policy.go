@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dollarshaveclub/psst/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	against    string
+	jsonOutput bool
+)
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policyDiffCmd)
+
+	policyDiffCmd.Flags().StringVar(&policyDir, "policy-dir", "", "directory of the generated policy files, required unless --against is vault")
+	policyDiffCmd.Flags().StringVar(&roleDir, "role-dir", "", "directory of the generated roles, required unless --against is vault")
+	policyDiffCmd.Flags().StringVar(&allTeam, "default-team", defaultTeam, "team containing every member of your organization")
+	policyDiffCmd.Flags().StringVar(&templates, "templates", "", "directory with general.hcl.tmpl, member.hcl.tmpl and team.hcl.tmpl policy templates")
+	policyDiffCmd.Flags().StringVar(&teamScopes, "team-scopes", "", "JSON file with the capabilities members and maintainers of each team have on the team drop")
+	policyDiffCmd.Flags().StringVar(&authPath, "auth-path", storage.DefaultAuthPath, "path the GitHub auth method is mounted at in Vault")
+	policyDiffCmd.Flags().StringVar(&against, "against", "both", "what to compare with the directory: files, vault or both")
+	policyDiffCmd.Flags().BoolVar(&jsonOutput, "json", false, "print the differences as JSON")
+}
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Inspect the Vault policies psst depends on",
+	Long:  `Inspect the Vault policies psst depends on`,
+}
+
+var policyDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the policies from the directory with the policy files and Vault",
+	Long: `Compare the policies and roles psst generate would create from the directory with the policy
+files in --policy-dir and --role-dir and with what is in Vault. Hand edited policies, missing
+policies and role mappings, mappings that lost their psst policy and leftovers of members and teams
+that left are reported. Exits with 2 when there are differences so it can be used in CI.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if against != "files" && against != "vault" && against != "both" {
+			errorAndExit(fmt.Errorf("--against must be files, vault or both"), 1)
+		}
+		if against != "vault" && (policyDir == "" || roleDir == "") {
+			errorAndExit(fmt.Errorf("--policy-dir and --role-dir are required to compare with the policy files, or use --against vault"), 1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		_, desired := renderPolicies()
+
+		drifts := []storage.Drift{}
+		if against != "vault" {
+			actual, err := storage.ReadPolicyFiles(policyDir, roleDir)
+			if err != nil {
+				errorAndExit(err, 1)
+			}
+			drifts = append(drifts, storage.CompareDesired("files", desired, actual)...)
+		}
+		if against != "files" {
+			actual, err := storageClient.ReadPolicies(appCtx, authPath)
+			if err != nil {
+				errorAndExit(err, 1)
+			}
+			drifts = append(drifts, storage.CompareDesired("vault", desired, actual)...)
+		}
+
+		if jsonOutput {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(drifts); err != nil {
+				errorAndExit(err, 1)
+			}
+		} else {
+			printDrifts(drifts)
+		}
+		if len(drifts) > 0 {
+			os.Exit(exitDrift)
+		}
+	},
+}
+
+func printDrifts(drifts []storage.Drift) {
+	if len(drifts) == 0 {
+		fmt.Println("Policies match the directory")
+		return
+	}
+	for _, d := range drifts {
+		fmt.Println(d)
+		if d.Problem == storage.DriftModified {
+			for _, l := range storage.DiffLines(d.Expected, d.Actual) {
+				fmt.Printf("    %s\n", l)
+			}
+		}
+	}
+	fmt.Printf("\n%d difference(s)\n", len(drifts))
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dollarshaveclub/psst/pkg/outbox"
+	"github.com/dollarshaveclub/psst/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(revokeCmd)
+}
+
+var revokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke a secret you shared that hasn't been retrieved yet",
+	Long: `Revoke the most recent share of a secret from every recipient that hasn't retrieved it yet.
+Recipients that already retrieved the secret are left alone since they have seen the value.
+
+For a secret shared with --wrap, the wrapping token kept in your drop is used up if it hasn't been
+used yet, so nobody else can unwrap it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		login, err := dirState.Whoami(appCtx)
+		if err != nil {
+			errorAndExit(fmt.Errorf("unable to get login name: %w", err), 1)
+		}
+
+		// cobra.ExactArgs(1) makes sure we have a single argument
+		name := args[0]
+
+		o, err := outbox.Load(outboxPath)
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+		entry := o.Latest(name)
+		if entry == nil {
+			errorAndExit(fmt.Errorf("no shared secret named '%s' in your outbox", name), 1)
+		}
+
+		now := time.Now()
+		if entry.Wrapped() {
+			revokeWrapped(o, entry, login, now)
+			return
+		}
+
+		pending := make(map[string]struct{})
+		for _, t := range entry.Targets {
+			receipt, err := storageClient.GetReceipt(appCtx, login, t, name)
+			if err != nil {
+				errorAndExit(err, 1)
+			}
+			if status := entry.Status(receipt, now); status != outbox.Pending {
+				fmt.Printf("%s: %s, skipping\n", t, status)
+				continue
+			}
+			pending[t] = struct{}{}
+		}
+
+		if len(pending) > 0 {
+			meta := storage.Metadata{Sender: login, Created: entry.Created, Expires: entry.Expires, Revoked: now}
+			if err := storageClient.Revoke(appCtx, name, meta, pending); err != nil {
+				errorAndExit(err, 1)
+			}
+			for t := range pending {
+				fmt.Printf("%s: revoked\n", t)
+			}
+		}
+
+		entry.Revoked = now
+		if err := o.Save(); err != nil {
+			errorAndExit(err, 1)
+		}
+	},
+}
+
+// revokeWrapped uses up the wrapping token of entry unless it was already used or expired
+func revokeWrapped(o *outbox.Outbox, entry *outbox.Entry, login string, now time.Time) {
+	if vaultClient == nil {
+		errorAndExit(fmt.Errorf("revoking a wrapped secret needs the Vault storage backend"), 1)
+	}
+	status, changed := wrapStatus(entry, login, now)
+	if status != outbox.Pending {
+		if changed {
+			if err := o.Save(); err != nil {
+				fmt.Fprintf(os.Stderr, "unable to save outbox: %v\n", err)
+			}
+		}
+		errorAndExit(fmt.Errorf("the wrapping token of %s can't be revoked, it is %s", entry.Name, status), 1)
+	}
+	if err := vaultClient.RevokeWrap(appCtx, login, entry.WrapAccessor); err != nil {
+		errorAndExit(err, 1)
+	}
+	fmt.Printf("%s: revoked\n", wrappedRecipient)
+
+	entry.Revoked = now
+	if err := o.Save(); err != nil {
+		errorAndExit(err, 1)
+	}
+}
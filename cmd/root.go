@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/dollarshaveclub/psst/pkg/directory"
 	"github.com/dollarshaveclub/psst/pkg/storage"
@@ -22,9 +27,31 @@ var (
 
 	dirState      directory.Backend
 	storageClient storage.Backend
+	// vaultClient is set when storage is in Vault so long running commands can keep the token fresh
+	vaultClient *storage.VaultStore
+	vaultConfig storage.VaultConfig
+
+	// appCtx is cancelled on Ctrl-C or SIGTERM and passed to every storage and directory call
+	appCtx = context.Background()
+	// sharing tracks shares in progress, which get to finish rolling back before psst exits
+	sharing sync.WaitGroup
+	// sharingMu guards exiting, set once psst waits for sharing so no share starts after the wait
+	sharingMu sync.Mutex
+	exiting   bool
 
 	updateCache bool
 	debug       bool
+
+	// secretsCacheDir holds the secret names last listed for each drop, used by shell completion
+	secretsCacheDir = os.ExpandEnv("${HOME}/.psst/cache/secrets")
+	// outboxPath records the secrets shared from this machine
+	outboxPath = os.ExpandEnv("${HOME}/.psst/outbox.json")
+	// watchStatePath records the secrets psst watch has already seen
+	watchStatePath = os.ExpandEnv("${HOME}/.psst/watch.json")
+	// auditPath is where the audit trail is kept unless the config lists other sinks
+	auditPath = os.ExpandEnv("${HOME}/.psst/audit.jsonl")
+	// configPath holds settings that rarely change, like how to log in to Vault
+	configPath = os.ExpandEnv("${HOME}/.psst/config.json")
 )
 
 func init() {
@@ -38,7 +65,16 @@ func init() {
 var rootCmd = &cobra.Command{
 	Use:   "psst",
 	Short: "Psst is a tool for securely sharing secrets inside of your organization",
-	Long:  `Psst is a tool for securely sharing secrets inside of your organization`,
+	Long: `Psst is a tool for securely sharing secrets inside of your organization
+
+Exit codes:
+  1    general error
+  2    policy diff found differences
+  3    secret not found
+  4    permission denied by the storage backend or GitHub
+  5    secret has expired or was revoked
+  6    storage backend or GitHub unavailable
+  130  interrupted`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		var err error
 
@@ -50,9 +86,9 @@ var rootCmd = &cobra.Command{
 
 			fmt.Fprintf(os.Stderr, "Checking members and teams cache...\n\n")
 
-			dirState, err = directory.NewGitHub(Org, updateCache)
+			dirState, err = directory.NewGitHub(appCtx, Org, updateCache)
 			if err != nil {
-				errorAndExit(fmt.Errorf("unable to get directory client: %+v", err), 1)
+				errorAndExit(fmt.Errorf("unable to get directory client: %w", err), 1)
 			}
 		default:
 			errorAndExit(errors.New("you must provide a valid directory backend"), 1)
@@ -60,10 +96,16 @@ var rootCmd = &cobra.Command{
 
 		switch storageBackend {
 		case "vault":
-			storageClient, err = storage.NewVault()
-			if err != nil {
+			if vaultConfig, err = storage.LoadVaultConfig(configPath); err != nil {
+				errorAndExit(err, 1)
+			}
+			if vaultClient, err = storage.NewVault(vaultConfig); err != nil {
 				errorAndExit(fmt.Errorf("unable to get storage client: %+v", err), 1)
 			}
+			if err := vaultClient.EnsureToken(appCtx, vaultConfig.Auth, terminalPrompter{}); err != nil {
+				errorAndExit(err, 1)
+			}
+			storageClient = storage.NewListCache(vaultClient, secretsCacheDir)
 		default:
 			errorAndExit(errors.New("you must provide a valid storage backend"), 1)
 		}
@@ -72,17 +114,70 @@ var rootCmd = &cobra.Command{
 
 // Execute is the entrypoint for running the different commands of psst
 func Execute() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	appCtx = ctx
+	go func() {
+		<-ctx.Done()
+		// A second signal kills psst right away, otherwise give in-flight requests a moment to
+		// notice the cancellation so commands can report it
+		stop()
+		time.Sleep(cancelGracePeriod)
+		waitForShares()
+		errorAndExit(ctx.Err(), 1)
+	}()
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
+// startShare adds a share to sharing unless psst is already exiting
+func startShare() bool {
+	sharingMu.Lock()
+	defer sharingMu.Unlock()
+	if exiting {
+		return false
+	}
+	sharing.Add(1)
+	return true
+}
+
+// waitForShares waits for interrupted shares to roll back the copies they already wrote
+func waitForShares() {
+	sharingMu.Lock()
+	exiting = true
+	sharingMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		sharing.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(storage.RollbackTimeout):
+	}
+}
+
+// errorAndExit prints err and exits. Errors from the storage and directory backends replace a
+// general exit code of 1 with a more specific one and a hint on what to do.
 func errorAndExit(err error, code int) {
 	format := "%v\n"
 	if debug {
 		format = "%+v\n"
 	}
+	if code == 1 {
+		code = exitCode(err)
+	}
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		os.Exit(code)
+	}
 	fmt.Fprintf(os.Stderr, format, err)
+	if hint := errorHint(err); hint != "" {
+		fmt.Fprintln(os.Stderr, hint)
+	}
 	os.Exit(code)
 }
@@ -8,14 +8,20 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	limit int
+)
+
 func init() {
 	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().IntVarP(&limit, "limit", "l", 10, "maximum number of members and teams to show for a search term (0 for no limit)")
 }
 
 var searchCmd = &cobra.Command{
 	Use:   "search",
 	Short: "Search for a member or team in your GitHub organization",
-	Long:  `Search for a member or team in your GitHub organization. You may provide a search team or leave it blank to see all available members and teams`,
+	Long:  `Search for a member or team in your GitHub organization. You may provide a search team or leave it blank to see all available members and teams. Results are ordered from the closest match and tolerate small typos.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		matches := search(dirState, args)
 
@@ -43,5 +49,9 @@ func search(dirState directory.Backend, args []string) directory.Matches {
 		lookup = strings.Join(args, " ")
 	}
 
-	return dirState.GetMatches(lookup)
+	// Showing everything is the point of a search without a term so only cap real lookups
+	if lookup == "*" {
+		return dirState.GetMatches(lookup)
+	}
+	return dirState.GetMatches(lookup).Limit(limit)
 }
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dollarshaveclub/psst/pkg/notify"
+	"github.com/dollarshaveclub/psst/pkg/outbox"
+	"github.com/dollarshaveclub/psst/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+const (
+	timeFormat = "2006-01-02 15:04"
+
+	// wrappedRecipient is shown for secrets shared with a wrapping token
+	wrappedRecipient = "(wrapped)"
+	// unknownStatus is shown for wrapped secrets whose token can't be looked up
+	unknownStatus outbox.Status = "unknown"
+)
+
+func init() {
+	rootCmd.AddCommand(sentCmd)
+}
+
+var sentCmd = &cobra.Command{
+	Use:   "sent",
+	Short: "List the secrets you have shared and whether they were retrieved",
+	Long: `List the secrets you have shared from this machine along with each recipient and whether the
+secret is pending, retrieved, expired or revoked. Notifiers given to share with --notify run here,
+or in psst watch, the first time a retrieval is seen, so this can be run from cron.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		login, err := dirState.Whoami(appCtx)
+		if err != nil {
+			errorAndExit(fmt.Errorf("unable to get login name: %w", err), 1)
+		}
+
+		o, err := outbox.Load(outboxPath)
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+		if len(o.Entries) == 0 {
+			fmt.Println("No secrets have been shared from this machine")
+			return
+		}
+
+		now := time.Now()
+		notified := false
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tRECIPIENT\tSENT\tSTATUS")
+		for i := range o.Entries {
+			e := &o.Entries[i]
+			if e.Wrapped() {
+				status, changed := wrapStatus(e, login, now)
+				notified = notified || changed
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Name, wrappedRecipient, e.Created.Local().Format(timeFormat), status)
+				continue
+			}
+			for _, t := range e.Targets {
+				status, receipt, changed, err := targetStatus(e, login, t, now)
+				if err != nil {
+					errorAndExit(err, 1)
+				}
+				notified = notified || changed
+				if status == outbox.Retrieved && receipt.By != t {
+					status = outbox.Status(fmt.Sprintf("%s by %s", status, receipt.By))
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Name, t, e.Created.Local().Format(timeFormat), status)
+			}
+		}
+		w.Flush()
+
+		if notified {
+			if err := o.Save(); err != nil {
+				errorAndExit(err, 1)
+			}
+		}
+	},
+}
+
+// wrapStatus returns the status of a secret shared with a wrapping token, looking up the token kept
+// in the drop of login until it is used, revoked or expired. When the token is first seen used, the
+// time is recorded and the outbox needs saving.
+func wrapStatus(e *outbox.Entry, login string, now time.Time) (outbox.Status, bool) {
+	if status := e.WrapStatus(false, now); status != outbox.Retrieved {
+		return status, false
+	}
+	if vaultClient == nil {
+		return unknownStatus, false
+	}
+	pending, err := vaultClient.WrapPending(appCtx, login, e.WrapAccessor)
+	if errors.Is(err, storage.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "unable to check %s, its wrapping token is no longer in your drop\n", e.Name)
+		return unknownStatus, false
+	}
+	if err != nil {
+		errorAndExit(err, 1)
+	}
+	status := e.WrapStatus(pending, now)
+	if status != outbox.Retrieved {
+		return status, false
+	}
+	e.Unwrapped = now
+	return status, true
+}
+
+// targetStatus returns the status of e for target along with its receipt, running the notifiers of e
+// the first time the secret is seen retrieved, in which case the outbox needs saving
+func targetStatus(e *outbox.Entry, login, target string, now time.Time) (outbox.Status, *storage.Receipt, bool, error) {
+	receipt, err := storageClient.GetReceipt(appCtx, login, target, e.Name)
+	if err != nil {
+		return "", nil, false, err
+	}
+	status := e.Status(receipt, now)
+	if status != outbox.Retrieved || len(e.Notify) == 0 || e.WasNotified(target) {
+		return status, receipt, false, nil
+	}
+	notifySender(e, login, target, receipt)
+	return status, receipt, true, nil
+}
+
+// notifyRetrieved runs the notifiers of every secret in the outbox seen retrieved since the last check
+func notifyRetrieved(login string) error {
+	o, err := outbox.Load(outboxPath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	notified := false
+	for i := range o.Entries {
+		e := &o.Entries[i]
+		if len(e.Notify) == 0 || e.Wrapped() {
+			continue
+		}
+		for _, t := range e.Targets {
+			if e.WasNotified(t) {
+				continue
+			}
+			_, _, changed, err := targetStatus(e, login, t, now)
+			if err != nil {
+				return err
+			}
+			notified = notified || changed
+		}
+	}
+	if notified {
+		return o.Save()
+	}
+	return nil
+}
+
+// notifySender runs the notifiers of e for a target that retrieved the secret
+func notifySender(e *outbox.Entry, login, target string, receipt *storage.Receipt) {
+	notice := notify.Notice{Secret: e.Name, Sender: login, Target: target, By: receipt.By, Host: receipt.Host, At: receipt.At}
+	for _, spec := range e.Notify {
+		n, err := notify.Parse(spec)
+		if err == nil {
+			err = n.Notify(notice)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to run notifier for %s: %v\n", e.Name, err)
+		}
+	}
+	e.Notified = append(e.Notified, target)
+}
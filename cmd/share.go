@@ -2,17 +2,41 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dollarshaveclub/psst/pkg/audit"
 	"github.com/dollarshaveclub/psst/pkg/directory"
+	"github.com/dollarshaveclub/psst/pkg/notify"
+	"github.com/dollarshaveclub/psst/pkg/outbox"
+	"github.com/dollarshaveclub/psst/pkg/secretgen"
+	"github.com/dollarshaveclub/psst/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
+const (
+	outputPerms = 0600
+)
+
 var (
 	filename string
 	members  []string
 	name     string
 	teams    []string
 	ttl      string
+
+	generate string
+	keep     bool
+	output   string
+	notifies []string
+
+	bestEffort bool
+
+	wrap    bool
+	wrapTTL string
 )
 
 func init() {
@@ -22,29 +46,115 @@ func init() {
 	shareCmd.Flags().StringArrayVarP(&members, "member", "m", []string{}, "members to provide secret to (use multiple times for multiple members)")
 	shareCmd.Flags().StringVarP(&name, "name", "n", "", "name of the secret")
 	shareCmd.Flags().StringArrayVarP(&teams, "team", "t", []string{}, "team to provide secrets to (use multiple times for multiple teams)")
+	shareCmd.Flags().StringVarP(&generate, "generate", "g", "", fmt.Sprintf("generate a new secret instead of reading a file (%s)", strings.Join(secretgen.Kinds(), ", ")))
+	shareCmd.Flags().BoolVar(&keep, "keep", false, "also save a generated secret to your own drop")
+	shareCmd.Flags().StringVarP(&output, "output", "o", "", "also save a generated secret to a local file")
+	shareCmd.Flags().StringVar(&ttl, "ttl", "", "how long the secret can be retrieved for (e.g. 12h or 7d)")
+	shareCmd.Flags().BoolVar(&bestEffort, "best-effort", false, "keep the secret with the targets that succeeded instead of rolling back when some fail")
+	shareCmd.Flags().StringArrayVar(&notifies, "notify", []string{}, "notify you when psst sent or psst watch on this machine sees the secret was retrieved: webhook:<url>, smtp://<host:port>?to=<address> or command:<command> (use multiple times for multiple notifiers)")
 
-	shareCmd.MarkFlagRequired("filename")
+	shareCmd.Flags().BoolVar(&wrap, "wrap", false, "share with a single use Vault wrapping token instead of a drop, for people outside the organization")
+	shareCmd.Flags().StringVar(&wrapTTL, "wrap-ttl", "1h", "how long the wrapping token can be used for")
 	shareCmd.MarkFlagRequired("name")
 }
 
 var shareCmd = &cobra.Command{
 	Use:   "share",
 	Short: "Share a secret in a user or set of user's drop(s)",
-	Long:  `Share a secret in a user or set of user's drop(s)`,
+	Long: `Share a secret in a user or set of user's drop(s). The secret is read from a file or, with
+--generate, created with a cryptographically secure random generator.
+
+With --wrap the secret isn't written to any drop. Vault keeps it behind a single use wrapping token
+that expires after --wrap-ttl, for vendors or new hires who aren't in the organization yet. Hand them
+the token, they get the secret with psst unwrap <token> without needing a GitHub or Vault login.
+The token is kept in your drop, where only you can read it, so psst sent can show whether it was
+used and psst revoke can use it up first. Vault doesn't say who used it, so a token intercepted on
+the way shows as retrieved too.`,
 	PreRun: func(cmd *cobra.Command, args []string) {
-		if len(members) == 0 && len(teams) == 0 {
+		if wrap {
+			validateWrap()
+		} else if len(members) == 0 && len(teams) == 0 {
 			errorAndExit(fmt.Errorf("you must provide either members and/or teams"), 1)
 		}
+		if err := storage.ValidateName(name); err != nil {
+			errorAndExit(err, 1)
+		}
+		if (filename == "") == (generate == "") {
+			errorAndExit(fmt.Errorf("you must provide either a filename or a secret to generate"), 1)
+		}
+		if generate == "" && (keep || output != "") {
+			errorAndExit(fmt.Errorf("--keep and --output can only be used with --generate"), 1)
+		}
+		if ttl != "" {
+			if _, err := parseDuration(ttl); err != nil {
+				errorAndExit(fmt.Errorf("invalid --ttl: %v", err), 1)
+			}
+		}
+		for _, spec := range notifies {
+			if _, err := notify.Parse(spec); err != nil {
+				errorAndExit(fmt.Errorf("invalid --notify: %v", err), 1)
+			}
+		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		// Use a map as an easy way to have a list without duplicates
+		login, err := dirState.Whoami(appCtx)
+		if err != nil {
+			errorAndExit(fmt.Errorf("unable to get login name: %w", err), 1)
+		}
+
+		// Use a map as an easy way to have a list without duplicates. Wrapped secrets have none.
 		targets, err := targets(dirState, members, teams)
 		if err != nil {
 			errorAndExit(err, 1)
 		}
+		requireTeamScopes(teams)
 
-		if err := storageClient.Write(filename, name, targets); err != nil {
-			errorAndExit(err, 1)
+		meta := storage.Metadata{Sender: login, Created: time.Now()}
+		if ttl != "" {
+			d, _ := parseDuration(ttl)
+			meta.Expires = meta.Created.Add(d)
+		}
+
+		if generate == "" {
+			buf, err := ioutil.ReadFile(filename)
+			if err != nil {
+				errorAndExit(fmt.Errorf("unable to read file %s: %+v", filename, err), 1)
+			}
+			if wrap {
+				shareWrapped(string(buf), name, meta)
+			} else {
+				shareSecret(audit.Event{Action: audit.Share}, string(buf), name, meta, notifies, targets)
+			}
+			return
+		}
+
+		secret, err := secretgen.Generate(generate)
+		if err != nil {
+			errorAndExit(fmt.Errorf("unable to generate secret: %v", err), 1)
+		}
+
+		if keep {
+			targets[login] = struct{}{}
+		}
+
+		// Save the local copy first so a failure doesn't leave recipients with a secret nobody else has
+		if output != "" {
+			if _, err := os.Stat(output); err == nil {
+				errorAndExit(fmt.Errorf("%s already exists, not overwriting", output), 1)
+			}
+			if err := ioutil.WriteFile(output, []byte(secret.Value), outputPerms); err != nil {
+				errorAndExit(fmt.Errorf("unable to write %s: %v", output, err), 1)
+			}
+		}
+
+		if wrap {
+			shareWrapped(secret.Value, name, meta)
+		} else {
+			shareSecret(audit.Event{Action: audit.Share, Detail: "generated " + generate}, secret.Value, name, meta, notifies, targets)
+		}
+
+		if secret.Public != "" {
+			fmt.Print(secret.Public)
 		}
 	},
 }
@@ -69,3 +179,126 @@ func targets(dirState directory.Backend, members, teams []string) (map[string]st
 	}
 	return targets, nil
 }
+
+// requireTeamScopes exits when the scope of one of teams doesn't let the user share to the team
+// drop, before anything is written
+func requireTeamScopes(teams []string) {
+	for _, t := range teams {
+		if name, ok := dirState.IsTeam(t); ok {
+			requireScope(name, "create")
+		}
+	}
+}
+
+// shareSecret writes the secret to every target, reports who received it and records it in the
+// outbox, along with the notifiers psst sent and psst watch run once it is retrieved, and the audit
+// trail as event. Exits if any target failed, after recording the targets that received the secret.
+func shareSecret(event audit.Event, value, name string, meta storage.Metadata, notifiers []string, targets map[string]struct{}) {
+	if !startShare() {
+		errorAndExit(appCtx.Err(), 1)
+	}
+	results, shareErr := storage.Share(appCtx, storageClient, value, name, meta, targets, bestEffort)
+	sharing.Done()
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(os.Stderr, "  failed       %s: %v\n", r.Target, r.Err)
+		case r.RolledBack:
+			fmt.Fprintf(os.Stderr, "  rolled back  %s\n", r.Target)
+		default:
+			fmt.Fprintf(os.Stderr, "  received     %s\n", r.Target)
+		}
+	}
+
+	if received := results.Received(); len(received) > 0 {
+		recordSent(name, meta, notifiers, received)
+		event.Actor = meta.Sender
+		event.Time = meta.Created
+		event.Secret = name
+		event.Targets = received
+		event.ValueHash = hashValue(value)
+		recordAudit(event)
+	}
+	if shareErr != nil {
+		errorAndExit(shareErr, 1)
+	}
+}
+
+// recordSent adds a shared secret to the outbox. The secret has already been shared at this point, so
+// failures are only reported.
+func recordSent(name string, meta storage.Metadata, notifiers []string, targets []string) {
+	o, err := outbox.Load(outboxPath)
+	if err == nil {
+		o.Add(outbox.Entry{Name: name, Targets: targets, Created: meta.Created, Expires: meta.Expires, Notify: notifiers})
+		err = o.Save()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to record secret in outbox: %v\n", err)
+	}
+}
+
+// validateWrap checks that --wrap isn't combined with flags that only apply to drops
+func validateWrap() {
+	if len(members) > 0 || len(teams) > 0 {
+		errorAndExit(fmt.Errorf("--wrap shares with whoever holds the token, it can't be used with --member or --team"), 1)
+	}
+	if keep || ttl != "" || len(notifies) > 0 || bestEffort {
+		errorAndExit(fmt.Errorf("--keep, --ttl, --notify and --best-effort can't be used with --wrap, use --wrap-ttl for the expiration"), 1)
+	}
+	if _, err := parseDuration(wrapTTL); err != nil {
+		errorAndExit(fmt.Errorf("invalid --wrap-ttl: %v", err), 1)
+	}
+}
+
+// shareWrapped stores the secret behind a wrapping token, prints the token and records it in the
+// outbox so psst sent can tell whether it was used
+func shareWrapped(value, name string, meta storage.Metadata) {
+	if vaultClient == nil {
+		errorAndExit(fmt.Errorf("--wrap needs the Vault storage backend"), 1)
+	}
+	d, _ := parseDuration(wrapTTL)
+	wrapped, err := vaultClient.Wrap(appCtx, value, d)
+	if err != nil {
+		errorAndExit(err, 1)
+	}
+	if err := vaultClient.KeepWrap(appCtx, meta.Sender, wrapped); err != nil {
+		fmt.Fprintf(os.Stderr, "%v, psst sent won't be able to tell whether it was used\n", err)
+	}
+
+	o, err := outbox.Load(outboxPath)
+	if err == nil {
+		o.Add(outbox.Entry{Name: name, Targets: []string{}, Created: wrapped.Created, Expires: wrapped.Expires, WrapAccessor: wrapped.Accessor})
+		err = o.Save()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to record secret in outbox: %v\n", err)
+	}
+	// The accessor lets Vault administrators look the token up without being able to unwrap it, and
+	// the sender find the token in their drop
+	recordAudit(audit.Event{Actor: meta.Sender, Action: audit.Share, Time: wrapped.Created, Secret: name,
+		ValueHash: hashValue(value), Detail: "wrapped, accessor " + wrapped.Accessor})
+
+	fmt.Fprintf(os.Stderr, "Wrapped %s, the token can be used once until %s:\n\n", name, wrapped.Expires.Local().Format(timeFormat))
+	fmt.Println(wrapped.Token)
+	fmt.Fprintf(os.Stderr, "\nUnwrap it with: VAULT_ADDR=%s psst unwrap <token>\n", vaultClient.Address())
+	fmt.Fprintf(os.Stderr, "or paste it at %s/ui/vault/tools/unwrap\n", strings.TrimSuffix(vaultClient.Address(), "/"))
+}
+
+// parseDuration works like time.ParseDuration and also accepts a number of days such as 7d
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid number of days '%s'", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive")
+	}
+	return d, nil
+}
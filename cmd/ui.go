@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dollarshaveclub/psst/pkg/tui"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(uiCmd)
+}
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse and manage your drops in an interactive terminal interface",
+	Long: `Browse and manage your drops in an interactive terminal interface. Your drop and the drops of
+every team you are a member of are shown side by side. Secrets can be previewed, deleted, shared
+with other members or teams and copied to a file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		login, err := dirState.Whoami(appCtx)
+		if err != nil {
+			errorAndExit(fmt.Errorf("unable to get login name: %w", err), 1)
+		}
+
+		term, err := tui.NewTerminal()
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+
+		app := tui.NewApp(term, storageClient, dirState, login)
+		app.Audit = auditEvent
+		app.HashValue = hashValue
+		runErr := app.Run(appCtx)
+		if err := term.Close(); err != nil {
+			errorAndExit(fmt.Errorf("unable to restore terminal: %+v", err), 1)
+		}
+		if runErr != nil {
+			errorAndExit(runErr, 1)
+		}
+	},
+}
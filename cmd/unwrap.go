@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dollarshaveclub/psst/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var checkOnly bool
+
+func init() {
+	rootCmd.AddCommand(unwrapCmd)
+	unwrapCmd.Flags().BoolVar(&checkOnly, "check", false, "only check whether the token can still be unwrapped, without using it")
+}
+
+var unwrapCmd = &cobra.Command{
+	Use:   "unwrap [token]",
+	Short: "Get a secret shared with a wrapping token",
+	Long: `Get a secret shared with psst share --wrap. The token works once, so keep the secret somewhere
+safe. The token is read from standard input when it isn't given, which keeps it out of the shell
+history. Only VAULT_ADDR, or the address in ~/.psst/config.json, is needed: no GitHub token, Vault
+login or membership in the organization.
+
+With --check the token is looked up without using it, so senders can tell whether it was used.`,
+	Args: cobra.MaximumNArgs(1),
+	// Recipients may not be in the directory or have a Vault token
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		token := ""
+		if len(args) == 1 {
+			token = args[0]
+		} else {
+			fmt.Fprint(os.Stderr, "Wrapping token: ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				errorAndExit(fmt.Errorf("unable to read token: %v", err), 1)
+			}
+			token = line
+		}
+		token = strings.TrimSpace(token)
+		if token == "" {
+			errorAndExit(fmt.Errorf("you must provide a wrapping token"), 1)
+		}
+
+		conf, err := storage.LoadVaultConfig(configPath)
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+		v, err := storage.NewVault(conf)
+		if err != nil {
+			errorAndExit(fmt.Errorf("unable to get storage client: %+v", err), 1)
+		}
+
+		if checkOnly {
+			valid, err := v.WrapValid(appCtx, token)
+			if err != nil {
+				errorAndExit(err, 1)
+			}
+			if !valid {
+				errorAndExit(fmt.Errorf("%w: the wrapping token was already used, has expired or is mistyped", storage.ErrExpired), 1)
+			}
+			fmt.Println("The wrapping token has not been used yet")
+			return
+		}
+
+		secret, err := v.Unwrap(appCtx, token)
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+		fmt.Println(secret)
+	},
+}
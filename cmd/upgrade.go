@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/dollarshaveclub/psst/internal/update"
+	"github.com/dollarshaveclub/psst/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var (
+	channel      string
+	forceUpgrade bool
+)
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+
+	upgradeCmd.Flags().StringVar(&channel, "channel", update.ChannelStable, "release channel to upgrade from: stable or prerelease")
+	upgradeCmd.Flags().BoolVar(&forceUpgrade, "force", false, "install the latest release even if it is not newer")
+}
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade psst to the latest release",
+	Long: `Upgrade psst to the latest release. The release for your platform is downloaded from GitHub,
+its SHA-256 checksum is verified against the release's SHA256SUMS and the running binary is replaced.
+Use --channel prerelease to include prereleases.`,
+	// Upgrading must work even when the backends are not set up
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		exe, err := os.Executable()
+		if err != nil {
+			errorAndExit(fmt.Errorf("Unable to find the psst binary: %+v", err), 1)
+		}
+		exe, err = filepath.EvalSymlinks(exe)
+		if err != nil {
+			errorAndExit(fmt.Errorf("Unable to find the psst binary: %+v", err), 1)
+		}
+		if strings.Contains(exe, "/Cellar/") {
+			fmt.Fprintln(os.Stderr, "psst was installed with Homebrew, upgrade with: brew upgrade psst")
+			os.Exit(1)
+		}
+
+		u := &update.Updater{
+			Client:      http.DefaultClient,
+			ReleasesURL: version.ReleasesURL,
+			Token:       os.Getenv("GITHUB_TOKEN"),
+			GOOS:        runtime.GOOS,
+			GOARCH:      runtime.GOARCH,
+			PublicKey:   update.PublicKey,
+		}
+
+		release, err := u.Find(channel)
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+		current := version.Get().Version
+		if !forceUpgrade && !version.Newer(current, release.TagName) {
+			fmt.Printf("psst is already up to date (%s, latest release is %s)\n", current, release.TagName)
+			return
+		}
+
+		fmt.Printf("Downloading psst %s...\n", release.TagName)
+		binary, err := u.Download(release)
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+		if err := update.Replace(exe, binary); err != nil {
+			errorAndExit(err, 1)
+		}
+		fmt.Printf("Upgraded %s from %s to %s\n", exe, current, release.TagName)
+	},
+}
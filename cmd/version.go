@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/dollarshaveclub/psst/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkLatest bool
+)
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().BoolVar(&checkLatest, "check", false, "check GitHub for a newer release")
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of psst and how it was built",
+	Long:  `Print the version of psst and how it was built, optionally checking for a newer release`,
+	// Printing the version must work even when the backends are not set up
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		info := version.Get()
+		commit := info.CommitSHA
+		if info.Modified {
+			commit += " (modified)"
+		}
+
+		fmt.Printf("Version:            %s\n", info.Version)
+		fmt.Printf("Commit:             %s\n", commit)
+		fmt.Printf("Go version:         %s\n", info.GoVersion)
+		fmt.Printf("Platform:           %s\n", info.Platform)
+		fmt.Printf("Compiled directory: %s\n", valueOrNone(CompiledDirectory))
+		fmt.Printf("Compiled storage:   %s\n", valueOrNone(CompiledStorage))
+		fmt.Printf("Organization:       %s\n", valueOrNone(Org))
+
+		if !checkLatest {
+			return
+		}
+
+		release, err := version.Latest(http.DefaultClient, version.LatestReleaseURL, os.Getenv("GITHUB_TOKEN"))
+		if err != nil {
+			errorAndExit(err, 1)
+		}
+		fmt.Println()
+		if !version.Newer(info.Version, release.TagName) {
+			fmt.Printf("psst is up to date (latest release is %s)\n", release.TagName)
+			return
+		}
+		fmt.Printf("A newer version of psst is available: %s\n", release.TagName)
+		fmt.Printf("  %s\n\n", release.HTMLURL)
+		fmt.Println("Upgrade with:")
+		fmt.Println("  psst upgrade (or brew upgrade psst if installed with Homebrew)")
+	},
+}
+
+func valueOrNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
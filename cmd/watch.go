@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dollarshaveclub/psst/pkg/storage"
+	"github.com/dollarshaveclub/psst/pkg/watch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	interval  time.Duration
+	once      bool
+	watchHook string
+)
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().DurationVar(&interval, "interval", 5*time.Minute, "how often to check for new secrets")
+	watchCmd.Flags().BoolVar(&once, "once", false, "check once and exit, useful from cron")
+	watchCmd.Flags().StringVar(&watchHook, "hook", "", "command to run for each new secret, with PSST_ENTITY and PSST_SECRET set")
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch your drop and your teams' drops for new secrets",
+	Long: `Watch your drop and your teams' drops for new secrets, printing each one as it arrives and
+optionally running a hook command. The secrets already seen are remembered between runs, so the
+first run only records what is there. Notifiers of secrets you shared with --notify run as soon as
+a retrieval is seen.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if interval < time.Second {
+			errorAndExit(fmt.Errorf("--interval must be at least 1s"), 1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		login, err := dirState.Whoami(appCtx)
+		if err != nil {
+			errorAndExit(fmt.Errorf("unable to get login name: %w", err), 1)
+		}
+
+		for {
+			// Renew the Vault token while it is close to expiring so watching can run for days
+			if vaultClient != nil {
+				if err := vaultClient.EnsureToken(appCtx, vaultConfig.Auth, terminalPrompter{}); err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", time.Now().Format(timeFormat), err)
+				}
+			}
+			if err := pollDrops(login); err != nil {
+				if once || appCtx.Err() != nil {
+					errorAndExit(err, 1)
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", time.Now().Format(timeFormat), err)
+			}
+			if once {
+				return
+			}
+			select {
+			case <-time.After(interval):
+			case <-appCtx.Done():
+				errorAndExit(appCtx.Err(), 1)
+			}
+		}
+	},
+}
+
+// pollDrops lists every drop the user can read and reports secrets that weren't there last time
+func pollDrops(login string) error {
+	previous, err := watch.LoadState(watchStatePath)
+	if err != nil {
+		return err
+	}
+
+	current := watch.State{}
+	for _, entity := range append([]string{login}, dirState.GetActiveMemberTeams()...) {
+		names, err := storage.Walk(appCtx, storageClient, entity)
+		if err != nil {
+			return err
+		}
+		current[entity] = names
+	}
+
+	for _, a := range watch.Arrivals(previous, current) {
+		if a.Entity == login {
+			fmt.Printf("%s: new secret %s\n", time.Now().Format(timeFormat), a.Name)
+		} else {
+			fmt.Printf("%s: new secret %s for team %s\n", time.Now().Format(timeFormat), a.Name, a.Entity)
+		}
+		if watchHook != "" {
+			if err := watch.RunHook(watchHook, a); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+		}
+	}
+
+	if err := current.Save(watchStatePath); err != nil {
+		return err
+	}
+	return notifyRetrieved(login)
+}
@@ -0,0 +1,263 @@
+// Package update downloads psst releases published by the releaser, verifies them and replaces the
+// running binary.
+package update
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dollarshaveclub/psst/internal/version"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	// ChecksumsAsset is the release asset listing the SHA-256 sum of every other asset
+	ChecksumsAsset = "SHA256SUMS"
+	// SignatureAsset is the release asset holding a base64 ed25519 signature of ChecksumsAsset
+	SignatureAsset = ChecksumsAsset + ".sig"
+
+	// Channels releases can be picked from
+	ChannelStable     = "stable"
+	ChannelPrerelease = "prerelease"
+
+	downloadTimeout = 5 * time.Minute
+	maxAssetSize    = 200 << 20
+)
+
+// PublicKey is the base64 ed25519 key releases are signed with, set with -ldflags. When it is set
+// every release must carry a valid signature, when it isn't signed releases are refused since their
+// signature can't be checked.
+var PublicKey = ""
+
+// Updater finds and downloads releases for a platform
+type Updater struct {
+	Client      *http.Client
+	ReleasesURL string
+	Token       string
+	GOOS        string
+	GOARCH      string
+	PublicKey   string
+}
+
+// Find returns the newest release on channel
+func (u *Updater) Find(channel string) (*version.Release, error) {
+	switch channel {
+	case ChannelStable:
+		return version.Latest(u.Client, u.ReleasesURL+"/latest", u.Token)
+	case ChannelPrerelease:
+		return version.LatestPrerelease(u.Client, u.ReleasesURL, u.Token)
+	}
+	return nil, fmt.Errorf("unknown channel '%s', use %s or %s", channel, ChannelStable, ChannelPrerelease)
+}
+
+// Asset returns the release asset containing the binary for the updater's platform
+func (u *Updater) Asset(release *version.Release) (*version.Asset, error) {
+	for i, a := range release.Assets {
+		switch u.GOOS {
+		case "linux":
+			if a.Name == fmt.Sprintf("psst-linux-%s.gz", u.GOARCH) {
+				return &release.Assets[i], nil
+			}
+		case "darwin":
+			// Bottles for every macOS version contain the same amd64 binary
+			if u.GOARCH == "amd64" && strings.Contains(a.Name, ".bottle.") && strings.HasSuffix(a.Name, ".tar.gz") {
+				return &release.Assets[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("release %s has no binary for %s/%s", release.TagName, u.GOOS, u.GOARCH)
+}
+
+// Download fetches the binary for the updater's platform from release after verifying its checksum
+// and the signature of the checksums
+func (u *Updater) Download(release *version.Release) ([]byte, error) {
+	asset, err := u.Asset(release)
+	if err != nil {
+		return nil, err
+	}
+
+	sums, err := u.checksums(release)
+	if err != nil {
+		return nil, err
+	}
+	want, ok := sums[asset.Name]
+	if !ok {
+		return nil, fmt.Errorf("%s does not list a checksum for %s", ChecksumsAsset, asset.Name)
+	}
+
+	data, err := u.get(asset.DownloadURL)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("unable to download %s", asset.Name))
+	}
+	got := sha256.Sum256(data)
+	if hex.EncodeToString(got[:]) != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %x, expected %s", asset.Name, got, want)
+	}
+
+	return extract(asset.Name, data)
+}
+
+// checksums downloads and parses the checksums file, verifying its signature when the release is
+// signed or a key is set
+func (u *Updater) checksums(release *version.Release) (map[string]string, error) {
+	assets := make(map[string]version.Asset)
+	for _, a := range release.Assets {
+		assets[a.Name] = a
+	}
+
+	sumsAsset, ok := assets[ChecksumsAsset]
+	if !ok {
+		return nil, fmt.Errorf("release %s does not publish %s, unable to verify the download", release.TagName, ChecksumsAsset)
+	}
+	sums, err := u.get(sumsAsset.DownloadURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to download checksums")
+	}
+
+	sigAsset, signed := assets[SignatureAsset]
+	if signed && u.PublicKey == "" {
+		return nil, fmt.Errorf("release %s is signed but this build of psst has no key to verify it with, download it from the release page instead", release.TagName)
+	}
+	if u.PublicKey != "" {
+		if !signed {
+			return nil, fmt.Errorf("release %s is not signed", release.TagName)
+		}
+		sig, err := u.get(sigAsset.DownloadURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to download signature")
+		}
+		if err := Verify(u.PublicKey, sums, sig); err != nil {
+			return nil, err
+		}
+	}
+
+	return parseChecksums(sums), nil
+}
+
+// Verify checks a base64 ed25519 signature of data against a base64 public key
+func Verify(publicKey string, data, signature []byte) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid release public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return errors.Wrap(err, "unable to decode signature")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return errors.New("release signature is invalid")
+	}
+	return nil
+}
+
+// parseChecksums reads the "<sha256>  <name>" lines written by sha256sum
+func parseChecksums(data []byte) map[string]string {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums
+}
+
+func (u *Updater) get(url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxAssetSize))
+}
+
+// extract returns the psst binary from a gzipped binary or from a Homebrew bottle
+func extract(name string, data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("unable to decompress %s", name))
+	}
+	defer gz.Close()
+
+	if !strings.HasSuffix(name, ".tar.gz") {
+		return ioutil.ReadAll(io.LimitReader(gz, maxAssetSize))
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("unable to read %s", name))
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == "psst" && path.Base(path.Dir(hdr.Name)) == "bin" {
+			return ioutil.ReadAll(io.LimitReader(tr, maxAssetSize))
+		}
+	}
+	return nil, fmt.Errorf("%s does not contain bin/psst", name)
+}
+
+// Replace atomically swaps the file at target with binary, keeping its permissions. The new binary is
+// written next to the target so the final rename never crosses filesystems.
+func Replace(target string, binary []byte) error {
+	info, err := os.Stat(target)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unable to stat %s", target))
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(target), ".psst-upgrade-")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary file, you may need to run with sudo")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "unable to write new binary")
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "unable to sync new binary")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "unable to close new binary")
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return errors.Wrap(err, "unable to set permissions on new binary")
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unable to replace %s", target))
+	}
+	return nil
+}
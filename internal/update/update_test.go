@@ -0,0 +1,264 @@
+package update
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dollarshaveclub/psst/internal/version"
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	testBinary = []byte("#!/bin/sh\necho new psst\n")
+	testSeed   = bytes.Repeat([]byte{7}, ed25519.SeedSize)
+)
+
+// fakeRelease serves a GitHub style release listing and its assets
+type fakeRelease struct {
+	tag        string
+	prerelease bool
+	files      map[string][]byte
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	buf := bytes.NewBuffer([]byte{})
+	gw := gzip.NewWriter(buf)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatalf("unable to compress: %v", err)
+	}
+	gw.Close()
+	return buf.Bytes()
+}
+
+func bottle(t *testing.T, data []byte) []byte {
+	buf := bytes.NewBuffer([]byte{})
+	tw := tar.NewWriter(buf)
+	for name, d := range map[string][]byte{"psst/1.2.0/README.md": []byte("readme"), "psst/1.2.0/bin/psst": data} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(d)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("unable to write header: %v", err)
+		}
+		tw.Write(d)
+	}
+	tw.Close()
+	return gzipped(t, buf.Bytes())
+}
+
+// newFakeRelease builds the linux and darwin assets along with signed checksums
+func newFakeRelease(t *testing.T, tag string, prerelease bool) *fakeRelease {
+	files := map[string][]byte{
+		"psst-linux-amd64.gz":                    gzipped(t, testBinary),
+		"psst-1.2.0.high_sierra.bottle.1.tar.gz": bottle(t, testBinary),
+		"psst-1.2.0.el_capitan.bottle.1.tar.gz":  bottle(t, testBinary),
+	}
+	sums := bytes.NewBuffer([]byte{})
+	for name, d := range files {
+		fmt.Fprintf(sums, "%x  %s\n", sha256.Sum256(d), name)
+	}
+	files[ChecksumsAsset] = sums.Bytes()
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(testSeed), sums.Bytes())
+	files[SignatureAsset] = []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+	return &fakeRelease{tag: tag, prerelease: prerelease, files: files}
+}
+
+func serve(t *testing.T, releases ...*fakeRelease) *httptest.Server {
+	mux := http.NewServeMux()
+	var ts *httptest.Server
+	listing := func() []version.Release {
+		out := []version.Release{}
+		for _, r := range releases {
+			rel := version.Release{TagName: r.tag, Prerelease: r.prerelease}
+			for name := range r.files {
+				rel.Assets = append(rel.Assets, version.Asset{Name: name, DownloadURL: ts.URL + "/download/" + r.tag + "/" + name})
+			}
+			out = append(out, rel)
+		}
+		return out
+	}
+	mux.HandleFunc("/releases", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(listing())
+	})
+	mux.HandleFunc("/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		for _, rel := range listing() {
+			if !rel.Prerelease {
+				json.NewEncoder(w).Encode(rel)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) {
+		for _, rel := range releases {
+			prefix := "/download/" + rel.tag + "/"
+			if len(r.URL.Path) > len(prefix) && r.URL.Path[:len(prefix)] == prefix {
+				if d, ok := rel.files[r.URL.Path[len(prefix):]]; ok {
+					w.Write(d)
+					return
+				}
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	ts = httptest.NewServer(mux)
+	return ts
+}
+
+func publicKey() string {
+	return base64.StdEncoding.EncodeToString(ed25519.NewKeyFromSeed(testSeed).Public().(ed25519.PublicKey))
+}
+
+func TestFind(t *testing.T) {
+	ts := serve(t, newFakeRelease(t, "v1.2.0", false), newFakeRelease(t, "v1.3.0-rc1", true))
+	defer ts.Close()
+
+	cases := map[string]struct {
+		Channel  string
+		Expected string
+		Err      bool
+	}{
+		"StableTest":     {Channel: ChannelStable, Expected: "v1.2.0"},
+		"PrereleaseTest": {Channel: ChannelPrerelease, Expected: "v1.3.0-rc1"},
+		"UnknownTest":    {Channel: "nightly", Err: true},
+	}
+
+	u := &Updater{Client: ts.Client(), ReleasesURL: ts.URL + "/releases"}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			release, err := u.Find(c.Channel)
+			if c.Err {
+				if err == nil {
+					t.Errorf("Name: %s, expected an error", name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Name: %s, unexpected error: %v", name, err)
+			}
+			if release.TagName != c.Expected {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, release.TagName, c.Expected)
+			}
+		})
+	}
+}
+
+func TestDownload(t *testing.T) {
+	good := newFakeRelease(t, "v1.2.0", false)
+
+	tampered := newFakeRelease(t, "v1.2.1", false)
+	tampered.files["psst-linux-amd64.gz"] = gzipped(t, []byte("evil"))
+
+	badSig := newFakeRelease(t, "v1.2.2", false)
+	badSig.files[ChecksumsAsset] = append(badSig.files[ChecksumsAsset], []byte("00  extra\n")...)
+
+	unsigned := newFakeRelease(t, "v1.2.3", false)
+	delete(unsigned.files, SignatureAsset)
+
+	noSums := newFakeRelease(t, "v1.2.4", false)
+	delete(noSums.files, ChecksumsAsset)
+
+	ts := serve(t, good, tampered, badSig, unsigned, noSums)
+	defer ts.Close()
+
+	releases := map[string]version.Release{}
+	if err := getReleases(ts, releases); err != nil {
+		t.Fatalf("unable to list releases: %v", err)
+	}
+
+	cases := map[string]struct {
+		Tag       string
+		GOOS      string
+		GOARCH    string
+		PublicKey string
+		Err       bool
+	}{
+		"LinuxTest":         {Tag: "v1.2.0", GOOS: "linux", GOARCH: "amd64", PublicKey: publicKey()},
+		"DarwinTest":        {Tag: "v1.2.0", GOOS: "darwin", GOARCH: "amd64", PublicKey: publicKey()},
+		"UnsupportedTest":   {Tag: "v1.2.0", GOOS: "windows", GOARCH: "amd64", Err: true},
+		"ChecksumTest":      {Tag: "v1.2.1", GOOS: "linux", GOARCH: "amd64", Err: true},
+		"BadSignatureTest":  {Tag: "v1.2.2", GOOS: "linux", GOARCH: "amd64", PublicKey: publicKey(), Err: true},
+		"UnsignedTest":      {Tag: "v1.2.3", GOOS: "linux", GOARCH: "amd64", PublicKey: publicKey(), Err: true},
+		"UnsignedNoKeyTest": {Tag: "v1.2.3", GOOS: "linux", GOARCH: "amd64"},
+		"SignedNoKeyTest":   {Tag: "v1.2.0", GOOS: "linux", GOARCH: "amd64", Err: true},
+		"MissingSumsTest":   {Tag: "v1.2.4", GOOS: "linux", GOARCH: "amd64", Err: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			u := &Updater{Client: ts.Client(), GOOS: c.GOOS, GOARCH: c.GOARCH, PublicKey: c.PublicKey}
+			release := releases[c.Tag]
+			binary, err := u.Download(&release)
+			if c.Err {
+				if err == nil {
+					t.Errorf("Name: %s, expected an error", name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Name: %s, unexpected error: %v", name, err)
+			}
+			if !bytes.Equal(binary, testBinary) {
+				t.Errorf("Name: %s, got: %q, expected: %q", name, binary, testBinary)
+			}
+		})
+	}
+}
+
+func getReleases(ts *httptest.Server, releases map[string]version.Release) error {
+	resp, err := ts.Client().Get(ts.URL + "/releases")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	list := []version.Release{}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return err
+	}
+	for _, r := range list {
+		releases[r.TagName] = r
+	}
+	return nil
+}
+
+func TestReplace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-update")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "psst")
+	if err := ioutil.WriteFile(target, []byte("old"), 0751); err != nil {
+		t.Fatalf("unable to write binary: %v", err)
+	}
+
+	if err := Replace(target, testBinary); err != nil {
+		t.Fatalf("unable to replace binary: %v", err)
+	}
+	got, _ := ioutil.ReadFile(target)
+	if !bytes.Equal(got, testBinary) {
+		t.Errorf("got: %q, expected: %q", got, testBinary)
+	}
+	info, _ := os.Stat(target)
+	if info.Mode().Perm() != 0751 {
+		t.Errorf("got: %v, expected: %v", info.Mode().Perm(), os.FileMode(0751))
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("got: %d files, expected the temporary file to be removed", len(files))
+	}
+
+	if err := Replace(filepath.Join(dir, "missing"), testBinary); err == nil {
+		t.Errorf("expected an error replacing a missing binary")
+	}
+}
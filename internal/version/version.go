@@ -0,0 +1,170 @@
+// Package version holds the build information for psst. Version and CommitSHA are set at build time
+// with -ldflags and fall back to what the Go toolchain embedded in the binary.
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	goversion "github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ReleasesURL is the GitHub API endpoint for psst releases
+	ReleasesURL = "https://api.github.com/repos/dollarshaveclub/psst/releases"
+	// LatestReleaseURL is the GitHub API endpoint for the latest stable psst release
+	LatestReleaseURL = ReleasesURL + "/latest"
+
+	unknown      = "unknown"
+	checkTimeout = 5 * time.Second
+)
+
+var (
+	// Version is the release tag, set with -ldflags
+	Version = ""
+	// CommitSHA is the commit the binary was built from, set with -ldflags
+	CommitSHA = ""
+)
+
+// Info describes the running binary
+type Info struct {
+	Version   string
+	CommitSHA string
+	Modified  bool
+	GoVersion string
+	Platform  string
+}
+
+// Get returns the build information, preferring the values set with -ldflags
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		CommitSHA: CommitSHA,
+		GoVersion: runtime.Version(),
+		Platform:  fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.CommitSHA == "" {
+					info.CommitSHA = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = unknown
+	}
+	if info.CommitSHA == "" {
+		info.CommitSHA = unknown
+	}
+	return info
+}
+
+// Release is the subset of a GitHub release psst cares about
+type Release struct {
+	TagName    string  `json:"tag_name"`
+	HTMLURL    string  `json:"html_url"`
+	Prerelease bool    `json:"prerelease"`
+	Draft      bool    `json:"draft"`
+	Assets     []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+}
+
+// Latest fetches the latest published release from url. A GitHub token is used if provided to avoid
+// rate limits.
+func Latest(client *http.Client, url, token string) (*Release, error) {
+	release := &Release{}
+	if err := getJSON(client, url, token, release); err != nil {
+		return nil, errors.Wrap(err, "unable to get latest release")
+	}
+	return release, nil
+}
+
+// LatestPrerelease returns the newest release, including prereleases, from the list of releases
+// at url. Drafts and tags that aren't versions are skipped.
+func LatestPrerelease(client *http.Client, url, token string) (*Release, error) {
+	releases := []Release{}
+	if err := getJSON(client, url, token, &releases); err != nil {
+		return nil, errors.Wrap(err, "unable to list releases")
+	}
+
+	var newest *Release
+	for i, r := range releases {
+		if r.Draft {
+			continue
+		}
+		if _, err := goversion.NewVersion(r.TagName); err != nil {
+			continue
+		}
+		if newest == nil || Newer(newest.TagName, r.TagName) {
+			newest = &releases[i]
+		}
+	}
+	if newest == nil {
+		return nil, errors.New("no releases found")
+	}
+	return newest, nil
+}
+
+func getJSON(client *http.Client, url, token string, v interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to create request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrap(err, "unable to decode response")
+	}
+	return nil
+}
+
+// Newer checks if latest is a newer version than current. Versions that can't be parsed, such as
+// development builds, are never considered out of date.
+func Newer(current, latest string) bool {
+	c, err := goversion.NewVersion(current)
+	if err != nil {
+		return false
+	}
+	l, err := goversion.NewVersion(latest)
+	if err != nil {
+		return false
+	}
+	return l.GreaterThan(c)
+}
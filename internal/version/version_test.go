@@ -0,0 +1,92 @@
+package version
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	oldVersion, oldCommit := Version, CommitSHA
+	defer func() { Version, CommitSHA = oldVersion, oldCommit }()
+
+	Version, CommitSHA = "v1.2.3", "abc123"
+	info := Get()
+	if info.Version != "v1.2.3" || info.CommitSHA != "abc123" {
+		t.Errorf("got: %+v, expected ldflags values", info)
+	}
+
+	Version, CommitSHA = "", ""
+	info = Get()
+	if info.Version == "" || info.CommitSHA == "" {
+		t.Errorf("got: %+v, expected fallback values", info)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	cases := map[string]struct {
+		Current  string
+		Latest   string
+		Expected bool
+	}{
+		"NewerTest":       {Current: "v1.0.0", Latest: "v1.1.0", Expected: true},
+		"SameTest":        {Current: "v1.1.0", Latest: "v1.1.0", Expected: false},
+		"OlderTest":       {Current: "v1.2.0", Latest: "v1.1.0", Expected: false},
+		"PrereleaseTest":  {Current: "v1.1.0-rc1", Latest: "v1.1.0", Expected: true},
+		"UnknownTest":     {Current: "unknown", Latest: "v1.1.0", Expected: false},
+		"BadReleaseTest":  {Current: "v1.0.0", Latest: "latest", Expected: false},
+		"NoVPrefixedTest": {Current: "1.0.0", Latest: "v1.0.1", Expected: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := Newer(c.Current, c.Latest); got != c.Expected {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestLatest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"tag_name": "v1.4.0", "html_url": "https://example.com/v1.4.0"}`)
+	}))
+	defer ts.Close()
+
+	release, err := Latest(ts.Client(), ts.URL, "secret")
+	if err != nil {
+		t.Fatalf("unable to get latest release: %v", err)
+	}
+	if release.TagName != "v1.4.0" || release.HTMLURL != "https://example.com/v1.4.0" {
+		t.Errorf("got: %+v", release)
+	}
+
+	if _, err := Latest(ts.Client(), ts.URL, ""); err == nil {
+		t.Errorf("expected an error for a failed request")
+	}
+}
+
+func TestLatestPrerelease(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.3.0"},
+			{"tag_name": "v1.5.0", "draft": true},
+			{"tag_name": "v1.4.0-rc1", "prerelease": true},
+			{"tag_name": "nightly"}
+		]`)
+	}))
+	defer ts.Close()
+
+	release, err := LatestPrerelease(ts.Client(), ts.URL, "")
+	if err != nil {
+		t.Fatalf("unable to get latest prerelease: %v", err)
+	}
+	if release.TagName != "v1.4.0-rc1" {
+		t.Errorf("got: %s, expected: v1.4.0-rc1", release.TagName)
+	}
+}
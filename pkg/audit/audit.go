@@ -0,0 +1,407 @@
+// Package audit records who shared what with whom. Every event holds the hash of the one before it,
+// so editing or removing an event breaks the chain. With an audit key the hashes are HMACs, so only
+// someone who can read the key can rebuild the chain after editing it; without one any writer of a
+// sink can. Only a Vault sink whose policy allows creating but not updating events can't be
+// rewritten by members who hold the key. Sinks
+// are described by a spec such as "file:~/.psst/audit.jsonl", "syslog:psst" or
+// "vault:secret/psst-audit".
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	filePerms = 0600
+	dirPerms  = 0700
+
+	defaultTag = "psst"
+	sumPrefix  = "sha256:"
+	hashPrefix = "hmac-sha256:"
+
+	// recordAttempts is how many times a Vault sink tries to add an event when other runs of psst
+	// take the key first
+	recordAttempts = 5
+)
+
+// ErrBroken is returned when verifying a chain that was tampered with
+var ErrBroken = errors.New("audit chain is broken")
+
+// Action is an operation recorded in the audit trail
+type Action string
+
+// Recorded actions
+const (
+	Share    Action = "share"
+	Get      Action = "get"
+	Delete   Action = "delete"
+	Forward  Action = "forward"
+	Generate Action = "generate"
+)
+
+// Event is a single psst operation. The value of a secret is never recorded, only its hash.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action Action    `json:"action"`
+	Host   string    `json:"host,omitempty"`
+	Secret string    `json:"secret,omitempty"`
+	// Targets are the drops a secret was shared to, read from or deleted in, or the policies and
+	// mappings changed by generate
+	Targets   []string `json:"targets,omitempty"`
+	ValueHash string   `json:"value_hash,omitempty"`
+	Detail    string   `json:"detail,omitempty"`
+	// Prev is the hash of the event before this one in the same chain, empty for the first event
+	Prev string `json:"prev"`
+	// Hash covers every other field, Prev included. It's an HMAC with the audit key when there is
+	// one.
+	Hash string `json:"hash"`
+}
+
+// HashValue returns the hash recorded for a secret value, an HMAC with key so that values can't be
+// guessed from the audit trail without the key. Without a key nothing is recorded.
+func HashValue(key []byte, value string) string {
+	if len(key) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sum returns the hash of the event, ignoring the current Hash. With a key it's an HMAC that can't be
+// recomputed without the key, otherwise a plain SHA-256.
+func (e Event) Sum(key []byte) string {
+	e.Hash = ""
+	e.Time = e.Time.UTC()
+	buf, _ := json.Marshal(e)
+	if len(key) == 0 {
+		sum := sha256.Sum256(buf)
+		return sumPrefix + hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(buf)
+	return hashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Chain returns e following the event with hash prev, hashed with key
+func (e Event) Chain(key []byte, prev string) Event {
+	e.Time = e.Time.UTC()
+	e.Prev = prev
+	e.Hash = e.Sum(key)
+	return e
+}
+
+// Verify checks that events form an unbroken chain from the first event on, hashed with key. Events
+// recorded without the key, or with another one, are reported as modified. Events removed from the
+// end of a chain, or a chain rebuilt from scratch by someone holding the key, can't be detected, keep
+// a Vault sink to catch that.
+func Verify(events []Event, key []byte) error {
+	prev := ""
+	for i, e := range events {
+		if e.Prev != prev {
+			return fmt.Errorf("%w: event %d (%s %s at %s) doesn't follow the event before it", ErrBroken, i+1, e.Actor, e.Action, e.Time.Format(time.RFC3339))
+		}
+		if !hmac.Equal([]byte(e.Sum(key)), []byte(e.Hash)) {
+			return fmt.Errorf("%w: event %d (%s %s at %s) was modified", ErrBroken, i+1, e.Actor, e.Action, e.Time.Format(time.RFC3339))
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// Sink stores audit events
+type Sink interface {
+	Record(context.Context, Event) error
+}
+
+// Reader is a sink that can read its events back, oldest first, so the chain can be verified
+type Reader interface {
+	Events(context.Context) ([]Event, error)
+}
+
+// Store keeps the events of a Vault sink, one per path, and the key value hashes are made with
+type Store interface {
+	ListAudit(context.Context, string) ([]string, error)
+	ReadAudit(context.Context, string) (string, error)
+	WriteAudit(context.Context, string, string) error
+	ReadAuditKey(context.Context, string) (string, error)
+}
+
+// Parse returns the sink for spec. File and Vault sinks chain events with key, Vault sinks keep a
+// chain per actor in store.
+func Parse(spec string, store Store, actor string, key []byte) (Sink, error) {
+	switch {
+	case strings.HasPrefix(spec, "file:"):
+		p := strings.TrimPrefix(spec, "file:")
+		if strings.HasPrefix(p, "~/") {
+			p = filepath.Join(os.Getenv("HOME"), p[2:])
+		}
+		if p == "" {
+			return nil, fmt.Errorf("no path in '%s'", spec)
+		}
+		return &File{Path: p, Key: key}, nil
+	case spec == "syslog" || strings.HasPrefix(spec, "syslog:"):
+		tag := strings.TrimPrefix(strings.TrimPrefix(spec, "syslog"), ":")
+		if tag == "" {
+			tag = defaultTag
+		}
+		return &Syslog{Tag: tag}, nil
+	case strings.HasPrefix(spec, "vault:"):
+		p := strings.Trim(strings.TrimPrefix(spec, "vault:"), "/")
+		if p == "" {
+			return nil, fmt.Errorf("no path in '%s'", spec)
+		}
+		if store == nil {
+			return nil, fmt.Errorf("'%s' needs the Vault storage backend", spec)
+		}
+		if actor == "" {
+			return nil, fmt.Errorf("'%s' needs to know who you are", spec)
+		}
+		return &Vault{Store: store, Path: p, Actor: actor, Key: key}, nil
+	}
+	return nil, fmt.Errorf("unknown audit sink '%s', use file:<path>, syslog[:<tag>] or vault:<path>", spec)
+}
+
+// Config lists the sinks every event is recorded to
+type Config struct {
+	// Sinks are sink specs, psst picks a local file when there are none and records nothing when
+	// the list is empty
+	Sinks []string `json:"sinks"`
+	// Key is the path in Vault of the key value hashes are made with. Keeping it out of the audit
+	// trail means someone reading the trail can't guess values from their hashes, or rebuild the
+	// chain after editing it. Without it, no value hashes are recorded and the chain is a plain
+	// SHA-256.
+	Key string `json:"key,omitempty"`
+}
+
+// LoadKey reads the key of conf from store, returning nil when conf has none
+func LoadKey(ctx context.Context, conf Config, store Store) ([]byte, error) {
+	if conf.Key == "" {
+		return nil, nil
+	}
+	if store == nil {
+		return nil, fmt.Errorf("the audit key at %s needs the Vault storage backend", conf.Key)
+	}
+	key, err := store.ReadAuditKey(ctx, strings.Trim(conf.Key, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read audit key: %w", err)
+	}
+	return []byte(key), nil
+}
+
+// LoadConfig reads the audit section of the psst config file. A missing file is the same as an
+// empty config.
+func LoadConfig(filename string) (Config, error) {
+	var conf struct {
+		Audit Config `json:"audit"`
+	}
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to read config: %+v", err)
+	}
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return Config{}, fmt.Errorf("unable to unmarshal config %s: %+v", filename, err)
+	}
+	return conf.Audit, nil
+}
+
+// File appends events as JSON lines to a local file readable only by the current user
+type File struct {
+	Path string
+	Key  []byte
+}
+
+// Record implements Sink. The file is locked while the last event is read and the new one appended
+// so concurrent runs of psst don't fork the chain.
+func (f *File) Record(ctx context.Context, e Event) error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), dirPerms); err != nil {
+		return fmt.Errorf("unable to create audit directory: %+v", err)
+	}
+	file, err := os.OpenFile(f.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, filePerms)
+	if err != nil {
+		return fmt.Errorf("unable to open audit log: %+v", err)
+	}
+	defer file.Close()
+	if err := lockFile(file); err != nil {
+		return fmt.Errorf("unable to lock audit log: %+v", err)
+	}
+	defer unlockFile(file)
+
+	events, err := readEvents(file)
+	if err != nil {
+		return err
+	}
+	prev := ""
+	if len(events) > 0 {
+		prev = events[len(events)-1].Hash
+	}
+	buf, err := json.Marshal(e.Chain(f.Key, prev))
+	if err != nil {
+		return fmt.Errorf("unable to marshal audit event: %+v", err)
+	}
+	if _, err := file.Write(append(buf, '\n')); err != nil {
+		return fmt.Errorf("unable to write audit log: %+v", err)
+	}
+	return nil
+}
+
+// Events implements Reader
+func (f *File) Events(ctx context.Context) ([]Event, error) {
+	file, err := os.Open(f.Path)
+	if os.IsNotExist(err) {
+		return []Event{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %+v", err)
+	}
+	defer file.Close()
+	return readEvents(file)
+}
+
+// readEvents reads one event per line
+func readEvents(r io.Reader) ([]Event, error) {
+	events := []Event{}
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e Event
+			if err := json.Unmarshal(line, &e); err != nil {
+				return nil, fmt.Errorf("%w: line %d isn't an audit event: %v", ErrBroken, n, err)
+			}
+			events = append(events, e)
+		}
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read audit log: %+v", err)
+		}
+	}
+}
+
+// Syslog sends events as JSON to the local syslog daemon with the auth facility, for shipping to a
+// central log. Syslog can't be read back, so its events aren't chained. There's no syslog on
+// Windows, recording there fails.
+type Syslog struct {
+	Tag string
+}
+
+// Vault keeps the chain of Actor under Path in the storage backend, one numbered event per key. With
+// a policy granting only create, read and list there, events can be added but never changed.
+type Vault struct {
+	Store Store
+	Path  string
+	Actor string
+	Key   []byte
+}
+
+func (v *Vault) chainPath() string {
+	return path.Join(v.Path, v.Actor)
+}
+
+// keys returns the keys of the chain in order
+func (v *Vault) keys(ctx context.Context) ([]string, error) {
+	keys, err := v.Store.ListAudit(ctx, v.chainPath())
+	if err != nil {
+		return nil, fmt.Errorf("unable to list audit events: %w", err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (v *Vault) read(ctx context.Context, key string) (Event, error) {
+	var e Event
+	raw, err := v.Store.ReadAudit(ctx, path.Join(v.chainPath(), key))
+	if err != nil {
+		return e, fmt.Errorf("unable to read audit event %s: %w", key, err)
+	}
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		return e, fmt.Errorf("%w: %s isn't an audit event: %v", ErrBroken, key, err)
+	}
+	return e, nil
+}
+
+// Record implements Sink. Concurrent runs of psst pick the same key for their event, the create only
+// policy lets one of them write it and the others chain their event after it instead.
+func (v *Vault) Record(ctx context.Context, e Event) error {
+	for attempt := 1; ; attempt++ {
+		key, err := v.record(ctx, e)
+		if err == nil {
+			return nil
+		}
+		if attempt == recordAttempts {
+			return err
+		}
+		// Anything but the key being taken by someone else is a real failure
+		if key == "" {
+			return err
+		}
+		if _, readErr := v.Store.ReadAudit(ctx, path.Join(v.chainPath(), key)); readErr != nil {
+			return err
+		}
+	}
+}
+
+// record writes e after the last event of the chain, returning the key it tried
+func (v *Vault) record(ctx context.Context, e Event) (string, error) {
+	keys, err := v.keys(ctx)
+	if err != nil {
+		return "", err
+	}
+	prev := ""
+	if len(keys) > 0 {
+		last, err := v.read(ctx, keys[len(keys)-1])
+		if err != nil {
+			return "", err
+		}
+		prev = last.Hash
+	}
+	buf, err := json.Marshal(e.Chain(v.Key, prev))
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal audit event: %+v", err)
+	}
+	// Zero padded so the keys list in order
+	key := fmt.Sprintf("%010d", len(keys))
+	if err := v.Store.WriteAudit(ctx, path.Join(v.chainPath(), key), string(buf)); err != nil {
+		return key, fmt.Errorf("unable to write audit event: %w", err)
+	}
+	return key, nil
+}
+
+// Events implements Reader
+func (v *Vault) Events(ctx context.Context) ([]Event, error) {
+	keys, err := v.keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	events := []Event{}
+	for _, k := range keys {
+		e, err := v.read(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
@@ -0,0 +1,370 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chain returns n events chained with key
+func chain(key []byte, n int) []Event {
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	events := []Event{}
+	prev := ""
+	for i := 0; i < n; i++ {
+		e := Event{Time: start.Add(time.Duration(i) * time.Minute), Actor: "alice", Action: Share, Secret: "db", Targets: []string{"bob"}, ValueHash: HashValue([]byte("key"), "hunter2")}.Chain(key, prev)
+		events = append(events, e)
+		prev = e.Hash
+	}
+	return events
+}
+
+func TestVerify(t *testing.T) {
+	key := []byte("key")
+	modified := chain(key, 3)
+	modified[1].Targets = []string{"mallory"}
+	resealed := chain(key, 3)
+	resealed[1].Targets = []string{"mallory"}
+	resealed[1] = resealed[1].Chain(key, resealed[1].Prev)
+	removed := chain(key, 3)
+	removed = append(removed[:1], removed[2:]...)
+	swapped := chain(key, 3)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+	// Rebuilt without the key, editing an event and hashing every one after it again
+	rebuilt := chain(nil, 3)
+	rebuilt[1].Targets = []string{"mallory"}
+	prev := ""
+	for i := range rebuilt {
+		rebuilt[i] = rebuilt[i].Chain([]byte("guess"), prev)
+		prev = rebuilt[i].Hash
+	}
+
+	cases := map[string]struct {
+		Events []Event
+		Key    []byte
+		Broken bool
+	}{
+		"EmptyTest":    {Events: []Event{}, Key: key},
+		"IntactTest":   {Events: chain(key, 3), Key: key},
+		"UnkeyedTest":  {Events: chain(nil, 3)},
+		"ModifiedTest": {Events: modified, Key: key, Broken: true},
+		"ResealedTest": {Events: resealed, Key: key, Broken: true},
+		"RemovedTest":  {Events: removed, Key: key, Broken: true},
+		"SwappedTest":  {Events: swapped, Key: key, Broken: true},
+		"RebuiltTest":  {Events: rebuilt, Key: key, Broken: true},
+		"NoKeyTest":    {Events: chain(nil, 3), Key: key, Broken: true},
+		"WrongKeyTest": {Events: chain(key, 3), Key: []byte("other"), Broken: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := Verify(c.Events, c.Key)
+			if got := errors.Is(err, ErrBroken); got != c.Broken {
+				t.Errorf("Name: %s, got: %v, expected broken: %v", name, err, c.Broken)
+			}
+		})
+	}
+}
+
+func TestHashValue(t *testing.T) {
+	key := []byte("key")
+	h := HashValue(key, "hunter2")
+	if !strings.HasPrefix(h, hashPrefix) || strings.Contains(h, "hunter2") {
+		t.Errorf("got: %s, expected an hmac without the value", h)
+	}
+	if h != HashValue(key, "hunter2") {
+		t.Errorf("expected the same value to hash the same")
+	}
+	if h == HashValue(key, "hunter3") {
+		t.Errorf("expected different values to hash differently")
+	}
+	if h == HashValue([]byte("other"), "hunter2") {
+		t.Errorf("expected different keys to hash differently")
+	}
+	if h := HashValue(nil, "hunter2"); h != "" {
+		t.Errorf("got: %s, expected no hash without a key", h)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	store := &memStore{keys: map[string]string{"secret/psst-audit-key": "key"}}
+
+	cases := map[string]struct {
+		Config   Config
+		Store    Store
+		Expected []byte
+		Err      bool
+	}{
+		"NoKeyTest":   {Store: store},
+		"KeyTest":     {Config: Config{Key: "/secret/psst-audit-key"}, Store: store, Expected: []byte("key")},
+		"MissingTest": {Config: Config{Key: "secret/other"}, Store: store, Err: true},
+		"NoStoreTest": {Config: Config{Key: "secret/psst-audit-key"}, Err: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := LoadKey(context.Background(), c.Config, c.Store)
+			if (err != nil) != c.Err || !reflect.DeepEqual(got, c.Expected) {
+				t.Errorf("Name: %s, got: %q %v, expected: %q %v", name, got, err, c.Expected, c.Err)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Setenv("HOME", "/home/alice")
+	store := &memStore{}
+	key := []byte("key")
+
+	cases := map[string]struct {
+		Spec     string
+		Store    Store
+		Expected Sink
+		Err      bool
+	}{
+		"FileTest":       {Spec: "file:/var/log/psst.jsonl", Expected: &File{Path: "/var/log/psst.jsonl", Key: key}},
+		"HomeTest":       {Spec: "file:~/.psst/audit.jsonl", Expected: &File{Path: "/home/alice/.psst/audit.jsonl", Key: key}},
+		"SyslogTest":     {Spec: "syslog", Expected: &Syslog{Tag: "psst"}},
+		"SyslogTagTest":  {Spec: "syslog:secrets", Expected: &Syslog{Tag: "secrets"}},
+		"VaultTest":      {Spec: "vault:/secret/psst-audit/", Store: store, Expected: &Vault{Store: store, Path: "secret/psst-audit", Actor: "alice", Key: key}},
+		"NoStoreTest":    {Spec: "vault:secret/psst-audit", Err: true},
+		"EmptyFileTest":  {Spec: "file:", Err: true},
+		"EmptyVaultTest": {Spec: "vault:", Store: store, Err: true},
+		"UnknownTest":    {Spec: "s3://bucket", Err: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(c.Spec, c.Store, "alice", key)
+			if (err != nil) != c.Err {
+				t.Fatalf("Name: %s, got error: %v, expected error: %v", name, err, c.Err)
+			}
+			if !reflect.DeepEqual(got, c.Expected) {
+				t.Errorf("Name: %s, got: %#v, expected: %#v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-audit-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	f := &File{Path: filepath.Join(dir, "psst", "audit.jsonl"), Key: []byte("key")}
+	ctx := context.Background()
+
+	events, err := f.Events(ctx)
+	if err != nil || len(events) != 0 {
+		t.Fatalf("got: %v %v, expected no events before anything was recorded", events, err)
+	}
+
+	for _, a := range []Action{Share, Get, Delete} {
+		if err := f.Record(ctx, Event{Time: time.Now(), Actor: "alice", Action: a, Secret: "db"}); err != nil {
+			t.Fatalf("unable to record event: %v", err)
+		}
+	}
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		t.Fatalf("unable to stat audit log: %v", err)
+	}
+	if info.Mode().Perm() != filePerms {
+		t.Errorf("got mode: %v, expected: %v", info.Mode().Perm(), os.FileMode(filePerms))
+	}
+
+	events, err = f.Events(ctx)
+	if err != nil {
+		t.Fatalf("unable to read events: %v", err)
+	}
+	if len(events) != 3 || events[2].Action != Delete {
+		t.Fatalf("got: %+v, expected share, get and delete", events)
+	}
+	if err := Verify(events, f.Key); err != nil {
+		t.Errorf("got: %v, expected an intact chain", err)
+	}
+
+	buf, _ := ioutil.ReadFile(f.Path)
+	tampered := strings.Replace(string(buf), `"action":"get"`, `"action":"list"`, 1)
+	if err := ioutil.WriteFile(f.Path, []byte(tampered), filePerms); err != nil {
+		t.Fatalf("unable to tamper with audit log: %v", err)
+	}
+	events, err = f.Events(ctx)
+	if err == nil {
+		err = Verify(events, f.Key)
+	}
+	if !errors.Is(err, ErrBroken) {
+		t.Errorf("got: %v, expected: %v", err, ErrBroken)
+	}
+}
+
+// memStore keeps audit events in memory and, like a create only Vault policy, refuses overwrites
+type memStore struct {
+	events map[string]string
+	keys   map[string]string
+}
+
+func (m *memStore) ListAudit(ctx context.Context, p string) ([]string, error) {
+	keys := []string{}
+	for k := range m.events {
+		if filepath.Dir(k) == p {
+			keys = append(keys, filepath.Base(k))
+		}
+	}
+	return keys, nil
+}
+
+func (m *memStore) ReadAudit(ctx context.Context, p string) (string, error) {
+	e, ok := m.events[p]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return e, nil
+}
+
+func (m *memStore) WriteAudit(ctx context.Context, p, event string) error {
+	if _, ok := m.events[p]; ok {
+		return errors.New("permission denied")
+	}
+	if m.events == nil {
+		m.events = map[string]string{}
+	}
+	m.events[p] = event
+	return nil
+}
+
+func (m *memStore) ReadAuditKey(ctx context.Context, p string) (string, error) {
+	k, ok := m.keys[p]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return k, nil
+}
+
+func TestVault(t *testing.T) {
+	store := &memStore{}
+	ctx := context.Background()
+	alice := &Vault{Store: store, Path: "secret/psst-audit", Actor: "alice", Key: []byte("key")}
+	bob := &Vault{Store: store, Path: "secret/psst-audit", Actor: "bob", Key: []byte("key")}
+
+	for i := 0; i < 12; i++ {
+		if err := alice.Record(ctx, Event{Time: time.Now(), Actor: "alice", Action: Share}); err != nil {
+			t.Fatalf("unable to record event: %v", err)
+		}
+	}
+	if err := bob.Record(ctx, Event{Time: time.Now(), Actor: "bob", Action: Get}); err != nil {
+		t.Fatalf("unable to record event: %v", err)
+	}
+
+	if _, ok := store.events["secret/psst-audit/alice/0000000011"]; !ok {
+		t.Errorf("got: %v, expected zero padded keys", store.events)
+	}
+
+	events, err := alice.Events(ctx)
+	if err != nil {
+		t.Fatalf("unable to read events: %v", err)
+	}
+	if len(events) != 12 {
+		t.Errorf("got: %d events, expected: 12", len(events))
+	}
+	if err := Verify(events, alice.Key); err != nil {
+		t.Errorf("got: %v, expected an intact chain", err)
+	}
+
+	events, err = bob.Events(ctx)
+	if err != nil || len(events) != 1 || events[0].Prev != "" {
+		t.Errorf("got: %+v %v, expected a separate chain for bob", events, err)
+	}
+}
+
+// racingStore records an event of another run of psst at every key right before it is written
+type racingStore struct {
+	memStore
+	races int
+}
+
+func (r *racingStore) WriteAudit(ctx context.Context, p, event string) error {
+	if r.races > 0 {
+		r.races--
+		var e Event
+		json.Unmarshal([]byte(event), &e)
+		other, _ := json.Marshal(Event{Time: e.Time, Actor: e.Actor, Action: Get}.Chain(nil, e.Prev))
+		r.memStore.WriteAudit(ctx, p, string(other))
+	}
+	return r.memStore.WriteAudit(ctx, p, event)
+}
+
+func TestVaultRace(t *testing.T) {
+	ctx := context.Background()
+	cases := map[string]struct {
+		Races  int
+		Events int
+		Err    bool
+	}{
+		"NoRaceTest": {Events: 1},
+		"RaceTest":   {Races: 2, Events: 3},
+		"GiveUpTest": {Races: recordAttempts, Events: recordAttempts, Err: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			store := &racingStore{races: c.Races}
+			v := &Vault{Store: store, Path: "secret/psst-audit", Actor: "alice"}
+			err := v.Record(ctx, Event{Time: time.Now(), Actor: "alice", Action: Share})
+			if (err != nil) != c.Err {
+				t.Fatalf("Name: %s, got error: %v, expected error: %v", name, err, c.Err)
+			}
+			events, err := v.Events(ctx)
+			if err != nil || len(events) != c.Events {
+				t.Fatalf("Name: %s, got: %d events %v, expected: %d", name, len(events), err, c.Events)
+			}
+			if err := Verify(events, nil); err != nil {
+				t.Errorf("Name: %s, got: %v, expected an intact chain", name, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-config-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := map[string]struct {
+		Config   string
+		Expected []string
+	}{
+		"MissingTest": {},
+		"NoAuditTest": {Config: `{"vault": {}}`},
+		"EmptyTest":   {Config: `{"audit": {"sinks": []}}`, Expected: []string{}},
+		"SinksTest":   {Config: `{"audit": {"sinks": ["syslog", "vault:secret/psst-audit"], "key": "secret/psst-audit-key"}}`, Expected: []string{"syslog", "vault:secret/psst-audit"}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := filepath.Join(dir, name+".json")
+			if c.Config != "" {
+				if err := ioutil.WriteFile(p, []byte(c.Config), filePerms); err != nil {
+					t.Fatalf("unable to write config: %v", err)
+				}
+			}
+			conf, err := LoadConfig(p)
+			if err != nil {
+				t.Fatalf("Name: %s, unexpected error: %v", name, err)
+			}
+			if !reflect.DeepEqual(conf.Sinks, c.Expected) {
+				t.Errorf("Name: %s, got: %#v, expected: %#v", name, conf.Sinks, c.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+)
+
+// Record implements Sink
+func (s *Syslog) Record(ctx context.Context, e Event) error {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, s.Tag)
+	if err != nil {
+		return fmt.Errorf("unable to connect to syslog: %+v", err)
+	}
+	defer w.Close()
+	e.Time = e.Time.UTC()
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("unable to marshal audit event: %+v", err)
+	}
+	if err := w.Info(string(buf)); err != nil {
+		return fmt.Errorf("unable to write to syslog: %+v", err)
+	}
+	return nil
+}
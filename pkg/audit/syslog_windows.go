@@ -0,0 +1,11 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+)
+
+// Record implements Sink. Windows has no syslog daemon, use a file or vault sink there.
+func (s *Syslog) Record(ctx context.Context, e Event) error {
+	return fmt.Errorf("syslog isn't supported on Windows, use a file or vault audit sink")
+}
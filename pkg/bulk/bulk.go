@@ -0,0 +1,113 @@
+// Package bulk selects secrets across drops for operations on many secrets at once
+package bulk
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Secret is a secret in a drop
+type Secret struct {
+	Entity string
+	Name   string
+	// Created is zero when the secret was shared before psst recorded metadata
+	Created time.Time
+}
+
+// Filter picks secrets by name pattern and age
+type Filter struct {
+	// Patterns are shell globs matched against secret names, any one has to match
+	Patterns []string
+	// All matches every name
+	All bool
+	// OlderThan only matches secrets created more than this long ago, zero disables the check
+	OlderThan time.Duration
+	Now       time.Time
+}
+
+// IsPattern checks if s contains glob characters
+func IsPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// Validate checks that every pattern is a valid glob
+func (f Filter) Validate() error {
+	if !f.All && len(f.Patterns) == 0 {
+		return fmt.Errorf("provide a name, a pattern or --all")
+	}
+	for _, p := range f.Patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %v", p, err)
+		}
+	}
+	return nil
+}
+
+// MatchName checks if name matches the filter's patterns, ignoring age
+func (f Filter) MatchName(name string) bool {
+	if f.All {
+		return true
+	}
+	for _, p := range f.Patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match checks if a secret matches the filter. Secrets of unknown age never match an age filter.
+func (f Filter) Match(s Secret) bool {
+	if !f.MatchName(s.Name) {
+		return false
+	}
+	if f.OlderThan > 0 {
+		if s.Created.IsZero() {
+			return false
+		}
+		return f.Now.Sub(s.Created) > f.OlderThan
+	}
+	return true
+}
+
+// MatchEntities returns the entities matching pattern, which may be a glob such as '*'
+func MatchEntities(pattern string, entities []string) []string {
+	matched := []string{}
+	for _, e := range entities {
+		if ok, _ := path.Match(pattern, e); ok {
+			matched = append(matched, e)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+// Sort orders secrets by drop and name
+func Sort(secrets []Secret) {
+	sort.Slice(secrets, func(i, j int) bool {
+		if secrets[i].Entity != secrets[j].Entity {
+			return secrets[i].Entity < secrets[j].Entity
+		}
+		return secrets[i].Name < secrets[j].Name
+	})
+}
+
+// Page returns page number page, counting from 1, of secrets split into pages of size along with the
+// number of pages. A size of 0 or less returns every secret on a single page.
+func Page(secrets []Secret, page, size int) ([]Secret, int) {
+	if size <= 0 || len(secrets) == 0 {
+		return secrets, 1
+	}
+	pages := (len(secrets) + size - 1) / size
+	if page < 1 || page > pages {
+		return []Secret{}, pages
+	}
+	end := page * size
+	if end > len(secrets) {
+		end = len(secrets)
+	}
+	return secrets[(page-1)*size : end], pages
+}
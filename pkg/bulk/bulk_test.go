@@ -0,0 +1,118 @@
+package bulk
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-40 * 24 * time.Hour)
+	recent := now.Add(-time.Hour)
+
+	cases := map[string]struct {
+		Filter   Filter
+		Secret   Secret
+		Expected bool
+	}{
+		"ExactTest":         {Filter: Filter{Patterns: []string{"db"}}, Secret: Secret{Name: "db"}, Expected: true},
+		"ExactMissTest":     {Filter: Filter{Patterns: []string{"db"}}, Secret: Secret{Name: "db2"}, Expected: false},
+		"GlobTest":          {Filter: Filter{Patterns: []string{"tmp-*"}}, Secret: Secret{Name: "tmp-key"}, Expected: true},
+		"GlobMissTest":      {Filter: Filter{Patterns: []string{"tmp-*"}}, Secret: Secret{Name: "key-tmp"}, Expected: false},
+		"AnyPatternTest":    {Filter: Filter{Patterns: []string{"a*", "b*"}}, Secret: Secret{Name: "bee"}, Expected: true},
+		"AllTest":           {Filter: Filter{All: true}, Secret: Secret{Name: "anything"}, Expected: true},
+		"OlderTest":         {Filter: Filter{All: true, OlderThan: 30 * 24 * time.Hour, Now: now}, Secret: Secret{Name: "a", Created: old}, Expected: true},
+		"NewerTest":         {Filter: Filter{All: true, OlderThan: 30 * 24 * time.Hour, Now: now}, Secret: Secret{Name: "a", Created: recent}, Expected: false},
+		"UnknownAgeTest":    {Filter: Filter{All: true, OlderThan: time.Hour, Now: now}, Secret: Secret{Name: "a"}, Expected: false},
+		"OlderNameMissTest": {Filter: Filter{Patterns: []string{"b"}, OlderThan: time.Hour, Now: now}, Secret: Secret{Name: "a", Created: old}, Expected: false},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := c.Filter.Match(c.Secret); got != c.Expected {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]struct {
+		Filter Filter
+		Err    bool
+	}{
+		"PatternTest":    {Filter: Filter{Patterns: []string{"tmp-*"}}},
+		"AllTest":        {Filter: Filter{All: true}},
+		"EmptyTest":      {Filter: Filter{}, Err: true},
+		"BadPatternTest": {Filter: Filter{Patterns: []string{"tmp-["}}, Err: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := c.Filter.Validate(); (err != nil) != c.Err {
+				t.Errorf("Name: %s, got: %v, expected error: %v", name, err, c.Err)
+			}
+		})
+	}
+}
+
+func TestMatchEntities(t *testing.T) {
+	teams := []string{"sre", "backend", "sre-oncall"}
+
+	cases := map[string]struct {
+		Pattern  string
+		Expected []string
+	}{
+		"AllTest":    {Pattern: "*", Expected: []string{"backend", "sre", "sre-oncall"}},
+		"PrefixTest": {Pattern: "sre*", Expected: []string{"sre", "sre-oncall"}},
+		"NoneTest":   {Pattern: "web", Expected: []string{}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := MatchEntities(c.Pattern, teams); !reflect.DeepEqual(got, c.Expected) {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	secrets := []Secret{{Entity: "sre", Name: "b"}, {Entity: "alice", Name: "z"}, {Entity: "sre", Name: "a"}}
+	Sort(secrets)
+	expected := []Secret{{Entity: "alice", Name: "z"}, {Entity: "sre", Name: "a"}, {Entity: "sre", Name: "b"}}
+	if !reflect.DeepEqual(secrets, expected) {
+		t.Errorf("got: %v, expected: %v", secrets, expected)
+	}
+}
+
+func TestPage(t *testing.T) {
+	secrets := []Secret{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "e"}}
+
+	cases := map[string]struct {
+		Page     int
+		Size     int
+		Expected []string
+		Pages    int
+	}{
+		"FirstTest":     {Page: 1, Size: 2, Expected: []string{"a", "b"}, Pages: 3},
+		"LastTest":      {Page: 3, Size: 2, Expected: []string{"e"}, Pages: 3},
+		"PastEndTest":   {Page: 4, Size: 2, Expected: []string{}, Pages: 3},
+		"ExactTest":     {Page: 1, Size: 5, Expected: []string{"a", "b", "c", "d", "e"}, Pages: 1},
+		"UnlimitedTest": {Page: 1, Size: 0, Expected: []string{"a", "b", "c", "d", "e"}, Pages: 1},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, pages := Page(secrets, c.Page, c.Size)
+			names := []string{}
+			for _, s := range got {
+				names = append(names, s.Name)
+			}
+			if !reflect.DeepEqual(names, c.Expected) || pages != c.Pages {
+				t.Errorf("Name: %s, got: %v of %d, expected: %v of %d", name, names, pages, c.Expected, c.Pages)
+			}
+		})
+	}
+}
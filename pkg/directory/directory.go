@@ -1,15 +1,30 @@
 package directory
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"sort"
+	"time"
 )
 
 const (
 	cacheTTL = 60.0 // 60 minute TTL
 )
 
-var cacheDir = os.ExpandEnv("${HOME}/.psst/cache")
+var (
+	cacheDir   = os.ExpandEnv("${HOME}/.psst/cache")
+	cacheFiles = []string{"members", "teams", "active-memberships", "login"}
+)
+
+// CacheFiles returns the paths of the files caching the directory and how long they stay fresh
+func CacheFiles() ([]string, time.Duration) {
+	paths := []string{}
+	for _, f := range cacheFiles {
+		paths = append(paths, filepath.Join(cacheDir, f))
+	}
+	return paths, time.Duration(cacheTTL) * time.Minute
+}
 
 // Backend allows us to have an easy way to get information from GitHub for members and teams
 type Backend interface {
@@ -20,7 +35,7 @@ type Backend interface {
 	GetActiveMemberTeams() []string
 	IsMember(string) (string, bool)
 	IsTeam(string) (string, bool)
-	Whoami() (string, error)
+	Whoami(context.Context) (string, error)
 }
 
 // Info is the basic information required by all directory implementations
@@ -41,6 +56,8 @@ type Member struct {
 type Team struct {
 	Name    string
 	Members []string
+	// Maintainers are the members with the maintainer role on the team
+	Maintainers []string `json:",omitempty"`
 }
 
 // Matches allows us to return both usernames and team names as single type
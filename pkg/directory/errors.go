@@ -0,0 +1,49 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/google/go-github/github"
+)
+
+// Errors returned when talking to GitHub, wrapped with more detail. Check for them with errors.Is.
+var (
+	// ErrForbidden is returned when GitHub rejects the token or it lacks the needed scopes
+	ErrForbidden = errors.New("GitHub denied access")
+	// ErrUnavailable is returned when GitHub can't be reached or is rate limiting requests
+	ErrUnavailable = errors.New("GitHub unavailable")
+)
+
+// classify wraps an error from the GitHub client with the matching sentinel error
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	switch e := err.(type) {
+	case *github.RateLimitError, *github.AbuseRateLimitError:
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	case *github.ErrorResponse:
+		if e.Response == nil {
+			return err
+		}
+		switch {
+		case e.Response.StatusCode == http.StatusUnauthorized || e.Response.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("%w: %v", ErrForbidden, err)
+		case e.Response.StatusCode >= 500:
+			return fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return err
+	case net.Error:
+		// Transport failures such as DNS or connection errors
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestClassify(t *testing.T) {
+	response := func(code int) *github.ErrorResponse {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code, Request: &http.Request{Method: "GET", URL: &url.URL{}}}}
+	}
+	network := &url.Error{Op: "Get", URL: "https://api.github.com/user", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	other := errors.New("bad username")
+
+	cases := map[string]struct {
+		Err      error
+		Expected error
+	}{
+		"UnauthorizedTest": {Err: response(http.StatusUnauthorized), Expected: ErrForbidden},
+		"ForbiddenTest":    {Err: response(http.StatusForbidden), Expected: ErrForbidden},
+		"ServerErrorTest":  {Err: response(http.StatusBadGateway), Expected: ErrUnavailable},
+		"RateLimitTest":    {Err: &github.RateLimitError{Response: &http.Response{}}, Expected: ErrUnavailable},
+		"NetworkTest":      {Err: network, Expected: ErrUnavailable},
+		"CanceledTest":     {Err: context.Canceled, Expected: context.Canceled},
+		"OtherTest":        {Err: other, Expected: other},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := classify(c.Err); !errors.Is(got, c.Expected) {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
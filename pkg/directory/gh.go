@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -35,11 +36,26 @@ type GH struct {
 
 	UsersService UsersService
 	Info
+
+	// login of the authenticated user, saved alongside the cache
+	login string
+}
+
+// cachedUsersService answers Whoami from the cache so it can be used without a network connection
+type cachedUsersService struct {
+	login string
+}
+
+func (c cachedUsersService) Get(ctx context.Context, name string) (*github.User, *github.Response, error) {
+	if c.login == "" {
+		return nil, nil, errors.New("login is not cached")
+	}
+	return &github.User{Login: &c.login}, nil, nil
 }
 
-// NewGitHub returns an initialized GitHub client to the caller and stored GH members and teams
-func NewGitHub(org string, updateCache bool) (*GH, error) {
-	ctx := context.Background()
+// NewGitHub returns an initialized GitHub client to the caller and stored GH members and teams.
+// Cancelling ctx stops refreshing the cache.
+func NewGitHub(ctx context.Context, org string, updateCache bool) (*GH, error) {
 	client := &GH{}
 
 	token, ok := os.LookupEnv("GITHUB_TOKEN")
@@ -54,13 +70,36 @@ func NewGitHub(org string, updateCache bool) (*GH, error) {
 	client.UsersService = client.Client.Users
 	client.Org = org
 
-	if err := client.getMembersAndTeams(updateCache); err != nil {
+	if err := client.getMembersAndTeams(ctx, updateCache); err != nil {
 		return client, err
 	}
 	return client, nil
 }
 
-func (g *GH) getMembersAndTeams(updateCache bool) error {
+// NewGitHubFromCache returns a GH loaded only from the on-disk cache, regardless of its age. It never
+// contacts GitHub so it is safe to use where speed matters more than freshness, such as shell completion.
+func NewGitHubFromCache(org string) (*GH, error) {
+	client := &GH{}
+	client.Org = org
+
+	if err := getCached(filepath.Join(cacheDir, "members"), &client.Members); err != nil {
+		return client, errors.Wrap(err, "unable to get cached members information")
+	}
+	if err := getCached(filepath.Join(cacheDir, "teams"), &client.Info.Teams); err != nil {
+		return client, errors.Wrap(err, "unable to get cached team information")
+	}
+	if err := getCached(filepath.Join(cacheDir, "active-memberships"), &client.ActiveMemberTeams); err != nil {
+		return client, errors.Wrap(err, "unable to get cached active memberships information")
+	}
+	// The login is optional since caches written by older versions will not have it
+	if err := getCached(filepath.Join(cacheDir, "login"), &client.login); err != nil && !os.IsNotExist(err) {
+		return client, errors.Wrap(err, "unable to get cached login")
+	}
+	client.UsersService = cachedUsersService{login: client.login}
+	return client, nil
+}
+
+func (g *GH) getMembersAndTeams(ctx context.Context, updateCache bool) error {
 	update := updateCache
 
 	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
@@ -86,23 +125,23 @@ func (g *GH) getMembersAndTeams(updateCache bool) error {
 	}
 
 	if update {
-		grp, _ := errgroup.WithContext(context.Background())
+		grp, gctx := errgroup.WithContext(ctx)
 		grp.Go(func() error {
-			if err := g.getMembers(); err != nil {
+			if err := g.getMembers(gctx); err != nil {
 				return err
 			}
 			return nil
 		})
 
 		grp.Go(func() error {
-			if err := g.getTeams(); err != nil {
+			if err := g.getTeams(gctx); err != nil {
 				return err
 			}
 			return nil
 		})
 
 		if err := grp.Wait(); err != nil {
-			return errors.Wrap(err, "unable to get members or teams from GitHub")
+			return fmt.Errorf("unable to get members or teams from GitHub: %w", err)
 		}
 
 		if err := saveCache(membersFile, g.Members); err != nil {
@@ -114,6 +153,9 @@ func (g *GH) getMembersAndTeams(updateCache bool) error {
 		if err := saveCache(activeMembershipsFile, g.ActiveMemberTeams); err != nil {
 			return errors.Wrap(err, "unable to save active memberships file")
 		}
+		if err := saveCache(filepath.Join(cacheDir, "login"), g.login); err != nil {
+			return errors.Wrap(err, "unable to save login file")
+		}
 	} else {
 		if err := getCached(membersFile, &g.Members); err != nil {
 			return errors.Wrap(err, "unable to get cached members information")
@@ -165,33 +207,34 @@ func getCached(filename string, v interface{}) error {
 	return nil
 }
 
-func (g *GH) getMembers() error {
+func (g *GH) getMembers(ctx context.Context) error {
 	members := []Member{}
 
 	in := make(chan string)
 	out := make(chan Member)
 
-	activeMember, err := g.Whoami()
+	activeMember, err := g.Whoami(ctx)
 	if err != nil {
 		return err
 	}
+	g.login = activeMember
 
 	// This process can be slow so we speed it up by doing multiple lookups at a time.
 	// Was implemented because it took about 45 seconds to get all members and teams and this
 	// took it down to about 3 seconds.
-	grp, _ := errgroup.WithContext(context.Background())
+	grp, gctx := errgroup.WithContext(ctx)
 	for i := 0; i < ghWorkers; i++ {
 		grp.Go(func() error {
 			for login := range in {
-				u, _, err := g.Client.Users.Get(context.Background(), login)
+				u, _, err := g.Client.Users.Get(gctx, login)
 				if err != nil {
-					return errors.Wrap(err, fmt.Sprintf("error looking up member %s", login))
+					return fmt.Errorf("error looking up member %s: %w", login, classify(err))
 				}
 
 				// Get memberships for the local user, we don't care about everybody's membership
 				if login == activeMember {
 					teams := []string{}
-					teams, err = g.getTeamMemberships(login)
+					teams, err = g.getTeamMemberships(gctx, login)
 					if err != nil {
 						return err
 					}
@@ -211,15 +254,20 @@ func (g *GH) getMembers() error {
 
 	nextPage := 1
 	for nextPage > 0 {
-		ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+		pctx, cancel := context.WithTimeout(gctx, contextTimeout)
 		defer cancel()
-		mems, resp, err := g.Client.Organizations.ListMembers(ctx, g.Org, &github.ListMembersOptions{ListOptions: github.ListOptions{Page: nextPage}})
+		mems, resp, err := g.Client.Organizations.ListMembers(pctx, g.Org, &github.ListMembersOptions{ListOptions: github.ListOptions{Page: nextPage}})
 		if err != nil {
-			return errors.Wrap(err, "unable to get members from GitHub")
+			close(in)
+			grp.Wait()
+			return fmt.Errorf("unable to get members from GitHub: %w", classify(err))
 		}
 
 		for _, m := range mems {
-			in <- m.GetLogin()
+			select {
+			case in <- m.GetLogin():
+			case <-gctx.Done():
+			}
 		}
 
 		nextPage = resp.NextPage
@@ -227,7 +275,7 @@ func (g *GH) getMembers() error {
 
 	close(in)
 	if err := grp.Wait(); err != nil {
-		return errors.Wrap(err, "error looking up members")
+		return fmt.Errorf("error looking up members: %w", err)
 	}
 	close(out)
 	ByMembers(sortMemberLogins).Sort(members)
@@ -236,7 +284,7 @@ func (g *GH) getMembers() error {
 	return nil
 }
 
-func (g *GH) getTeams() error {
+func (g *GH) getTeams(ctx context.Context) error {
 	teams := []Team{}
 
 	in := make(chan *github.Team)
@@ -245,15 +293,19 @@ func (g *GH) getTeams() error {
 	// This process can be slow so we speed it up by doing multiple lookups at a time.
 	// Was implemented because it took about 45 seconds to get all members and teams and this
 	// took it down to about 3 seconds.
-	grp, _ := errgroup.WithContext(context.Background())
+	grp, gctx := errgroup.WithContext(ctx)
 	for i := 0; i < ghWorkers; i++ {
 		grp.Go(func() error {
 			for team := range in {
-				mems, err := g.getTeamMembers(team.GetID())
+				mems, err := g.getTeamMembers(gctx, team.GetID(), "all")
+				if err != nil {
+					return fmt.Errorf("error looking up members of team %s: %w", team.GetName(), err)
+				}
+				maintainers, err := g.getTeamMembers(gctx, team.GetID(), "maintainer")
 				if err != nil {
-					return errors.Wrap(err, fmt.Sprintf("error looking up members of team %s", team.GetName()))
+					return fmt.Errorf("error looking up maintainers of team %s: %w", team.GetName(), err)
 				}
-				out <- Team{Name: team.GetName(), Members: mems}
+				out <- Team{Name: team.GetName(), Members: mems, Maintainers: maintainers}
 
 			}
 			return nil
@@ -273,22 +325,27 @@ func (g *GH) getTeams() error {
 
 	nextPage := 1
 	for nextPage > 0 {
-		ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+		pctx, cancel := context.WithTimeout(gctx, contextTimeout)
 		defer cancel()
-		ts, resp, err := g.Client.Teams.ListTeams(ctx, g.Org, &github.ListOptions{Page: nextPage})
+		ts, resp, err := g.Client.Teams.ListTeams(pctx, g.Org, &github.ListOptions{Page: nextPage})
 		if err != nil {
-			return errors.Wrap(err, "unable to get teams from GitHub")
+			close(in)
+			grp.Wait()
+			return fmt.Errorf("unable to get teams from GitHub: %w", classify(err))
 		}
 
 		for _, t := range ts {
-			in <- t
+			select {
+			case in <- t:
+			case <-gctx.Done():
+			}
 		}
 
 		nextPage = resp.NextPage
 	}
 	close(in)
 	if err := grp.Wait(); err != nil {
-		return errors.Wrap(err, "unable to lookup teams")
+		return fmt.Errorf("unable to lookup teams: %w", err)
 	}
 	close(out)
 	ByTeams(sortTeamNames).Sort(teams)
@@ -297,14 +354,14 @@ func (g *GH) getTeams() error {
 	return nil
 }
 
-func (g *GH) getTeamMembers(id int64) ([]string, error) {
+func (g *GH) getTeamMembers(ctx context.Context, id int64, role string) ([]string, error) {
 	members := []string{}
 	nextPage := 1
 
 	for nextPage > 0 {
-		users, resp, err := g.Client.Teams.ListTeamMembers(context.Background(), id, &github.TeamListTeamMembersOptions{Role: "all", ListOptions: github.ListOptions{Page: nextPage}})
+		users, resp, err := g.Client.Teams.ListTeamMembers(ctx, id, &github.TeamListTeamMembersOptions{Role: role, ListOptions: github.ListOptions{Page: nextPage}})
 		if err != nil {
-			return members, err
+			return members, classify(err)
 		}
 		for _, u := range users {
 			members = append(members, u.GetLogin())
@@ -316,28 +373,9 @@ func (g *GH) getTeamMembers(id int64) ([]string, error) {
 }
 
 // GetMatches will search for a given value as part of a username or team name and return a set of
-// available options for the user.
+// available options for the user ordered from the best match to the worst.
 func (g *GH) GetMatches(lookup string) Matches {
-	matches := Matches{}
-
-	if lookup == "*" {
-		matches.Members = g.Members
-		matches.Teams = g.Info.Teams
-		return matches
-	}
-
-	for _, m := range g.Members {
-		if strings.Contains(strings.ToLower(m.Login), strings.ToLower(lookup)) || strings.Contains(strings.ToLower(m.Name), strings.ToLower(lookup)) {
-			matches.Members = append(matches.Members, m)
-		}
-	}
-
-	for _, t := range g.Info.Teams {
-		if strings.Contains(strings.ToLower(t.Name), strings.ToLower(lookup)) {
-			matches.Teams = append(matches.Teams, t)
-		}
-	}
-	return matches
+	return RankMatches(lookup, g.Members, g.Info.Teams)
 }
 
 // IsMember will check an organization for a specific user
@@ -371,14 +409,37 @@ func (g *GH) GetTeamMembers(name string) []string {
 }
 
 // Whoami returns the login name of the currently authenitcated user
-func (g *GH) Whoami() (string, error) {
-	user, _, err := g.UsersService.Get(context.Background(), "")
+func (g *GH) Whoami(ctx context.Context) (string, error) {
+	user, _, err := g.UsersService.Get(ctx, "")
 	if err != nil {
-		return "", errors.Wrap(err, "unable to get authenticated user's login")
+		return "", fmt.Errorf("unable to get authenticated user's login: %w", classify(err))
 	}
 	return *user.Login, nil
 }
 
+// TokenInfo returns the OAuth scopes granted to GITHUB_TOKEN and the current time according to GitHub
+func (g *GH) TokenInfo(ctx context.Context) ([]string, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, contextTimeout)
+	defer cancel()
+
+	_, resp, err := g.Client.Users.Get(ctx, "")
+	if err != nil {
+		return []string{}, time.Time{}, fmt.Errorf("unable to get authenticated user: %w", classify(err))
+	}
+
+	scopes := []string{}
+	for _, s := range strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return scopes, time.Time{}, errors.Wrap(err, "unable to parse date from GitHub")
+	}
+	return scopes, date, nil
+}
+
 // GetMembers returns the list of members
 func (g *GH) GetMembers() []Member {
 	return g.Members
@@ -394,14 +455,14 @@ func (g *GH) GetActiveMemberTeams() []string {
 	return g.ActiveMemberTeams
 }
 
-func (g *GH) getTeamMemberships(member string) ([]string, error) {
+func (g *GH) getTeamMemberships(ctx context.Context, member string) ([]string, error) {
 	teamNames := []string{}
 
 	opts := &github.ListOptions{Page: 1}
 	for {
-		teams, resp, err := g.Client.Teams.ListUserTeams(context.Background(), &github.ListOptions{})
+		teams, resp, err := g.Client.Teams.ListUserTeams(ctx, &github.ListOptions{})
 		if err != nil {
-			return []string{}, err
+			return []string{}, classify(err)
 		}
 
 		for _, t := range teams {
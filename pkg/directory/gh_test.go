@@ -2,6 +2,9 @@ package directory
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-github/github"
@@ -234,7 +237,7 @@ func TestWhoami(t *testing.T) {
 
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
-			login, err := c.State.Whoami()
+			login, err := c.State.Whoami(context.Background())
 			if login != c.Expected.login {
 				t.Errorf("Name: %s, got: %s, expected: %s", name, login, c.Expected.login)
 			}
@@ -314,3 +317,54 @@ func checkTeams(g []Team, e []Team) bool {
 
 	return true
 }
+
+func TestNewGitHubFromCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-cache-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldCacheDir := cacheDir
+	cacheDir = dir
+	defer func() { cacheDir = oldCacheDir }()
+
+	members := []Member{Member{Login: "test1", Name: "Test 1"}}
+	teams := []Team{Team{Name: "team1", Members: []string{"test1"}}}
+	for file, v := range map[string]interface{}{
+		"members":            members,
+		"teams":              teams,
+		"active-memberships": []string{"team1"},
+		"login":              "test1",
+	} {
+		if err := saveCache(filepath.Join(dir, file), v); err != nil {
+			t.Fatalf("unable to save cache file %s: %v", file, err)
+		}
+	}
+
+	g, err := NewGitHubFromCache("org")
+	if err != nil {
+		t.Fatalf("unable to load cache: %v", err)
+	}
+	if !checkMembers(g.GetMembers(), members) {
+		t.Errorf("got: %+v, expected: %+v", g.GetMembers(), members)
+	}
+	if !checkTeams(g.GetTeams(), teams) {
+		t.Errorf("got: %+v, expected: %+v", g.GetTeams(), teams)
+	}
+	login, err := g.Whoami(context.Background())
+	if err != nil || login != "test1" {
+		t.Errorf("got: %s (%v), expected: test1", login, err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "login")); err != nil {
+		t.Fatalf("unable to remove login cache: %v", err)
+	}
+	g, err = NewGitHubFromCache("org")
+	if err != nil {
+		t.Fatalf("unable to load cache without login: %v", err)
+	}
+	if _, err := g.Whoami(context.Background()); err == nil {
+		t.Errorf("expected an error without a cached login")
+	}
+}
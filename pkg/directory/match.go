@@ -0,0 +1,243 @@
+package directory
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scores for the different ways a lookup can match a candidate. Higher is better.
+const (
+	scoreExact        = 1000
+	scorePrefix       = 800
+	scoreWordPrefix   = 600
+	scoreInitials     = 500
+	scoreSubstring    = 400
+	scoreTypo         = 200
+	scoreTypoPenalty  = 50
+	minSubstringLen   = 3
+	minInitialsLen    = 2
+	typoLenOneEdit    = 4
+	typoLenTwoEdits   = 8
+	lengthPenaltyCeil = 50
+)
+
+// rankedMember is a member along with how well it matched a lookup
+type rankedMember struct {
+	Member
+	score int
+}
+
+// rankedTeam is a team along with how well it matched a lookup
+type rankedTeam struct {
+	Team
+	score int
+}
+
+// Score rates how closely lookup matches candidate without allowing typos. A score of zero means
+// there is no match. Matching is case-insensitive and rewards exact matches, prefixes, matches at
+// the start of a word and initials (e.g. "ahamilton" for "Andrew Hamilton").
+func Score(lookup, candidate string) int {
+	q := strings.ToLower(strings.TrimSpace(lookup))
+	c := strings.ToLower(strings.TrimSpace(candidate))
+	if q == "" || c == "" {
+		return 0
+	}
+
+	// Shorter candidates are preferred when two candidates match the same way
+	penalty := len(c) - len(q)
+	if penalty > lengthPenaltyCeil {
+		penalty = lengthPenaltyCeil
+	}
+
+	switch {
+	case q == c:
+		return scoreExact
+	case strings.HasPrefix(c, q):
+		return scorePrefix - penalty
+	}
+
+	words := splitWords(c)
+	for _, w := range words[1:] {
+		if strings.HasPrefix(w, q) {
+			return scoreWordPrefix - penalty
+		}
+	}
+	if len(q) >= minInitialsLen && len(words) > 1 && matchesInitials(q, words) {
+		return scoreInitials - penalty
+	}
+	if len(q) >= minSubstringLen && strings.Contains(c, q) {
+		return scoreSubstring - penalty
+	}
+	return 0
+}
+
+// TypoScore rates how closely lookup matches candidate, or any word inside of it, when allowing for
+// a small number of typos. A score of zero means the lookup is too far away from the candidate.
+func TypoScore(lookup, candidate string) int {
+	q := strings.ToLower(strings.TrimSpace(lookup))
+	c := strings.ToLower(strings.TrimSpace(candidate))
+
+	allowed := 0
+	switch {
+	case len(q) >= typoLenTwoEdits:
+		allowed = 2
+	case len(q) >= typoLenOneEdit:
+		allowed = 1
+	}
+	if allowed == 0 || c == "" {
+		return 0
+	}
+
+	best := editDistance(q, c)
+	for _, w := range splitWords(c) {
+		if d := editDistance(q, w); d < best {
+			best = d
+		}
+	}
+	if best > allowed {
+		return 0
+	}
+	return scoreTypo - best*scoreTypoPenalty
+}
+
+// RankMatches scores every member and team against lookup and returns the matches ordered from best
+// to worst. Typos are only taken into account when nothing matches the lookup directly. An empty
+// lookup or "*" returns everything in alphabetical order.
+func RankMatches(lookup string, members []Member, teams []Team) Matches {
+	lookup = strings.TrimSpace(lookup)
+	if lookup == "" || lookup == "*" {
+		return Matches{Members: members, Teams: teams}
+	}
+
+	rm, rt := rank(lookup, members, teams, Score)
+	if len(rm) == 0 && len(rt) == 0 {
+		rm, rt = rank(lookup, members, teams, TypoScore)
+	}
+
+	matches := Matches{}
+	for _, m := range rm {
+		matches.Members = append(matches.Members, m.Member)
+	}
+	for _, t := range rt {
+		matches.Teams = append(matches.Teams, t.Team)
+	}
+	return matches
+}
+
+func rank(lookup string, members []Member, teams []Team, score func(string, string) int) ([]rankedMember, []rankedTeam) {
+	rm := []rankedMember{}
+	for _, m := range members {
+		s := score(lookup, m.Login)
+		if n := score(lookup, m.Name); n > s {
+			s = n
+		}
+		if s > 0 {
+			rm = append(rm, rankedMember{Member: m, score: s})
+		}
+	}
+	sort.SliceStable(rm, func(i, j int) bool {
+		if rm[i].score != rm[j].score {
+			return rm[i].score > rm[j].score
+		}
+		return rm[i].Login < rm[j].Login
+	})
+
+	rt := []rankedTeam{}
+	for _, t := range teams {
+		if s := score(lookup, t.Name); s > 0 {
+			rt = append(rt, rankedTeam{Team: t, score: s})
+		}
+	}
+	sort.SliceStable(rt, func(i, j int) bool {
+		if rt[i].score != rt[j].score {
+			return rt[i].score > rt[j].score
+		}
+		return rt[i].Name < rt[j].Name
+	})
+
+	return rm, rt
+}
+
+// Limit caps the number of members and teams to n each. A limit of zero or less returns every match.
+func (m Matches) Limit(n int) Matches {
+	if n <= 0 {
+		return m
+	}
+	if len(m.Members) > n {
+		m.Members = m.Members[:n]
+	}
+	if len(m.Teams) > n {
+		m.Teams = m.Teams[:n]
+	}
+	return m
+}
+
+// splitWords breaks a name or login into words on spaces and punctuation
+func splitWords(s string) []string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return []string{s}
+	}
+	return words
+}
+
+// matchesInitials checks if the lookup can be built from a prefix of each word in order, with at
+// least two words contributing. Words may be skipped, which allows for middle names.
+func matchesInitials(q string, words []string) bool {
+	var match func(q string, words []string, used int) bool
+	match = func(q string, words []string, used int) bool {
+		if q == "" {
+			return used >= 2
+		}
+		for i, w := range words {
+			for n := len(w); n > 0; n-- {
+				if strings.HasPrefix(q, w[:n]) && match(q[n:], words[i+1:], used+1) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return match(q, words, 0)
+}
+
+// editDistance is the optimal string alignment distance between a and b, which counts insertions,
+// deletions, substitutions and transpositions of adjacent characters.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func minInt(vals ...int) int {
+	m := vals[0]
+	for _, v := range vals[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
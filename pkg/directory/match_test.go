@@ -0,0 +1,183 @@
+package directory
+
+import (
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	cases := map[string]struct {
+		Lookup    string
+		Candidate string
+		Expected  int
+	}{
+		"ExactTest": {
+			Lookup:    "Test1",
+			Candidate: "test1",
+			Expected:  scoreExact,
+		},
+		"PrefixTest": {
+			Lookup:    "and",
+			Candidate: "andrew",
+			Expected:  scorePrefix - 3,
+		},
+		"WordPrefixTest": {
+			Lookup:    "ham",
+			Candidate: "Andrew Hamilton",
+			Expected:  scoreWordPrefix - 12,
+		},
+		"InitialsTest": {
+			Lookup:    "ahamilton",
+			Candidate: "Andrew Hamilton",
+			Expected:  scoreInitials - 6,
+		},
+		"InitialsMiddleNameTest": {
+			Lookup:    "ah",
+			Candidate: "Andrew J Hamilton",
+			Expected:  scoreInitials - 15,
+		},
+		"SubstringTest": {
+			Lookup:    "mil",
+			Candidate: "hamilton",
+			Expected:  scoreSubstring - 5,
+		},
+		"ShortSubstringTest": {
+			Lookup:    "mi",
+			Candidate: "hamilton",
+			Expected:  0,
+		},
+		"NoMatchTest": {
+			Lookup:    "zzz",
+			Candidate: "hamilton",
+			Expected:  0,
+		},
+		"EmptyCandidateTest": {
+			Lookup:    "test",
+			Candidate: "",
+			Expected:  0,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Score(c.Lookup, c.Candidate)
+			if got != c.Expected {
+				t.Errorf("Name: %s, got: %d, expected: %d", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestTypoScore(t *testing.T) {
+	cases := map[string]struct {
+		Lookup    string
+		Candidate string
+		Expected  int
+	}{
+		"TranspositionTest": {
+			Lookup:    "jhon",
+			Candidate: "John Smith",
+			Expected:  scoreTypo - scoreTypoPenalty,
+		},
+		"TwoEditsTest": {
+			Lookup:    "hamiltno1",
+			Candidate: "hamilton",
+			Expected:  scoreTypo - 2*scoreTypoPenalty,
+		},
+		"TooShortTest": {
+			Lookup:    "jhn",
+			Candidate: "jon",
+			Expected:  0,
+		},
+		"TooFarTest": {
+			Lookup:    "jhon",
+			Candidate: "jane",
+			Expected:  0,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := TypoScore(c.Lookup, c.Candidate)
+			if got != c.Expected {
+				t.Errorf("Name: %s, got: %d, expected: %d", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestRankMatches(t *testing.T) {
+	members := []Member{
+		Member{Login: "ahamilton", Name: "Andrew Hamilton"},
+		Member{Login: "dan", Name: "Daniel Anderson"},
+		Member{Login: "jsmith", Name: "John Smith"},
+		Member{Login: "nan", Name: "Nancy Jones"},
+	}
+	teams := []Team{Team{Name: "platform-services"}, Team{Name: "sre"}}
+
+	cases := map[string]struct {
+		Lookup          string
+		ExpectedMembers []string
+		ExpectedTeams   []string
+	}{
+		"OrderedTest": {
+			Lookup:          "an",
+			ExpectedMembers: []string{"ahamilton", "dan"},
+		},
+		"InitialsTest": {
+			Lookup:          "jsmith",
+			ExpectedMembers: []string{"jsmith"},
+		},
+		"TypoTest": {
+			Lookup:          "jhon",
+			ExpectedMembers: []string{"jsmith"},
+		},
+		"TeamWordTest": {
+			Lookup:        "serv",
+			ExpectedTeams: []string{"platform-services"},
+		},
+		"StarTest": {
+			Lookup:          "*",
+			ExpectedMembers: []string{"ahamilton", "dan", "jsmith", "nan"},
+			ExpectedTeams:   []string{"platform-services", "sre"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := RankMatches(c.Lookup, members, teams)
+			if len(got.Members) != len(c.ExpectedMembers) {
+				t.Fatalf("Name: %s, got: %+v, expected: %v", name, got.Members, c.ExpectedMembers)
+			}
+			for i := range got.Members {
+				if got.Members[i].Login != c.ExpectedMembers[i] {
+					t.Errorf("Name: %s, got: %+v, expected: %v", name, got.Members, c.ExpectedMembers)
+				}
+			}
+			if len(got.Teams) != len(c.ExpectedTeams) {
+				t.Fatalf("Name: %s, got: %+v, expected: %v", name, got.Teams, c.ExpectedTeams)
+			}
+			for i := range got.Teams {
+				if got.Teams[i].Name != c.ExpectedTeams[i] {
+					t.Errorf("Name: %s, got: %+v, expected: %v", name, got.Teams, c.ExpectedTeams)
+				}
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	matches := Matches{
+		Members: []Member{Member{Login: "test1"}, Member{Login: "test2"}, Member{Login: "test3"}},
+		Teams:   []Team{Team{Name: "team1"}},
+	}
+
+	got := matches.Limit(2)
+	if len(got.Members) != 2 || len(got.Teams) != 1 {
+		t.Errorf("got: %+v, expected 2 members and 1 team", got)
+	}
+
+	got = matches.Limit(0)
+	if len(got.Members) != 3 {
+		t.Errorf("got: %+v, expected all members", got)
+	}
+}
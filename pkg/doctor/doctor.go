@@ -0,0 +1,199 @@
+// Package doctor evaluates the pieces of a psst setup that commonly go wrong and explains how to
+// fix them.
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// TokenWarnTTL is how close to expiring a token must be before a warning is given
+	TokenWarnTTL = 24 * time.Hour
+
+	skewWarn = 30 * time.Second
+	skewFail = 5 * time.Minute
+)
+
+// Status is the outcome of a single check
+type Status int
+
+// Possible outcomes of a check
+const (
+	Pass Status = iota
+	Warn
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "PASS"
+	case Warn:
+		return "WARN"
+	}
+	return "FAIL"
+}
+
+// Result is the outcome of a check along with a hint on how to fix it
+type Result struct {
+	Name    string
+	Status  Status
+	Message string
+	Hint    string
+}
+
+// Passed returns a passing result
+func Passed(name, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: Pass, Message: fmt.Sprintf(format, args...)}
+}
+
+// Warned returns a warning result
+func Warned(name, hint, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: Warn, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+// Failed returns a failed result
+func Failed(name, hint, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: Fail, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+// Report is the set of results from every check run
+type Report []Result
+
+// Failed checks if any check failed
+func (r Report) Failed() bool {
+	for _, res := range r {
+		if res.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints the report with a fix-it hint under every check that didn't pass
+func (r Report) Write(w io.Writer) {
+	counts := map[Status]int{}
+	for _, res := range r {
+		counts[res.Status]++
+		fmt.Fprintf(w, "[%s] %s: %s\n", res.Status, res.Name, res.Message)
+		if res.Status != Pass && res.Hint != "" {
+			fmt.Fprintf(w, "       fix: %s\n", res.Hint)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", counts[Pass], counts[Warn], counts[Fail])
+}
+
+// Scopes checks that a GitHub token has at least one scope from every group in required
+func Scopes(scopes []string, required [][]string) Result {
+	name := "GitHub token scopes"
+	if len(scopes) == 0 {
+		return Warned(name, "use a personal access token with the read:org scope", "GitHub did not report any scopes for the token")
+	}
+
+	have := make(map[string]struct{})
+	for _, s := range scopes {
+		have[strings.TrimSpace(s)] = struct{}{}
+	}
+
+	missing := []string{}
+	for _, group := range required {
+		found := false
+		for _, s := range group {
+			if _, ok := have[s]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, strings.Join(group, " or "))
+		}
+	}
+	if len(missing) > 0 {
+		return Failed(name, "regenerate GITHUB_TOKEN with the missing scopes at https://github.com/settings/tokens",
+			"token is missing %s", strings.Join(missing, ", "))
+	}
+	return Passed(name, "token has %s", strings.Join(scopes, ", "))
+}
+
+// TokenTTL checks how long a Vault token has left. A TTL of zero means the token never expires.
+func TokenTTL(ttl time.Duration, renewable bool) Result {
+	name := "Vault token TTL"
+	hint := "log in to Vault again with: psst login"
+	switch {
+	case ttl == 0:
+		return Passed(name, "token does not expire")
+	case ttl < 0:
+		return Failed(name, hint, "token has expired")
+	case ttl < TokenWarnTTL:
+		if renewable {
+			hint = "renew the token with: vault token renew"
+		}
+		return Warned(name, hint, "token expires in %s", ttl.Round(time.Second))
+	}
+	return Passed(name, "token expires in %s", ttl.Round(time.Second))
+}
+
+// Capabilities checks that every capability in want is granted. The root capability grants everything.
+func Capabilities(name string, have, want []string) Result {
+	granted := make(map[string]struct{})
+	for _, c := range have {
+		granted[c] = struct{}{}
+	}
+	if _, ok := granted["root"]; ok {
+		return Passed(name, "root token")
+	}
+
+	missing := []string{}
+	for _, c := range want {
+		if _, ok := granted[c]; !ok {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return Failed(name, "ask a Vault admin to run psst generate and apply the policies for you",
+			"missing %s (have %s)", strings.Join(missing, ", "), strings.Join(have, ", "))
+	}
+	return Passed(name, "has %s", strings.Join(want, ", "))
+}
+
+// CacheFile checks the age and permissions of a directory cache file
+func CacheFile(path string, info os.FileInfo, statErr error, ttl time.Duration, now time.Time) Result {
+	name := fmt.Sprintf("cache %s", path)
+	hint := "run any psst command with --update-cache"
+	switch {
+	case os.IsNotExist(statErr):
+		return Warned(name, hint, "cache file does not exist yet")
+	case statErr != nil:
+		return Failed(name, fmt.Sprintf("check the permissions on %s or remove it", path), "unable to read: %v", statErr)
+	}
+
+	if info.Mode().Perm()&0077 != 0 {
+		return Warned(name, fmt.Sprintf("chmod go-rwx %s", path), "readable by other users (%v)", info.Mode().Perm())
+	}
+	age := now.Sub(info.ModTime())
+	if age > ttl {
+		return Warned(name, hint, "stale, last updated %s ago", age.Round(time.Second))
+	}
+	return Passed(name, "updated %s ago", age.Round(time.Second))
+}
+
+// ClockSkew compares the local clock against the time reported by a server
+func ClockSkew(source string, local, remote time.Time) Result {
+	name := fmt.Sprintf("clock skew with %s", source)
+	skew := local.Sub(remote)
+	if skew < 0 {
+		skew = -skew
+	}
+	hint := "enable time synchronization (NTP) on this machine"
+	switch {
+	case skew >= skewFail:
+		return Failed(name, hint, "local clock is off by %s", skew.Round(time.Second))
+	case skew >= skewWarn:
+		return Warned(name, hint, "local clock is off by %s", skew.Round(time.Second))
+	}
+	return Passed(name, "within %s", skewWarn)
+}
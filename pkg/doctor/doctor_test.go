@@ -0,0 +1,150 @@
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScopes(t *testing.T) {
+	required := [][]string{{"read:org", "admin:org"}}
+
+	cases := map[string]struct {
+		Scopes   []string
+		Expected Status
+	}{
+		"ScopePresentTest":     {Scopes: []string{"repo", "read:org"}, Expected: Pass},
+		"AlternateScopeTest":   {Scopes: []string{"admin:org"}, Expected: Pass},
+		"ScopeMissingTest":     {Scopes: []string{"repo"}, Expected: Fail},
+		"ScopesUnreportedTest": {Scopes: []string{}, Expected: Warn},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Scopes(c.Scopes, required)
+			if got.Status != c.Expected {
+				t.Errorf("Name: %s, got: %v (%s), expected: %v", name, got.Status, got.Message, c.Expected)
+			}
+		})
+	}
+}
+
+func TestTokenTTL(t *testing.T) {
+	cases := map[string]struct {
+		TTL      time.Duration
+		Expected Status
+	}{
+		"NoExpiryTest": {TTL: 0, Expected: Pass},
+		"ExpiredTest":  {TTL: -time.Second, Expected: Fail},
+		"ExpiringTest": {TTL: time.Hour, Expected: Warn},
+		"FreshTest":    {TTL: 30 * 24 * time.Hour, Expected: Pass},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := TokenTTL(c.TTL, true)
+			if got.Status != c.Expected {
+				t.Errorf("Name: %s, got: %v (%s), expected: %v", name, got.Status, got.Message, c.Expected)
+			}
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	want := []string{"read", "list", "delete"}
+
+	cases := map[string]struct {
+		Have     []string
+		Expected Status
+	}{
+		"AllTest":     {Have: []string{"create", "read", "list", "delete"}, Expected: Pass},
+		"RootTest":    {Have: []string{"root"}, Expected: Pass},
+		"MissingTest": {Have: []string{"read"}, Expected: Fail},
+		"DenyTest":    {Have: []string{"deny"}, Expected: Fail},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Capabilities("drop", c.Have, want)
+			if got.Status != c.Expected {
+				t.Errorf("Name: %s, got: %v (%s), expected: %v", name, got.Status, got.Message, c.Expected)
+			}
+		})
+	}
+}
+
+func TestCacheFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "psst-doctor-")
+	if err != nil {
+		t.Fatalf("unable to create temporary file: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	stat := func(mode os.FileMode) os.FileInfo {
+		if err := os.Chmod(f.Name(), mode); err != nil {
+			t.Fatalf("unable to chmod: %v", err)
+		}
+		info, err := os.Stat(f.Name())
+		if err != nil {
+			t.Fatalf("unable to stat: %v", err)
+		}
+		return info
+	}
+
+	now := time.Now()
+	if got := CacheFile(f.Name(), stat(0600), nil, time.Hour, now); got.Status != Pass {
+		t.Errorf("fresh cache, got: %v (%s)", got.Status, got.Message)
+	}
+	if got := CacheFile(f.Name(), stat(0600), nil, time.Hour, now.Add(2*time.Hour)); got.Status != Warn {
+		t.Errorf("stale cache, got: %v (%s)", got.Status, got.Message)
+	}
+	if got := CacheFile(f.Name(), stat(0644), nil, time.Hour, now); got.Status != Warn {
+		t.Errorf("world readable cache, got: %v (%s)", got.Status, got.Message)
+	}
+	if got := CacheFile(f.Name(), nil, os.ErrNotExist, time.Hour, now); got.Status != Warn {
+		t.Errorf("missing cache, got: %v (%s)", got.Status, got.Message)
+	}
+	if got := CacheFile(f.Name(), nil, errors.New("permission denied"), time.Hour, now); got.Status != Fail {
+		t.Errorf("unreadable cache, got: %v (%s)", got.Status, got.Message)
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	now := time.Now()
+	if got := ClockSkew("test", now, now.Add(time.Second)); got.Status != Pass {
+		t.Errorf("small skew, got: %v", got.Status)
+	}
+	if got := ClockSkew("test", now, now.Add(-time.Minute)); got.Status != Warn {
+		t.Errorf("medium skew, got: %v", got.Status)
+	}
+	if got := ClockSkew("test", now, now.Add(time.Hour)); got.Status != Fail {
+		t.Errorf("large skew, got: %v", got.Status)
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := Report{
+		Passed("one", "ok"),
+		Warned("two", "do something", "meh"),
+	}
+	if report.Failed() {
+		t.Errorf("expected report without failures")
+	}
+	report = append(report, Failed("three", "fix it", "broken"))
+	if !report.Failed() {
+		t.Errorf("expected report with failures")
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	report.Write(buf)
+	for _, expected := range []string{"[PASS] one: ok", "[WARN] two: meh", "fix: fix it", "1 passed, 1 warnings, 1 failed"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, buf.String())
+		}
+	}
+}
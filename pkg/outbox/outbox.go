@@ -0,0 +1,150 @@
+// Package outbox keeps a local record of the secrets a user has shared. Senders can't read other
+// drops, so the outbox is the only place that knows what was sent and to whom.
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dollarshaveclub/psst/pkg/storage"
+)
+
+const (
+	filePerms = 0600
+	dirPerms  = 0700
+)
+
+// Status of a sent secret for a single target
+type Status string
+
+// Possible statuses for a sent secret
+const (
+	Pending   Status = "pending"
+	Retrieved Status = "retrieved"
+	Expired   Status = "expired"
+	Revoked   Status = "revoked"
+)
+
+// Entry is a secret shared with a set of targets
+type Entry struct {
+	Name    string    `json:"name"`
+	Targets []string  `json:"targets"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	Revoked time.Time `json:"revoked"`
+	// Notify lists the notifier specs psst sent and psst watch run when a target retrieves the secret
+	Notify []string `json:"notify,omitempty"`
+	// Notified lists the targets the local notifiers already ran for
+	Notified []string `json:"notified,omitempty"`
+	// WrapAccessor is the accessor of the wrapping token for secrets shared with one instead of
+	// targets. The token unwraps the secret, so it is kept in the sender's drop instead of here.
+	WrapAccessor string `json:"wrap_accessor,omitempty"`
+	// Unwrapped is when the wrapping token was first seen used
+	Unwrapped time.Time `json:"unwrapped,omitempty"`
+}
+
+// Wrapped checks if the secret was shared with a wrapping token instead of targets
+func (e Entry) Wrapped() bool {
+	return e.WrapAccessor != ""
+}
+
+// WrapStatus returns the status of a wrapped secret given whether its wrapping token can still be
+// unwrapped. A token that is gone before it expired was used, but Vault doesn't say by whom: the
+// recipient and someone who intercepted the token both show as Retrieved.
+func (e Entry) WrapStatus(valid bool, now time.Time) Status {
+	switch {
+	case !e.Unwrapped.IsZero():
+		return Retrieved
+	case !e.Revoked.IsZero():
+		return Revoked
+	case valid:
+		return Pending
+	case !e.Expires.IsZero() && !now.Before(e.Expires):
+		return Expired
+	}
+	return Retrieved
+}
+
+// WasNotified checks if the local notifiers already ran for target
+func (e Entry) WasNotified(target string) bool {
+	for _, t := range e.Notified {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the status of the entry for a target given its retrieval receipt, if any. Receipts
+// written before the entry was created belong to an earlier share of the same name.
+func (e Entry) Status(receipt *storage.Receipt, now time.Time) Status {
+	switch {
+	case receipt != nil && !receipt.At.Before(e.Created.Truncate(time.Second)):
+		return Retrieved
+	case !e.Revoked.IsZero():
+		return Revoked
+	case !e.Expires.IsZero() && now.After(e.Expires):
+		return Expired
+	}
+	return Pending
+}
+
+// Outbox is the list of sent secrets saved at Path
+type Outbox struct {
+	Path    string
+	Entries []Entry
+}
+
+// Load reads the outbox at path, returning an empty outbox if nothing has been sent yet
+func Load(path string) (*Outbox, error) {
+	o := &Outbox{Path: path, Entries: []Entry{}}
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read outbox: %w", err)
+	}
+	if err := json.Unmarshal(buf, &o.Entries); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal outbox: %w", err)
+	}
+	return o, nil
+}
+
+// Add records a sent secret
+func (o *Outbox) Add(e Entry) {
+	o.Entries = append(o.Entries, e)
+}
+
+// Latest returns the most recent entry for name that hasn't been revoked, or nil if there is none
+func (o *Outbox) Latest(name string) *Entry {
+	var latest *Entry
+	for i, e := range o.Entries {
+		if e.Name != name || !e.Revoked.IsZero() {
+			continue
+		}
+		if latest == nil || e.Created.After(latest.Created) {
+			latest = &o.Entries[i]
+		}
+	}
+	return latest
+}
+
+// Save writes the outbox to disk, readable only by the current user
+func (o *Outbox) Save() error {
+	if err := os.MkdirAll(filepath.Dir(o.Path), dirPerms); err != nil {
+		return fmt.Errorf("unable to create outbox directory: %w", err)
+	}
+	buf, err := json.MarshalIndent(o.Entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal outbox: %w", err)
+	}
+	if err := ioutil.WriteFile(o.Path, buf, filePerms); err != nil {
+		return fmt.Errorf("unable to write outbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package outbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dollarshaveclub/psst/pkg/storage"
+)
+
+func TestStatus(t *testing.T) {
+	created := time.Date(2018, 6, 1, 12, 0, 0, 500, time.UTC)
+	now := created.Add(2 * time.Hour)
+
+	cases := map[string]struct {
+		Entry    Entry
+		Receipt  *storage.Receipt
+		Expected Status
+	}{
+		"PendingTest":        {Entry: Entry{Created: created}, Expected: Pending},
+		"RetrievedTest":      {Entry: Entry{Created: created}, Receipt: &storage.Receipt{By: "a", At: created.Add(time.Hour)}, Expected: Retrieved},
+		"SameSecondTest":     {Entry: Entry{Created: created}, Receipt: &storage.Receipt{By: "a", At: created.Truncate(time.Second)}, Expected: Retrieved},
+		"OldReceiptTest":     {Entry: Entry{Created: created}, Receipt: &storage.Receipt{By: "a", At: created.Add(-time.Hour)}, Expected: Pending},
+		"ExpiredTest":        {Entry: Entry{Created: created, Expires: created.Add(time.Hour)}, Expected: Expired},
+		"NotExpiredTest":     {Entry: Entry{Created: created, Expires: created.Add(3 * time.Hour)}, Expected: Pending},
+		"RevokedTest":        {Entry: Entry{Created: created, Revoked: created.Add(time.Hour)}, Expected: Revoked},
+		"RetrievedFirstTest": {Entry: Entry{Created: created, Expires: created.Add(time.Hour)}, Receipt: &storage.Receipt{By: "a", At: created}, Expected: Retrieved},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := c.Entry.Status(c.Receipt, now); got != c.Expected {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestWrapStatus(t *testing.T) {
+	created := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	now := created.Add(2 * time.Hour)
+
+	cases := map[string]struct {
+		Entry    Entry
+		Valid    bool
+		Expected Status
+	}{
+		"PendingTest":   {Entry: Entry{Created: created, Expires: created.Add(3 * time.Hour)}, Valid: true, Expected: Pending},
+		"UsedTest":      {Entry: Entry{Created: created, Expires: created.Add(3 * time.Hour)}, Expected: Retrieved},
+		"ExpiredTest":   {Entry: Entry{Created: created, Expires: created.Add(time.Hour)}, Expected: Expired},
+		"UnwrappedTest": {Entry: Entry{Created: created, Expires: created.Add(time.Hour), Unwrapped: created.Add(time.Minute)}, Expected: Retrieved},
+		"RevokedTest":   {Entry: Entry{Created: created, Expires: created.Add(3 * time.Hour), Revoked: created.Add(time.Minute)}, Expected: Revoked},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := c.Entry.WrapStatus(c.Valid, now); got != c.Expected {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestWrapped(t *testing.T) {
+	created := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	e := Entry{Created: created, Expires: created.Add(time.Hour), WrapAccessor: "acc"}
+	if !e.Wrapped() || e.WrapStatus(false, created.Add(2*time.Hour)) != Expired {
+		t.Errorf("got: %v %v, expected an expired wrapped secret", e.Wrapped(), e.WrapStatus(false, created.Add(2*time.Hour)))
+	}
+	if (Entry{Targets: []string{"bob"}}).Wrapped() {
+		t.Errorf("expected a secret shared with targets not to be wrapped")
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-outbox-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "psst", "outbox.json")
+
+	o, err := Load(path)
+	if err != nil {
+		t.Fatalf("unable to load missing outbox: %v", err)
+	}
+	if len(o.Entries) != 0 {
+		t.Errorf("got: %d entries, expected none", len(o.Entries))
+	}
+
+	created := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	o.Add(Entry{Name: "db", Targets: []string{"alice"}, Created: created})
+	o.Add(Entry{Name: "db", Targets: []string{"bob"}, Created: created.Add(time.Hour)})
+	o.Add(Entry{Name: "db", Targets: []string{"carol"}, Created: created.Add(2 * time.Hour), Revoked: created.Add(3 * time.Hour)})
+	if err := o.Save(); err != nil {
+		t.Fatalf("unable to save outbox: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unable to stat outbox: %v", err)
+	}
+	if info.Mode().Perm() != filePerms {
+		t.Errorf("got mode: %v, expected: %v", info.Mode().Perm(), os.FileMode(filePerms))
+	}
+
+	o, err = Load(path)
+	if err != nil {
+		t.Fatalf("unable to load outbox: %v", err)
+	}
+	if len(o.Entries) != 3 {
+		t.Fatalf("got: %d entries, expected: 3", len(o.Entries))
+	}
+
+	latest := o.Latest("db")
+	if latest == nil || latest.Targets[0] != "bob" {
+		t.Errorf("got: %+v, expected the latest unrevoked entry", latest)
+	}
+	if o.Latest("missing") != nil {
+		t.Errorf("expected no entry for an unknown name")
+	}
+}
+
+func TestWasNotified(t *testing.T) {
+	e := Entry{Targets: []string{"alice", "sre"}, Notified: []string{"sre"}}
+	if e.WasNotified("alice") {
+		t.Errorf("expected alice to not be notified")
+	}
+	if !e.WasNotified("sre") {
+		t.Errorf("expected sre to be notified")
+	}
+}
@@ -0,0 +1,232 @@
+// Package secretgen creates new secrets using crypto/rand so they can be shared without ever
+// existing outside of psst.
+package secretgen
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	passwordChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!#$%&*+-=?@^_~"
+
+	defaultPasswordLength = 32
+	defaultHexLength      = 64
+	minLength             = 8
+	maxLength             = 4096
+
+	defaultCommonName = "psst"
+	certValidity      = 365 * 24 * time.Hour
+)
+
+// Generated is a newly created secret. Public holds the part of a key pair that is safe to share
+// openly, if there is one.
+type Generated struct {
+	Value  string
+	Public string
+}
+
+type generator func(arg string) (*Generated, error)
+
+var generators = map[string]generator{
+	"password":        password,
+	"hex":             hexString,
+	"uuid":            uuid,
+	"ssh-ed25519":     sshEd25519,
+	"x509-selfsigned": x509SelfSigned,
+}
+
+// Kinds returns the supported kinds of secrets
+func Kinds() []string {
+	return []string{"password[:length]", "hex[:length]", "uuid", "ssh-ed25519[:comment]", "x509-selfsigned[:common-name]"}
+}
+
+// Generate creates a secret from a spec in the form kind[:argument], e.g. "password:32"
+func Generate(spec string) (*Generated, error) {
+	kind, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, arg = spec[:i], spec[i+1:]
+	}
+
+	g, ok := generators[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown secret kind '%s', use one of %s", kind, strings.Join(Kinds(), ", "))
+	}
+	return g(arg)
+}
+
+func length(arg string, def int) (int, error) {
+	if arg == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid length '%s'", arg)
+	}
+	if n < minLength || n > maxLength {
+		return 0, fmt.Errorf("length must be between %d and %d", minLength, maxLength)
+	}
+	return n, nil
+}
+
+func password(arg string) (*Generated, error) {
+	n, err := length(arg, defaultPasswordLength)
+	if err != nil {
+		return nil, err
+	}
+
+	max := big.NewInt(int64(len(passwordChars)))
+	buf := make([]byte, n)
+	for i := range buf {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to generate random password")
+		}
+		buf[i] = passwordChars[idx.Int64()]
+	}
+	return &Generated{Value: string(buf)}, nil
+}
+
+// hexString creates a random string of length hex characters
+func hexString(arg string) (*Generated, error) {
+	n, err := length(arg, defaultHexLength)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, (n+1)/2)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, errors.Wrap(err, "unable to generate random bytes")
+	}
+	return &Generated{Value: hex.EncodeToString(buf)[:n]}, nil
+}
+
+// uuid creates a random (version 4) UUID
+func uuid(arg string) (*Generated, error) {
+	if arg != "" {
+		return nil, errors.New("uuid does not take an argument")
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "unable to generate random bytes")
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return &Generated{Value: fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])}, nil
+}
+
+// sshEd25519 creates an Ed25519 key pair with the private key in OpenSSH format
+func sshEd25519(comment string) (*Generated, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate ed25519 key")
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create ssh public key")
+	}
+
+	block, err := marshalOpenSSHEd25519(sshPub, priv, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+	if comment != "" {
+		authorized = authorized + " " + comment
+	}
+	return &Generated{Value: string(pem.EncodeToMemory(block)), Public: authorized + "\n"}, nil
+}
+
+// marshalOpenSSHEd25519 encodes an unencrypted key in the openssh-key-v1 format described in
+// PROTOCOL.key of the OpenSSH sources
+func marshalOpenSSHEd25519(pub ssh.PublicKey, priv ed25519.PrivateKey, comment string) (*pem.Block, error) {
+	check := make([]byte, 4)
+	if _, err := rand.Read(check); err != nil {
+		return nil, errors.Wrap(err, "unable to generate check bytes")
+	}
+
+	private := bytes.NewBuffer([]byte{})
+	private.Write(check)
+	private.Write(check)
+	writeString(private, []byte(ssh.KeyAlgoED25519))
+	writeString(private, priv.Public().(ed25519.PublicKey))
+	writeString(private, priv)
+	writeString(private, []byte(comment))
+	for i := 1; private.Len()%8 != 0; i++ {
+		private.WriteByte(byte(i))
+	}
+
+	buf := bytes.NewBufferString("openssh-key-v1\x00")
+	writeString(buf, []byte("none"))
+	writeString(buf, []byte("none"))
+	writeString(buf, []byte{})
+	binary.Write(buf, binary.BigEndian, uint32(1))
+	writeString(buf, pub.Marshal())
+	writeString(buf, private.Bytes())
+
+	return &pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: buf.Bytes()}, nil
+}
+
+func writeString(buf *bytes.Buffer, s []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.Write(s)
+}
+
+// x509SelfSigned creates a self-signed ECDSA certificate. The secret holds both the certificate and
+// the private key.
+func x509SelfSigned(cn string) (*Generated, error) {
+	if cn == "" {
+		cn = defaultCommonName
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate ecdsa key")
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate serial number")
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              []string{cn},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create certificate")
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal private key")
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	priv := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	return &Generated{Value: string(cert) + string(priv), Public: string(cert)}, nil
+}
@@ -0,0 +1,115 @@
+package secretgen
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGenerate(t *testing.T) {
+	cases := map[string]struct {
+		Spec  string
+		Check func(*Generated) bool
+		Err   bool
+	}{
+		"PasswordTest": {
+			Spec: "password:40",
+			Check: func(g *Generated) bool {
+				for _, r := range g.Value {
+					if !strings.ContainsRune(passwordChars, r) {
+						return false
+					}
+				}
+				return len(g.Value) == 40
+			},
+		},
+		"PasswordDefaultTest": {
+			Spec:  "password",
+			Check: func(g *Generated) bool { return len(g.Value) == defaultPasswordLength },
+		},
+		"PasswordTooShortTest": {
+			Spec: "password:4",
+			Err:  true,
+		},
+		"HexTest": {
+			Spec: "hex:63",
+			Check: func(g *Generated) bool {
+				_, err := hex.DecodeString(g.Value + "0")
+				return len(g.Value) == 63 && err == nil
+			},
+		},
+		"UUIDTest": {
+			Spec: "uuid",
+			Check: func(g *Generated) bool {
+				return regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(g.Value)
+			},
+		},
+		"SSHTest": {
+			Spec: "ssh-ed25519:deploy@example",
+			Check: func(g *Generated) bool {
+				signer, err := ssh.ParsePrivateKey([]byte(g.Value))
+				if err != nil {
+					t.Logf("unable to parse private key: %v", err)
+					return false
+				}
+				pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(g.Public))
+				if err != nil {
+					t.Logf("unable to parse public key: %v", err)
+					return false
+				}
+				return comment == "deploy@example" && string(pub.Marshal()) == string(signer.PublicKey().Marshal())
+			},
+		},
+		"X509Test": {
+			Spec: "x509-selfsigned:example.com",
+			Check: func(g *Generated) bool {
+				cert, err := tls.X509KeyPair([]byte(g.Value), []byte(g.Value))
+				if err != nil {
+					t.Logf("unable to parse key pair: %v", err)
+					return false
+				}
+				return len(cert.Certificate) == 1 && strings.HasPrefix(g.Public, "-----BEGIN CERTIFICATE-----")
+			},
+		},
+		"UnknownTest": {
+			Spec: "rot13",
+			Err:  true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			g, err := Generate(c.Spec)
+			if c.Err {
+				if err == nil {
+					t.Errorf("Name: %s, expected an error", name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Name: %s, unexpected error: %v", name, err)
+			}
+			if !c.Check(g) {
+				t.Errorf("Name: %s, unexpected secret: %+v", name, g)
+			}
+		})
+	}
+}
+
+func TestGenerateUnique(t *testing.T) {
+	a, err := Generate("password")
+	if err != nil {
+		t.Fatalf("unable to generate: %v", err)
+	}
+	b, err := Generate("password")
+	if err != nil {
+		t.Fatalf("unable to generate: %v", err)
+	}
+	if a.Value == b.Value {
+		t.Errorf("generated the same password twice")
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// auditField is the field holding an audit event
+	auditField = "event"
+	// auditKeyField is the field holding the key audit value hashes are made with
+	auditKeyField = "key"
+)
+
+// ListAudit lists the audit events stored under p
+func (v *VaultStore) ListAudit(ctx context.Context, p string) ([]string, error) {
+	return v.listKeys(ctx, p)
+}
+
+// ReadAudit returns the audit event stored at p
+func (v *VaultStore) ReadAudit(ctx context.Context, p string) (string, error) {
+	event, err := v.readField(ctx, p, auditField)
+	if err != nil {
+		return "", err
+	}
+	if event == "" {
+		return "", fmt.Errorf("%w: no audit event at %s", ErrNotFound, p)
+	}
+	return event, nil
+}
+
+// WriteAudit stores an audit event at p. Policies should only allow creating audit events, so an
+// event can't be overwritten once stored.
+func (v *VaultStore) WriteAudit(ctx context.Context, p, event string) error {
+	if _, err := v.request(ctx, "PUT", p, map[string]interface{}{auditField: event}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadAuditKey returns the key audit value hashes are made with, stored at p
+func (v *VaultStore) ReadAuditKey(ctx context.Context, p string) (string, error) {
+	key, err := v.readField(ctx, p, auditKeyField)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", fmt.Errorf("%w: no audit key at %s", ErrNotFound, p)
+	}
+	return key, nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestAuditEvents(t *testing.T) {
+	// Answers like Vault with a policy that only allows creating audit events
+	events := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, "/v1/")
+		switch {
+		case r.Method == "PUT" || r.Method == "POST":
+			if _, ok := events[p]; ok {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+				return
+			}
+			body := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&body)
+			events[p] = body[auditField]
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Query().Get("list") == "true":
+			keys := []string{}
+			for k := range events {
+				if strings.HasPrefix(k, p+"/") {
+					keys = append(keys, strings.TrimPrefix(k, p+"/"))
+				}
+			}
+			if len(keys) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			buf, _ := json.Marshal(map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+			w.Write(buf)
+		default:
+			e, ok := events[p]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"errors": []}`)
+				return
+			}
+			fmt.Fprintf(w, `{"data": {"event": %q}}`, e)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := api.NewClient(&api.Config{Address: srv.URL, HttpClient: srv.Client()})
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	client.SetToken("alice")
+	v := &VaultStore{client}
+	ctx := context.Background()
+
+	keys, err := v.ListAudit(ctx, "secret/psst-audit/alice")
+	if err != nil || len(keys) != 0 {
+		t.Errorf("got: %v %v, expected no events yet", keys, err)
+	}
+
+	if err := v.WriteAudit(ctx, "secret/psst-audit/alice/0000000000", `{"action":"share"}`); err != nil {
+		t.Fatalf("unable to write audit event: %v", err)
+	}
+	if err := v.WriteAudit(ctx, "secret/psst-audit/alice/0000000000", `{"action":"get"}`); !errors.Is(err, ErrForbidden) {
+		t.Errorf("got: %v, expected: %v", err, ErrForbidden)
+	}
+
+	keys, err = v.ListAudit(ctx, "secret/psst-audit/alice")
+	if err != nil || !reflect.DeepEqual(keys, []string{"0000000000"}) {
+		t.Errorf("got: %v %v, expected: [0000000000]", keys, err)
+	}
+	event, err := v.ReadAudit(ctx, "secret/psst-audit/alice/0000000000")
+	if err != nil || event != `{"action":"share"}` {
+		t.Errorf("got: %s %v, expected the first event", event, err)
+	}
+	if _, err := v.ReadAudit(ctx, "secret/psst-audit/alice/0000000001"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got: %v, expected: %v", err, ErrNotFound)
+	}
+}
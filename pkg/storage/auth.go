@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// AuthGitHub logs in with GITHUB_TOKEN, the default since psst needs it for the directory anyway
+	AuthGitHub = "github"
+	// AuthOIDC logs in through a browser with an OIDC provider
+	AuthOIDC = "oidc"
+	// AuthAppRole logs in with a role ID and the secret ID in VAULT_SECRET_ID, for automation
+	AuthAppRole = "approle"
+	// AuthUserpass logs in with a username and the password in VAULT_PASSWORD or prompted for
+	AuthUserpass = "userpass"
+
+	// TokenRenewWithin is how close to expiring a token must be before psst renews it
+	TokenRenewWithin = 24 * time.Hour
+	// tokenLoginWithin is how close to expiring a token that can't be renewed must be before psst
+	// logs in again
+	tokenLoginWithin = 5 * time.Minute
+
+	// oidcCallbackAddress is where the browser is sent back to after logging in with OIDC, the
+	// same default as the Vault CLI so roles allowing it work with psst too
+	oidcCallbackAddress = "localhost:8250"
+	oidcCallbackPath    = "/oidc/callback"
+
+	tokenFilePerms = 0600
+)
+
+// Token actions decided by tokenAction
+const (
+	tokenKeep  = "keep"
+	tokenRenew = "renew"
+	tokenLogin = "login"
+)
+
+// AuthConfig selects the auth method psst logs in with when there is no valid Vault token. Secrets
+// never come from the config: they are read from the environment or prompted for.
+type AuthConfig struct {
+	// Method is github, oidc, approle or userpass, github when empty
+	Method string `json:"method,omitempty"`
+	// Path is where the auth method is mounted, auth/<method> when empty
+	Path string `json:"path,omitempty"`
+	// Role is the OIDC role, or the role ID for AppRole
+	Role string `json:"role,omitempty"`
+	// Username is the userpass username
+	Username string `json:"username,omitempty"`
+}
+
+// Prompter asks the user for what logging in needs
+type Prompter interface {
+	// Password asks for a secret without echoing it
+	Password(prompt string) (string, error)
+	// OpenURL sends the user to url to log in
+	OpenURL(url string) error
+}
+
+// Validate checks that the auth method is known and has what it needs
+func (a AuthConfig) Validate() error {
+	switch a.method() {
+	case AuthGitHub, AuthUserpass:
+		if a.method() == AuthUserpass && a.Username == "" {
+			return fmt.Errorf("userpass auth needs a username")
+		}
+	case AuthOIDC, AuthAppRole:
+		if a.Role == "" {
+			return fmt.Errorf("%s auth needs a role", a.method())
+		}
+	default:
+		return fmt.Errorf("unknown auth method %s, expected github, oidc, approle or userpass", a.Method)
+	}
+	return nil
+}
+
+func (a AuthConfig) method() string {
+	if a.Method == "" {
+		return AuthGitHub
+	}
+	return a.Method
+}
+
+func (a AuthConfig) path() string {
+	if a.Path == "" {
+		return path.Join("auth", a.method())
+	}
+	return strings.Trim(a.Path, "/")
+}
+
+// TokenPath is where the Vault CLI and psst keep the token of the last login
+func TokenPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".vault-token")
+}
+
+// tokenAction decides what to do with a token that has ttl left. A ttl of zero never expires.
+func tokenAction(ttl time.Duration, renewable bool) string {
+	switch {
+	case ttl == 0:
+		return tokenKeep
+	case renewable && ttl < TokenRenewWithin:
+		return tokenRenew
+	case ttl < tokenLoginWithin:
+		return tokenLogin
+	}
+	return tokenKeep
+}
+
+// loginRequest returns the path and data to log in with the github, approle or userpass methods
+func loginRequest(a AuthConfig, getenv func(string) string, prompt Prompter) (string, map[string]interface{}, error) {
+	switch a.method() {
+	case AuthGitHub:
+		token := getenv("GITHUB_TOKEN")
+		if token == "" {
+			return "", nil, fmt.Errorf("GITHUB_TOKEN is needed to log in to Vault")
+		}
+		return path.Join(a.path(), "login"), map[string]interface{}{"token": token}, nil
+	case AuthAppRole:
+		secretID := getenv("VAULT_SECRET_ID")
+		if secretID == "" {
+			return "", nil, fmt.Errorf("VAULT_SECRET_ID is needed to log in to Vault with AppRole")
+		}
+		return path.Join(a.path(), "login"), map[string]interface{}{"role_id": a.Role, "secret_id": secretID}, nil
+	case AuthUserpass:
+		password := getenv("VAULT_PASSWORD")
+		if password == "" {
+			if prompt == nil {
+				return "", nil, fmt.Errorf("VAULT_PASSWORD is needed to log in to Vault as %s", a.Username)
+			}
+			var err error
+			if password, err = prompt.Password(fmt.Sprintf("Vault password for %s: ", a.Username)); err != nil {
+				return "", nil, fmt.Errorf("unable to read password: %+v", err)
+			}
+		}
+		return path.Join(a.path(), "login", a.Username), map[string]interface{}{"password": password}, nil
+	}
+	return "", nil, fmt.Errorf("unknown auth method %s", a.Method)
+}
+
+// EnsureToken makes sure the client has a token that isn't about to expire. Tokens close to
+// expiring are renewed and, without a valid token, psst logs in with the auth method in conf and
+// saves the token where the Vault CLI does. A token in VAULT_TOKEN is used as is.
+func (v *VaultStore) EnsureToken(ctx context.Context, conf AuthConfig, prompt Prompter) error {
+	if os.Getenv("VAULT_TOKEN") != "" {
+		return nil
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	action := tokenLogin
+	if v.Token() != "" {
+		self, err := v.request(ctx, "GET", "auth/token/lookup-self", nil)
+		switch {
+		case err == nil && self != nil:
+			ttl, _ := self.TokenTTL()
+			renewable, _ := self.TokenIsRenewable()
+			action = tokenAction(ttl, renewable)
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case !isForbidden(err):
+			return fmt.Errorf("unable to look up Vault token: %w", err)
+		}
+	}
+
+	switch action {
+	case tokenKeep:
+		return nil
+	case tokenRenew:
+		if _, err := v.request(ctx, "POST", "auth/token/renew-self", map[string]interface{}{}); err == nil {
+			return nil
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// The token may have reached its max TTL, it works until then
+		self, err := v.request(ctx, "GET", "auth/token/lookup-self", nil)
+		if err != nil || self == nil {
+			return fmt.Errorf("unable to renew Vault token: %w", err)
+		}
+		if ttl, _ := self.TokenTTL(); ttl >= tokenLoginWithin {
+			return nil
+		}
+	}
+	return v.Login(ctx, conf, prompt)
+}
+
+// Login logs in with the auth method in conf, uses the new token and saves it where the Vault CLI
+// keeps it
+func (v *VaultStore) Login(ctx context.Context, conf AuthConfig, prompt Prompter) error {
+	v.ClearToken()
+
+	var token string
+	var err error
+	if conf.method() == AuthOIDC {
+		token, err = v.loginOIDC(ctx, conf, prompt)
+	} else {
+		var p string
+		var data map[string]interface{}
+		if p, data, err = loginRequest(conf, os.Getenv, prompt); err != nil {
+			return err
+		}
+		token, err = v.login(ctx, p, data)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to log in to Vault with %s: %w", conf.method(), err)
+	}
+
+	v.SetToken(token)
+	if err := ioutil.WriteFile(TokenPath(), []byte(token), tokenFilePerms); err != nil {
+		return fmt.Errorf("unable to save Vault token: %+v", err)
+	}
+	return nil
+}
+
+func (v *VaultStore) login(ctx context.Context, p string, data map[string]interface{}) (string, error) {
+	secret, err := v.request(ctx, "POST", p, data)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", fmt.Errorf("no token in the response from %s", p)
+	}
+	return secret.Auth.ClientToken, nil
+}
+
+// loginOIDC sends the user to the OIDC provider and waits for the browser to come back with the
+// code Vault exchanges for a token
+func (v *VaultStore) loginOIDC(ctx context.Context, conf AuthConfig, prompt Prompter) (string, error) {
+	if prompt == nil {
+		return "", fmt.Errorf("logging in with OIDC needs a browser")
+	}
+	redirect := "http://" + oidcCallbackAddress + oidcCallbackPath
+	secret, err := v.request(ctx, "POST", path.Join(conf.path(), "oidc/auth_url"), map[string]interface{}{"role": conf.Role, "redirect_uri": redirect})
+	if err != nil {
+		return "", err
+	}
+	authURL := ""
+	if secret != nil {
+		authURL, _ = secret.Data["auth_url"].(string)
+	}
+	if authURL == "" {
+		return "", fmt.Errorf("role %s doesn't allow %s as a redirect URI", conf.Role, redirect)
+	}
+
+	l, err := net.Listen("tcp", oidcCallbackAddress)
+	if err != nil {
+		return "", fmt.Errorf("unable to listen for the OIDC callback: %+v", err)
+	}
+	type result struct {
+		token string
+		err   error
+	}
+	done := make(chan result, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != oidcCallbackPath {
+			http.NotFound(w, r)
+			return
+		}
+		req := v.NewRequest("GET", "/v1/"+path.Join(conf.path(), "oidc/callback"))
+		for _, k := range []string{"state", "code", "id_token"} {
+			req.Params.Set(k, r.URL.Query().Get(k))
+		}
+		res := result{}
+		secret, err := v.do(ctx, req)
+		switch {
+		case err != nil:
+			res.err = err
+		case secret == nil || secret.Auth == nil:
+			res.err = fmt.Errorf("no token in the OIDC callback response")
+		default:
+			res.token = secret.Auth.ClientToken
+		}
+		if res.err != nil {
+			fmt.Fprintf(w, "Logging in to Vault failed: %v\n", res.err)
+		} else {
+			fmt.Fprintln(w, "Logged in to Vault, you can close this window.")
+		}
+		select {
+		case done <- res:
+		default:
+		}
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	if err := prompt.OpenURL(authURL); err != nil {
+		return "", err
+	}
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-done:
+		return res.token, res.err
+	}
+}
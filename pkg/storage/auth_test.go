@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+type fixedPrompter struct {
+	password string
+}
+
+func (f fixedPrompter) Password(prompt string) (string, error) {
+	return f.password, nil
+}
+
+func (f fixedPrompter) OpenURL(url string) error {
+	return nil
+}
+
+func TestTokenAction(t *testing.T) {
+	cases := map[string]struct {
+		TTL       time.Duration
+		Renewable bool
+		Expected  string
+	}{
+		"NoExpiryTest":        {TTL: 0, Expected: tokenKeep},
+		"FreshTest":           {TTL: 72 * time.Hour, Renewable: true, Expected: tokenKeep},
+		"NearExpiryTest":      {TTL: time.Hour, Renewable: true, Expected: tokenRenew},
+		"NotRenewableTest":    {TTL: time.Hour, Expected: tokenKeep},
+		"AlmostExpiredTest":   {TTL: time.Minute, Expected: tokenLogin},
+		"RenewExpiringTest":   {TTL: time.Minute, Renewable: true, Expected: tokenRenew},
+		"NegativeExpiredTest": {TTL: -time.Second, Expected: tokenLogin},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := tokenAction(c.TTL, c.Renewable); got != c.Expected {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestLoginRequest(t *testing.T) {
+	env := map[string]string{"GITHUB_TOKEN": "gh", "VAULT_SECRET_ID": "sid"}
+	getenv := func(k string) string { return env[k] }
+
+	cases := map[string]struct {
+		Auth         AuthConfig
+		ExpectedPath string
+		ExpectedData map[string]interface{}
+	}{
+		"GitHubTest":    {Auth: AuthConfig{}, ExpectedPath: "auth/github/login", ExpectedData: map[string]interface{}{"token": "gh"}},
+		"MountPathTest": {Auth: AuthConfig{Method: "github", Path: "/auth/gh-org/"}, ExpectedPath: "auth/gh-org/login", ExpectedData: map[string]interface{}{"token": "gh"}},
+		"AppRoleTest":   {Auth: AuthConfig{Method: "approle", Role: "rid"}, ExpectedPath: "auth/approle/login", ExpectedData: map[string]interface{}{"role_id": "rid", "secret_id": "sid"}},
+		"UserpassTest":  {Auth: AuthConfig{Method: "userpass", Username: "alice"}, ExpectedPath: "auth/userpass/login/alice", ExpectedData: map[string]interface{}{"password": "hunter2"}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, data, err := loginRequest(c.Auth, getenv, fixedPrompter{password: "hunter2"})
+			if err != nil {
+				t.Fatalf("Name: %s, unexpected error: %v", name, err)
+			}
+			if p != c.ExpectedPath || !reflect.DeepEqual(data, c.ExpectedData) {
+				t.Errorf("Name: %s, got: %s %v, expected: %s %v", name, p, data, c.ExpectedPath, c.ExpectedData)
+			}
+		})
+	}
+
+	if _, _, err := loginRequest(AuthConfig{}, func(string) string { return "" }, nil); err == nil {
+		t.Errorf("expected an error without GITHUB_TOKEN")
+	}
+	if _, _, err := loginRequest(AuthConfig{Method: "userpass", Username: "alice"}, func(string) string { return "" }, nil); err == nil {
+		t.Errorf("expected an error without a password or a way to ask for it")
+	}
+}
+
+func TestLoadVaultConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-config-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf, err := LoadVaultConfig(filepath.Join(dir, "missing.json"))
+	if err != nil || conf.Auth.method() != AuthGitHub {
+		t.Errorf("got: %+v %v, expected GitHub auth without a config file", conf, err)
+	}
+
+	cases := map[string]struct {
+		Content  string
+		Expected AuthConfig
+		Valid    bool
+	}{
+		"OIDCTest":        {Content: `{"vault": {"auth": {"method": "oidc", "role": "psst"}}}`, Expected: AuthConfig{Method: "oidc", Role: "psst"}, Valid: true},
+		"OtherKeysTest":   {Content: `{"other": true}`, Valid: true},
+		"NoRoleTest":      {Content: `{"vault": {"auth": {"method": "approle"}}}`},
+		"NoUsernameTest":  {Content: `{"vault": {"auth": {"method": "userpass"}}}`},
+		"UnknownTest":     {Content: `{"vault": {"auth": {"method": "ldap"}}}`},
+		"SyntaxErrorTest": {Content: `{"vault": `},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := filepath.Join(dir, name+".json")
+			if err := ioutil.WriteFile(f, []byte(c.Content), 0600); err != nil {
+				t.Fatalf("unable to write config: %v", err)
+			}
+			conf, err := LoadVaultConfig(f)
+			if (err == nil) != c.Valid {
+				t.Fatalf("Name: %s, got: %v, expected valid: %v", name, err, c.Valid)
+			}
+			if err == nil && conf.Auth != c.Expected {
+				t.Errorf("Name: %s, got: %+v, expected: %+v", name, conf.Auth, c.Expected)
+			}
+		})
+	}
+}
+
+func TestEnsureToken(t *testing.T) {
+	home, err := ioutil.TempDir("", "psst-home-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(home)
+	t.Setenv("HOME", home)
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "gh")
+
+	cases := map[string]struct {
+		Token    string
+		TTL      int
+		Expected string
+		Calls    []string
+	}{
+		"FreshTest":   {Token: "old", TTL: 7200000, Expected: "old", Calls: []string{"lookup-self"}},
+		"RenewTest":   {Token: "old", TTL: 3600, Expected: "old", Calls: []string{"lookup-self", "renew-self"}},
+		"InvalidTest": {Token: "bad", Expected: "new", Calls: []string{"lookup-self", "login"}},
+		"NoTokenTest": {Expected: "new", Calls: []string{"login"}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			calls := []string{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, path.Base(r.URL.Path))
+				switch r.URL.Path {
+				case "/v1/auth/token/lookup-self":
+					if r.Header.Get("X-Vault-Token") == "bad" {
+						w.WriteHeader(http.StatusForbidden)
+						fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+						return
+					}
+					fmt.Fprintf(w, `{"data": {"ttl": %d, "renewable": true}}`, c.TTL)
+				case "/v1/auth/token/renew-self":
+					fmt.Fprint(w, `{"auth": {"client_token": "old", "renewable": true}}`)
+				case "/v1/auth/github/login":
+					fmt.Fprint(w, `{"auth": {"client_token": "new"}}`)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			client, err := api.NewClient(&api.Config{Address: srv.URL, HttpClient: srv.Client()})
+			if err != nil {
+				t.Fatalf("unable to create client: %v", err)
+			}
+			client.SetToken(c.Token)
+			v := &VaultStore{client}
+
+			if err := v.EnsureToken(context.Background(), AuthConfig{}, nil); err != nil {
+				t.Fatalf("Name: %s, unexpected error: %v", name, err)
+			}
+			if v.Token() != c.Expected || !reflect.DeepEqual(calls, c.Calls) {
+				t.Errorf("Name: %s, got: %s %v, expected: %s %v", name, v.Token(), calls, c.Expected, c.Calls)
+			}
+		})
+	}
+
+	saved, err := ioutil.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil || string(saved) != "new" {
+		t.Errorf("got: %q %v, expected the new token to be saved", saved, err)
+	}
+}
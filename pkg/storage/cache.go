@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	cacheFilePerms = 0600
+	cacheDirPerms  = 0700
+)
+
+// ListCache wraps a Backend and remembers the names of the secrets in each drop on disk, including
+// those inside folders such as prod/db once the drop was walked. Only names are cached, never values.
+type ListCache struct {
+	Backend
+
+	Dir string
+}
+
+// NewListCache returns a Backend that caches listed secret names in dir
+func NewListCache(backend Backend, dir string) *ListCache {
+	return &ListCache{Backend: backend, Dir: dir}
+}
+
+// List will list the secrets available to entity and save the listed names to the cache. Folders
+// aren't descended into, Walk does that.
+func (l *ListCache) List(ctx context.Context, entity string) ([]string, error) {
+	names, err := l.Backend.List(ctx, entity)
+	if err != nil {
+		return names, err
+	}
+
+	// A failure to cache should never stop the user from seeing their secrets. Folders inside a drop
+	// and the list of drops itself aren't cached on their own.
+	if entity != "" && !strings.Contains(entity, "/") {
+		_ = l.save(entity, l.keepWalked(entity, names))
+	}
+	return names, nil
+}
+
+// keepWalked returns listed with the names the last walk of the drop of entity cached inside the
+// folders still listed, so listing the top of a drop doesn't forget the names completion offers
+// inside its folders
+func (l *ListCache) keepWalked(entity string, listed []string) []string {
+	if !hasFolder(listed) {
+		return listed
+	}
+	cached, err := CachedList(l.Dir, entity)
+	if err != nil {
+		return listed
+	}
+	folders := make(map[string]struct{})
+	for _, n := range listed {
+		if IsFolder(n) {
+			folders[n] = struct{}{}
+		}
+	}
+	names := append([]string{}, listed...)
+	for _, n := range cached {
+		i := strings.Index(n, "/")
+		if i < 0 || IsFolder(n) {
+			continue
+		}
+		if _, ok := folders[n[:i+1]]; ok {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// walk lists every secret in the drop of entity like Walk and saves the names to the cache
+func (l *ListCache) walk(ctx context.Context, entity string) ([]string, error) {
+	listed, err := l.Backend.List(ctx, entity)
+	if err != nil {
+		return []string{}, err
+	}
+	names, err := walkFolders(ctx, l.Backend, entity, listed)
+	if err != nil {
+		return names, err
+	}
+	_ = l.save(entity, names)
+	return names, nil
+}
+
+func (l *ListCache) save(entity string, names []string) error {
+	if err := os.MkdirAll(l.Dir, cacheDirPerms); err != nil {
+		return errors.Wrap(err, "unable to create secret cache directory")
+	}
+	buf, err := json.Marshal(names)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal secret names")
+	}
+	return ioutil.WriteFile(filepath.Join(l.Dir, entity), buf, cacheFilePerms)
+}
+
+// CachedList returns the secret names last listed for entity without contacting the storage backend
+func CachedList(dir, entity string) ([]string, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, entity))
+	if err != nil {
+		return []string{}, err
+	}
+	names := []string{}
+	if err := json.Unmarshal(buf, &names); err != nil {
+		return []string{}, errors.Wrap(err, "unable to unmarshal cached secret names")
+	}
+	return names, nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type listBackend struct {
+	Backend
+
+	names map[string][]string
+}
+
+func (l listBackend) List(ctx context.Context, entity string) ([]string, error) {
+	return l.names[entity], nil
+}
+
+func TestListCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-list-cache-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cacheDir := filepath.Join(dir, "secrets")
+
+	backend := listBackend{names: map[string][]string{"test-user": []string{"one", "two"}}}
+	l := NewListCache(backend, cacheDir)
+
+	if _, err := CachedList(cacheDir, "test-user"); err == nil {
+		t.Fatalf("expected an error before anything was listed")
+	}
+
+	if _, err := l.List(context.Background(), "test-user"); err != nil {
+		t.Fatalf("unable to list: %v", err)
+	}
+	got, err := CachedList(cacheDir, "test-user")
+	if err != nil {
+		t.Fatalf("unable to read cache: %v", err)
+	}
+	if len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Errorf("got: %v, expected: [one two]", got)
+	}
+
+	info, err := os.Stat(filepath.Join(cacheDir, "test-user"))
+	if err != nil {
+		t.Fatalf("unable to stat cache file: %v", err)
+	}
+	if info.Mode().Perm() != cacheFilePerms {
+		t.Errorf("got mode: %v, expected: %v", info.Mode().Perm(), os.FileMode(cacheFilePerms))
+	}
+}
+
+func TestListCacheFolders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-list-cache-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	backend := listBackend{names: map[string][]string{
+		"test-user":          []string{"prod/", "one"},
+		"test-user/prod":     []string{"api/", "db"},
+		"test-user/prod/api": []string{"key"},
+	}}
+	walked := []string{"one", "prod/api/key", "prod/db"}
+
+	cases := map[string]struct {
+		list     func(l *ListCache) ([]string, error)
+		expected []string
+	}{
+		"ListTest": {
+			list: func(l *ListCache) ([]string, error) {
+				return l.List(context.Background(), "test-user")
+			},
+			expected: []string{"prod/", "one"},
+		},
+		"WalkTest": {
+			list: func(l *ListCache) ([]string, error) {
+				return Walk(context.Background(), l, "test-user")
+			},
+			expected: walked,
+		},
+		"WalkThenListTest": {
+			list: func(l *ListCache) ([]string, error) {
+				if _, err := Walk(context.Background(), l, "test-user"); err != nil {
+					return nil, err
+				}
+				return l.List(context.Background(), "test-user")
+			},
+			expected: []string{"prod/", "one", "prod/api/key", "prod/db"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cacheDir := filepath.Join(dir, name)
+			if _, err := c.list(NewListCache(backend, cacheDir)); err != nil {
+				t.Fatalf("Name: %s, unable to list: %v", name, err)
+			}
+			got, err := CachedList(cacheDir, "test-user")
+			if err != nil || !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("Name: %s, got: %v %v, expected: %v", name, got, err, c.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// namespaceHeader selects the Vault Enterprise namespace of a request
+const namespaceHeader = "X-Vault-Namespace"
+
+// VaultConfig is how psst connects and logs in to Vault. Settings left empty keep what the VAULT_*
+// variables set, or the Vault client defaults.
+type VaultConfig struct {
+	// Address of the Vault server, like https://vault.example.com:8200
+	Address string `json:"address,omitempty"`
+	// Namespace is the Vault Enterprise namespace psst works in
+	Namespace string `json:"namespace,omitempty"`
+	// TLS configures how the Vault server is verified and how psst identifies itself to it
+	TLS TLSConfig `json:"tls"`
+	// Timeout of each request to Vault, like "30s"
+	Timeout string `json:"timeout,omitempty"`
+	// MaxRetries is how many times requests failing with a server error are retried
+	MaxRetries *int `json:"max_retries,omitempty"`
+	// Auth selects how psst logs in when there is no valid token
+	Auth AuthConfig `json:"auth"`
+}
+
+// TLSConfig points to the files used to connect to Vault over TLS
+type TLSConfig struct {
+	// CACert is a PEM file with the CA certificates the Vault server certificate is verified with
+	CACert string `json:"ca_cert,omitempty"`
+	// CAPath is a directory of PEM files with CA certificates
+	CAPath string `json:"ca_path,omitempty"`
+	// ClientCert and ClientKey are PEM files psst authenticates to Vault with
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	// ServerName is the name the Vault server certificate is verified against instead of the host
+	// of the address
+	ServerName string `json:"server_name,omitempty"`
+}
+
+// Validate checks the settings and that the TLS files can be used
+func (c VaultConfig) Validate() error {
+	if c.Address != "" {
+		u, err := url.Parse(c.Address)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("address %s must look like https://vault.example.com:8200", c.Address)
+		}
+	}
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("timeout %s must be a positive duration like 30s", c.Timeout)
+		}
+	}
+	if c.MaxRetries != nil && *c.MaxRetries < 0 {
+		return fmt.Errorf("max_retries can't be negative")
+	}
+	if err := c.TLS.Validate(); err != nil {
+		return err
+	}
+	return c.Auth.Validate()
+}
+
+// Validate checks that the CA certificates parse and that the client certificate matches its key
+func (t TLSConfig) Validate() error {
+	if t.CACert != "" {
+		b, err := ioutil.ReadFile(t.CACert)
+		if err != nil {
+			return fmt.Errorf("unable to read CA certificate: %+v", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(b) {
+			return fmt.Errorf("no PEM certificates found in %s", t.CACert)
+		}
+	}
+	if t.CAPath != "" {
+		info, err := os.Stat(t.CAPath)
+		if err != nil {
+			return fmt.Errorf("unable to read CA path: %+v", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("CA path %s must be a directory, use ca_cert for a file", t.CAPath)
+		}
+	}
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return fmt.Errorf("client_cert and client_key must be set together")
+	}
+	if t.ClientCert != "" {
+		if _, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey); err != nil {
+			return fmt.Errorf("unable to load client certificate: %+v", err)
+		}
+	}
+	return nil
+}
+
+// apiConfig returns the Vault client configuration with conf applied over the VAULT_* variables
+func (c VaultConfig) apiConfig() (*api.Config, error) {
+	config := api.DefaultConfig()
+	if config.Error != nil {
+		return nil, config.Error
+	}
+	if c.Address != "" {
+		config.Address = c.Address
+	}
+	if c.TLS != (TLSConfig{}) {
+		err := config.ConfigureTLS(&api.TLSConfig{
+			CACert:        c.TLS.CACert,
+			CAPath:        c.TLS.CAPath,
+			ClientCert:    c.TLS.ClientCert,
+			ClientKey:     c.TLS.ClientKey,
+			TLSServerName: c.TLS.ServerName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure TLS: %+v", err)
+		}
+	}
+	if c.Timeout != "" {
+		timeout, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %+v", err)
+		}
+		config.Timeout = timeout
+		config.HttpClient.Timeout = timeout
+	}
+	if c.MaxRetries != nil {
+		config.MaxRetries = *c.MaxRetries
+	}
+	return config, nil
+}
+
+// headers returns the headers sent with every request, selecting the namespace
+func (c VaultConfig) headers() http.Header {
+	h := http.Header{}
+	if c.Namespace != "" {
+		h.Set(namespaceHeader, c.Namespace)
+	}
+	return h
+}
+
+// LoadVaultConfig reads the "vault" section of the psst config file. A missing file gives the
+// defaults.
+func LoadVaultConfig(filename string) (VaultConfig, error) {
+	var conf struct {
+		Vault VaultConfig `json:"vault"`
+	}
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return VaultConfig{}, nil
+	}
+	if err != nil {
+		return VaultConfig{}, fmt.Errorf("unable to read config: %+v", err)
+	}
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return VaultConfig{}, fmt.Errorf("unable to unmarshal config %s: %+v", filename, err)
+	}
+	if err := conf.Vault.Validate(); err != nil {
+		return VaultConfig{}, fmt.Errorf("invalid config %s: %v", filename, err)
+	}
+	return conf.Vault, nil
+}
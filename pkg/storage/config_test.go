@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVaultConfigValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-config-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	negative := -1
+
+	cases := map[string]struct {
+		Config VaultConfig
+		Valid  bool
+	}{
+		"EmptyTest":          {Config: VaultConfig{}, Valid: true},
+		"AddressTest":        {Config: VaultConfig{Address: "https://vault.example.com:8200", Namespace: "eng/"}, Valid: true},
+		"BadAddressTest":     {Config: VaultConfig{Address: "vault.example.com"}},
+		"TimeoutTest":        {Config: VaultConfig{Timeout: "30s"}, Valid: true},
+		"BadTimeoutTest":     {Config: VaultConfig{Timeout: "soon"}},
+		"RetriesTest":        {Config: VaultConfig{MaxRetries: &negative}},
+		"MissingCATest":      {Config: VaultConfig{TLS: TLSConfig{CACert: filepath.Join(dir, "missing.pem")}}},
+		"NotPEMTest":         {Config: VaultConfig{TLS: TLSConfig{CACert: notPEM}}},
+		"CAPathFileTest":     {Config: VaultConfig{TLS: TLSConfig{CAPath: notPEM}}},
+		"CAPathTest":         {Config: VaultConfig{TLS: TLSConfig{CAPath: dir}}, Valid: true},
+		"CertWithoutKeyTest": {Config: VaultConfig{TLS: TLSConfig{ClientCert: notPEM}}},
+		"BadKeyPairTest":     {Config: VaultConfig{TLS: TLSConfig{ClientCert: notPEM, ClientKey: notPEM}}},
+		"BadAuthTest":        {Config: VaultConfig{Auth: AuthConfig{Method: "ldap"}}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := c.Config.Validate()
+			if (err == nil) != c.Valid {
+				t.Errorf("Name: %s, got: %v, expected valid: %v", name, err, c.Valid)
+			}
+		})
+	}
+}
+
+func TestNewVaultConfig(t *testing.T) {
+	retries := 5
+	conf := VaultConfig{Address: "https://vault.example.com:8200", Namespace: "eng", Timeout: "15s", MaxRetries: &retries}
+	v, err := NewVault(conf)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	if v.Address() != conf.Address {
+		t.Errorf("got: %s, expected: %s", v.Address(), conf.Address)
+	}
+	r := v.NewRequest("GET", "/v1/sys/health")
+	if ns := r.Headers.Get(namespaceHeader); ns != "eng" {
+		t.Errorf("got namespace: %q, expected: eng", ns)
+	}
+
+	config, err := conf.apiConfig()
+	if err != nil {
+		t.Fatalf("unable to build config: %v", err)
+	}
+	if config.Timeout != 15*time.Second || config.MaxRetries != 5 {
+		t.Errorf("got: %v %d, expected: 15s 5", config.Timeout, config.MaxRetries)
+	}
+}
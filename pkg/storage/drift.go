@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// DriftMissing is reported for a policy or mapping that doesn't exist
+	DriftMissing = "missing"
+	// DriftModified is reported for a policy whose rules were edited by hand
+	DriftModified = "modified"
+	// DriftLostPolicy is reported for a mapping that no longer includes a psst policy it should
+	DriftLostPolicy = "lost-policy"
+	// DriftStale is reported for a psst policy, or a mapping to one, of a member or team who left
+	DriftStale = "stale"
+	// DriftDemoted is reported for a user mapping that still includes the maintainer policy of a
+	// team the user no longer maintains
+	DriftDemoted = "demoted"
+)
+
+// Drift is a difference between the policies rendered from the directory and the policies found in
+// files or in Vault
+type Drift struct {
+	Source   string `json:"source"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Problem  string `json:"problem"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// String describes the drift on a single line
+func (d Drift) String() string {
+	switch d.Problem {
+	case DriftMissing:
+		return fmt.Sprintf("%s: %s %s is missing", d.Source, d.Kind, d.Name)
+	case DriftModified:
+		return fmt.Sprintf("%s: %s %s was edited by hand", d.Source, d.Kind, d.Name)
+	case DriftLostPolicy:
+		return fmt.Sprintf("%s: %s %s is mapped to %s instead of %s", d.Source, d.Kind, d.Name, d.Actual, d.Expected)
+	case DriftStale:
+		return fmt.Sprintf("%s: %s %s belongs to a member or team that left", d.Source, d.Kind, d.Name)
+	case DriftDemoted:
+		return fmt.Sprintf("%s: %s %s is mapped to %s instead of %s, they no longer maintain the team", d.Source, d.Kind, d.Name, d.Actual, d.Expected)
+	}
+	return fmt.Sprintf("%s: %s %s: %s", d.Source, d.Kind, d.Name, d.Problem)
+}
+
+// CompareDesired returns how actual, read from source, differs from the desired policies. Vault
+// lowercases policy names and mapped users and teams, so names are compared ignoring case.
+func CompareDesired(source string, desired, actual PolicySet) []Drift {
+	drifts := []Drift{}
+
+	policies := make(map[string]string)
+	for k, v := range actual.Policies {
+		policies[strings.ToLower(k)] = v
+	}
+	for name, rules := range desired.Policies {
+		current, ok := policies[strings.ToLower(name)]
+		switch {
+		case !ok:
+			drifts = append(drifts, Drift{Source: source, Kind: PolicyChange, Name: name, Problem: DriftMissing})
+		case current != rules:
+			drifts = append(drifts, Drift{Source: source, Kind: PolicyChange, Name: name, Problem: DriftModified, Expected: rules, Actual: current})
+		}
+	}
+
+	mappings := []struct {
+		kind            string
+		desired, actual map[string][]string
+	}{
+		{UserChange, desired.Users, actual.Users},
+		{TeamChange, desired.Teams, actual.Teams},
+	}
+	for _, m := range mappings {
+		mapped := make(map[string][]string)
+		for k, v := range m.actual {
+			mapped[strings.ToLower(k)] = v
+		}
+		for name, want := range m.desired {
+			current, ok := mapped[strings.ToLower(name)]
+			if !ok {
+				drifts = append(drifts, Drift{Source: source, Kind: m.kind, Name: name, Problem: DriftMissing, Expected: strings.Join(want, ",")})
+				continue
+			}
+			for _, w := range want {
+				if !containsFold(current, w) {
+					drifts = append(drifts, Drift{Source: source, Kind: m.kind, Name: name, Problem: DriftLostPolicy, Expected: strings.Join(want, ","), Actual: strings.Join(current, ",")})
+					break
+				}
+			}
+		}
+	}
+
+	for _, c := range planPrune(actual, desired) {
+		d := Drift{Source: source, Kind: c.Kind, Name: c.Name, Problem: DriftStale, Actual: c.Old}
+		if c.Kind == UserChange && !anyStale(splitPolicies(c.Old), actual, desired) {
+			d.Problem = DriftDemoted
+			d.Expected = c.New
+		}
+		drifts = append(drifts, d)
+	}
+
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Kind != drifts[j].Kind {
+			return drifts[i].Kind < drifts[j].Kind
+		}
+		return drifts[i].Name < drifts[j].Name
+	})
+	return drifts
+}
+
+// ReadPolicyFiles reads the policies in policyDir and the roles in roleDir written by
+// GeneratePoliciesAndRoles
+func ReadPolicyFiles(policyDir, roleDir string) (PolicySet, error) {
+	actual := PolicySet{Policies: map[string]string{}, Users: map[string][]string{}, Teams: map[string][]string{}}
+	files, err := filepath.Glob(filepath.Join(policyDir, "*.hcl"))
+	if err != nil {
+		return PolicySet{}, err
+	}
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".hcl")
+		if !isPsstPolicy(name) {
+			continue
+		}
+		buf, err := ioutil.ReadFile(f)
+		if err != nil {
+			return PolicySet{}, fmt.Errorf("unable to read %s: %+v", f, err)
+		}
+		actual.Policies[name] = string(buf)
+	}
+
+	folders := map[string]map[string][]string{"users": actual.Users, "teams": actual.Teams}
+	for folder, mapped := range folders {
+		files, err := filepath.Glob(filepath.Join(roleDir, folder, "*.json"))
+		if err != nil {
+			return PolicySet{}, err
+		}
+		for _, f := range files {
+			role, err := readRole(f)
+			if err != nil {
+				return PolicySet{}, err
+			}
+			mapped[strings.TrimSuffix(filepath.Base(f), ".json")] = splitPolicies(role.Value)
+		}
+	}
+	return actual, nil
+}
+
+// anyStale checks if any of policies psst generated in actual is stale in set
+func anyStale(policies []string, actual, set PolicySet) bool {
+	for _, p := range policies {
+		if stalePolicy(p, actual, set) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareDesired(t *testing.T) {
+	desired, err := RenderPolicies(policies["github"], "all", []string{"Alice"}, map[string]Team{"sre": {}})
+	if err != nil {
+		t.Fatalf("unable to render policies: %v", err)
+	}
+	copySet := func() PolicySet {
+		set := PolicySet{Policies: map[string]string{}, Users: map[string][]string{}, Teams: map[string][]string{}}
+		for k, v := range desired.Policies {
+			set.Policies[k] = v
+		}
+		for k, v := range desired.Users {
+			set.Users[k] = v
+		}
+		for k, v := range desired.Teams {
+			set.Teams[k] = v
+		}
+		return set
+	}
+
+	cases := map[string]struct {
+		Change   func(PolicySet)
+		Expected []string
+	}{
+		"InSyncTest": {
+			Change:   func(s PolicySet) {},
+			Expected: []string{},
+		},
+		"LowercasedTest": {
+			Change: func(s PolicySet) {
+				s.Policies["psst-alice"] = s.Policies["psst-Alice"]
+				delete(s.Policies, "psst-Alice")
+				s.Users["alice"] = []string{"psst-alice"}
+				delete(s.Users, "Alice")
+			},
+			Expected: []string{},
+		},
+		"HandEditedTest": {
+			Change:   func(s PolicySet) { s.Policies["psst-sre"] = "path \"*\" {}" },
+			Expected: []string{"policy psst-sre modified"},
+		},
+		"MissingTeamTest": {
+			Change:   func(s PolicySet) { delete(s.Teams, "sre") },
+			Expected: []string{"team sre missing"},
+		},
+		"LostPolicyTest": {
+			Change:   func(s PolicySet) { s.Teams["all"] = []string{"admin"} },
+			Expected: []string{"team all lost-policy"},
+		},
+		"StaleTest": {
+			Change: func(s PolicySet) {
+				s.Policies["psst-bob"] = generatedMarker + "\nrules"
+				s.Users["bob"] = []string{"psst-bob"}
+			},
+			Expected: []string{"policy psst-bob stale", "user bob stale"},
+		},
+		"HandMadeTest": {
+			Change: func(s PolicySet) {
+				s.Policies["psst-admins"] = "rules"
+				s.Users["bob"] = []string{"psst-admins"}
+			},
+			Expected: []string{},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual := copySet()
+			c.Change(actual)
+			got := []string{}
+			for _, d := range CompareDesired("vault", desired, actual) {
+				got = append(got, d.Kind+" "+d.Name+" "+d.Problem)
+			}
+			if !reflect.DeepEqual(got, c.Expected) {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestCompareDemoted(t *testing.T) {
+	scope := TeamScope{Members: []string{"read", "list"}, Maintainers: DefaultTeamScope.Maintainers}
+	desired, err := RenderPolicies(policies["github"], "all", []string{"alice", "bob"}, map[string]Team{
+		"sre": {Members: []string{"alice", "bob"}, Maintainers: []string{"alice"}, Scope: scope},
+	})
+	if err != nil {
+		t.Fatalf("unable to render policies: %v", err)
+	}
+	actual := PolicySet{Policies: desired.Policies, Teams: desired.Teams, Users: map[string][]string{
+		"alice": desired.Users["alice"],
+		"bob":   []string{"psst-bob", "psst-sre-maintainers"},
+	}}
+
+	got := []string{}
+	for _, d := range CompareDesired("vault", desired, actual) {
+		got = append(got, d.Kind+" "+d.Name+" "+d.Problem+" "+d.Expected)
+	}
+	expected := []string{"user bob demoted psst-bob"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got: %v, expected: %v", got, expected)
+	}
+
+	change, ok := planMapping(UserChange, "bob", "psst-bob,psst-sre-maintainers", desired.Users["bob"])
+	if !ok || change.New != "psst-bob" {
+		t.Errorf("got: %q %v, expected the maintainer policy to be taken away from bob", change.New, ok)
+	}
+}
+
+func TestReadPolicyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-drift-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	policyDir := filepath.Join(dir, "policies")
+	roleDir := filepath.Join(dir, "roles")
+
+	desired, err := RenderPolicies(policies["github"], "all", []string{"alice"}, map[string]Team{"sre": {Members: []string{"alice"}}})
+	if err != nil {
+		t.Fatalf("unable to render policies: %v", err)
+	}
+	if err := (&VaultStore{}).GeneratePoliciesAndRoles(desired, roleDir, policyDir); err != nil {
+		t.Fatalf("unable to generate files: %v", err)
+	}
+	actual, err := ReadPolicyFiles(policyDir, roleDir)
+	if err != nil {
+		t.Fatalf("unable to read files: %v", err)
+	}
+	if drifts := CompareDesired("files", desired, actual); len(drifts) != 0 {
+		t.Errorf("got: %v, expected generated files to match", drifts)
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// Errors returned by backends, wrapped with more detail. Check for them with errors.Is.
+var (
+	// ErrNotFound is returned when a secret doesn't exist
+	ErrNotFound = errors.New("secret not found")
+	// ErrForbidden is returned when the storage backend denies access
+	ErrForbidden = errors.New("permission denied")
+	// ErrExpired is returned when reading a secret past its expiration
+	ErrExpired = errors.New("secret has expired")
+	// ErrRevoked is returned when reading a secret the sender revoked
+	ErrRevoked = errors.New("secret was revoked by the sender")
+	// ErrUnavailable is returned when the storage backend can't be reached or can't serve requests
+	ErrUnavailable = errors.New("storage backend unavailable")
+)
+
+// classify wraps an error from a Vault request with the matching sentinel error
+func classify(resp *api.Response, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if resp == nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %v", ErrForbidden, err)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return err
+}
+
+func isForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden)
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
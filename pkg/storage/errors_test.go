@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestClassify(t *testing.T) {
+	cause := errors.New("request failed")
+	response := func(code int) *api.Response {
+		return &api.Response{Response: &http.Response{StatusCode: code}}
+	}
+
+	cases := map[string]struct {
+		Resp     *api.Response
+		Err      error
+		Expected error
+	}{
+		"NotFoundTest":     {Resp: response(http.StatusNotFound), Err: cause, Expected: ErrNotFound},
+		"ForbiddenTest":    {Resp: response(http.StatusForbidden), Err: cause, Expected: ErrForbidden},
+		"UnauthorizedTest": {Resp: response(http.StatusUnauthorized), Err: cause, Expected: ErrForbidden},
+		"SealedTest":       {Resp: response(http.StatusServiceUnavailable), Err: cause, Expected: ErrUnavailable},
+		"RateLimitTest":    {Resp: response(http.StatusTooManyRequests), Err: cause, Expected: ErrUnavailable},
+		"NetworkTest":      {Resp: nil, Err: cause, Expected: ErrUnavailable},
+		"CanceledTest":     {Resp: nil, Err: context.Canceled, Expected: context.Canceled},
+		"BadRequestTest":   {Resp: response(http.StatusBadRequest), Err: cause, Expected: cause},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := classify(c.Resp, c.Err); !errors.Is(got, c.Expected) {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestDropCapabilitiesErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") == "denied" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"capabilities": ["create", "update"]}`)
+	}))
+	defer srv.Close()
+
+	client, err := api.NewClient(&api.Config{Address: srv.URL, HttpClient: srv.Client()})
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	v := &VaultStore{client}
+
+	client.SetToken("alice")
+	if caps, err := v.DropCapabilities(context.Background(), "sre"); err != nil || len(caps) != 2 {
+		t.Errorf("got: %v %v, expected: [create update]", caps, err)
+	}
+	client.SetToken("denied")
+	if _, err := v.DropCapabilities(context.Background(), "sre"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("got: %v, expected: %v", err, ErrForbidden)
+	}
+}
@@ -0,0 +1,398 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+const (
+	// DefaultAuthPath is where the GitHub auth method is mounted in Vault
+	DefaultAuthPath = "auth/github"
+
+	// PolicyChange is a change to a Vault ACL policy
+	PolicyChange = "policy"
+	// UserChange is a change to the policies mapped to a GitHub user
+	UserChange = "user"
+	// TeamChange is a change to the policies mapped to a GitHub team
+	TeamChange = "team"
+
+	// generatedMarker starts every policy psst renders, so only those are pruned
+	generatedMarker = "# Generated by psst"
+
+	generalTemplateFile = "general.hcl.tmpl"
+	memberTemplateFile  = "member.hcl.tmpl"
+	teamTemplateFile    = "team.hcl.tmpl"
+)
+
+// capabilities are the capabilities Vault accepts in a policy
+var capabilities = map[string]struct{}{
+	"create": {}, "read": {}, "update": {}, "delete": {}, "list": {}, "sudo": {}, "deny": {},
+}
+
+// PolicySet is what Vault needs for psst to work with a directory: the ACL policies by name and the
+// policies mapped to each GitHub user and team
+type PolicySet struct {
+	Policies map[string]string
+	Users    map[string][]string
+	Teams    map[string][]string
+}
+
+// Change is a difference between the policies in Vault and a PolicySet. Mappings are comma separated
+// lists of policies like Vault returns them.
+type Change struct {
+	Kind string
+	Name string
+	Old  string
+	// New is empty when the policy or mapping is deleted
+	New string
+	// File is set for changes to generated policy and role files instead of Vault
+	File string
+}
+
+// Action describes what applying the change does
+func (c Change) Action() string {
+	switch {
+	case c.Old == "":
+		return "create"
+	case c.New == "":
+		return "delete"
+	}
+	return "update"
+}
+
+// TemplateData is available to policy templates
+type TemplateData struct {
+	// Path is the path of the drop the policy is for, or the path every drop is under for the
+	// general policy
+	Path string
+	// Mount is the path the secrets engine psst uses is mounted at
+	Mount string
+	// Entity is the member or team the policy is for, empty for the general policy
+	Entity string
+	// Login is the login of the member the policy is for, empty for team policies
+	Login string
+	// Members are the logins of the team the policy is for, or of every member for the general
+	// policy. Empty for member policies.
+	Members []string
+	// Maintainers are the logins of the maintainers of the team the policy is for
+	Maintainers []string
+	// Maintainer is set for the policy of the maintainers of a team whose scope gives them different
+	// capabilities than the other members
+	Maintainer bool
+	// Capabilities are what the scope of the team allows in the team drop
+	Capabilities []string
+}
+
+// Templates returns the policy templates compiled in for a directory backend
+func Templates(directoryBackend string) (PolicyTemplates, error) {
+	templates, ok := policies[directoryBackend]
+	if !ok {
+		known := []string{}
+		for k := range policies {
+			known = append(known, k)
+		}
+		sort.Strings(known)
+		return PolicyTemplates{}, fmt.Errorf("unknown directory backend %s, expected one of: %s", directoryBackend, strings.Join(known, ", "))
+	}
+	return templates, nil
+}
+
+// LoadTemplates reads the general, member and team templates from general.hcl.tmpl,
+// member.hcl.tmpl and team.hcl.tmpl in dir. Templates without a file are taken from base.
+func LoadTemplates(dir string, base PolicyTemplates) (PolicyTemplates, error) {
+	templates := base
+	files := map[string]*string{
+		generalTemplateFile: &templates.GeneralPolicyTemplate,
+		memberTemplateFile:  &templates.MemberPolicyTemplate,
+		teamTemplateFile:    &templates.TeamPolicyTemplate,
+	}
+	found := 0
+	for name, tmpl := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return PolicyTemplates{}, fmt.Errorf("unable to read template %s: %+v", name, err)
+		}
+		if _, err := template.New(name).Parse(string(b)); err != nil {
+			return PolicyTemplates{}, fmt.Errorf("unable to parse template %s: %+v", name, err)
+		}
+		*tmpl = string(b)
+		found++
+	}
+	if found == 0 {
+		return PolicyTemplates{}, fmt.Errorf("no templates found in %s, expected %s, %s or %s", dir, generalTemplateFile, memberTemplateFile, teamTemplateFile)
+	}
+	return templates, nil
+}
+
+// RenderPolicies renders the policies and mappings for members and teams of a directory. Members get
+// their own drop through their user mapping, teams get theirs through their team mapping and
+// everyone gets the general policy, letting them write to the drop of every member, through
+// defaultTeam. When the scope of a team gives maintainers
+// different capabilities, they get them through a separate policy in their user mapping. Every
+// policy is checked to be valid HCL so nothing is written or applied from a broken template.
+func RenderPolicies(templates PolicyTemplates, defaultTeam string, members []string, teams map[string]Team) (PolicySet, error) {
+	set := PolicySet{Policies: map[string]string{}, Users: map[string][]string{}, Teams: map[string][]string{}}
+	mount := "/" + vaultSecretName
+	// Team drops are left out of the general policy, the scope of each team decides who writes to it
+	general, err := renderPolicy(templates.GeneralPolicyTemplate, TemplateData{Path: keyPrefix, Mount: mount, Members: sortedCopy(members)})
+	if err != nil {
+		return PolicySet{}, fmt.Errorf("unable to render general policy: %w", err)
+	}
+	set.Policies[filePrefix] = general
+	set.Teams[defaultTeam] = []string{filePrefix}
+
+	for _, m := range members {
+		name := policyName(m)
+		data := TemplateData{Path: getSecretPathPrefix(m), Mount: mount, Entity: m, Login: m}
+		if set.Policies[name], err = renderPolicy(templates.MemberPolicyTemplate, data); err != nil {
+			return PolicySet{}, fmt.Errorf("unable to render policy for user %s: %w", m, err)
+		}
+		set.Users[m] = []string{name}
+	}
+
+	names := []string{}
+	for t := range teams {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	for _, t := range names {
+		team := teams[t]
+		scope := team.Scope
+		if len(scope.Members) == 0 {
+			scope = DefaultTeamScope
+		}
+		if err := scope.Validate(); err != nil {
+			return PolicySet{}, fmt.Errorf("invalid scope for team %s: %v", t, err)
+		}
+		data := TemplateData{
+			Path:         getSecretPathPrefix(t),
+			Mount:        mount,
+			Entity:       t,
+			Members:      sortedCopy(team.Members),
+			Maintainers:  sortedCopy(team.Maintainers),
+			Capabilities: scope.Members,
+		}
+
+		name := policyName(t)
+		if set.Policies[name], err = renderPolicy(templates.TeamPolicyTemplate, data); err != nil {
+			return PolicySet{}, fmt.Errorf("unable to render policy for team %s: %w", t, err)
+		}
+		set.Teams[t] = append(set.Teams[t], name)
+
+		if !scope.split() || len(team.Maintainers) == 0 {
+			continue
+		}
+		name = maintainerPolicyName(t)
+		data.Maintainer = true
+		data.Capabilities = scope.Maintainers
+		if set.Policies[name], err = renderPolicy(templates.TeamPolicyTemplate, data); err != nil {
+			return PolicySet{}, fmt.Errorf("unable to render policy for maintainers of team %s: %w", t, err)
+		}
+		for _, m := range data.Maintainers {
+			set.Users[m] = append(set.Users[m], name)
+		}
+	}
+	return set, nil
+}
+
+func sortedCopy(list []string) []string {
+	sorted := append([]string{}, list...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func renderPolicy(tmpl string, data TemplateData) (string, error) {
+	t, err := template.New("policy").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.NewBufferString(generatedMarker + "\n")
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+	if err := ValidatePolicy(buf.String()); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ValidatePolicy checks that rules parse as a Vault ACL policy: HCL made of path blocks with known
+// capabilities
+func ValidatePolicy(rules string) error {
+	f, err := hcl.Parse(rules)
+	if err != nil {
+		return fmt.Errorf("invalid HCL: %+v", err)
+	}
+	list, ok := f.Node.(*ast.ObjectList)
+	if !ok {
+		return fmt.Errorf("invalid policy: expected path blocks")
+	}
+	if len(list.Items) == 0 {
+		return fmt.Errorf("invalid policy: no path blocks")
+	}
+	for _, item := range list.Items {
+		if len(item.Keys) != 2 || item.Keys[0].Token.Value() != "path" {
+			return fmt.Errorf("invalid policy: line %d: expected a path block", item.Pos().Line)
+		}
+		p, _ := item.Keys[1].Token.Value().(string)
+		var rule struct {
+			Capabilities []string `hcl:"capabilities"`
+		}
+		if err := hcl.DecodeObject(&rule, item.Val); err != nil {
+			return fmt.Errorf("invalid policy for path %q: %+v", p, err)
+		}
+		if len(rule.Capabilities) == 0 {
+			return fmt.Errorf("invalid policy for path %q: no capabilities", p)
+		}
+		for _, c := range rule.Capabilities {
+			if _, ok := capabilities[c]; !ok {
+				return fmt.Errorf("invalid policy for path %q: unknown capability %q", p, c)
+			}
+		}
+	}
+	return nil
+}
+
+// policyName returns the name of the policy for the drop of entity
+func policyName(entity string) string {
+	return fmt.Sprintf("%s-%s", filePrefix, entity)
+}
+
+// maintainerPolicyName returns the name of the policy for the maintainers of a team
+func maintainerPolicyName(team string) string {
+	return fmt.Sprintf("%s-%s-maintainers", filePrefix, team)
+}
+
+// isMaintainerPolicy checks if name is the policy of the maintainers of a team
+func isMaintainerPolicy(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, filePrefix+"-") && strings.HasSuffix(name, "-maintainers")
+}
+
+// isPsstPolicy checks if name is the general psst policy or the policy of a drop
+func isPsstPolicy(name string) bool {
+	return name == filePrefix || strings.HasPrefix(name, filePrefix+"-")
+}
+
+// planPolicy returns the change needed to make the current rules of a policy match desired
+func planPolicy(name, current, desired string) (Change, bool) {
+	if current == desired {
+		return Change{}, false
+	}
+	return Change{Kind: PolicyChange, Name: name, Old: current, New: desired}, true
+}
+
+// planMapping returns the change needed for the current mapping of a user or team to include every
+// desired policy. Maintainer policies are taken away from users who no longer maintain the team,
+// other policies mapped by hand are kept.
+func planMapping(kind, name, current string, desired []string) (Change, bool) {
+	mapped := []string{}
+	changed := false
+	for _, m := range splitPolicies(current) {
+		// Vault lowercases the policies of a mapping
+		if kind == UserChange && isMaintainerPolicy(m) && !containsFold(desired, m) {
+			changed = true
+			continue
+		}
+		mapped = append(mapped, m)
+	}
+	for _, d := range desired {
+		if !containsFold(mapped, d) {
+			mapped = append(mapped, d)
+			changed = true
+		}
+	}
+	if !changed {
+		return Change{}, false
+	}
+	return Change{Kind: kind, Name: name, Old: current, New: strings.Join(mapped, ",")}, true
+}
+
+func splitPolicies(value string) []string {
+	policies := []string{}
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			policies = append(policies, p)
+		}
+	}
+	return policies
+}
+
+// SortChanges orders changes by kind, policies first, then by name
+func SortChanges(changes []Change) {
+	order := map[string]int{PolicyChange: 0, UserChange: 1, TeamChange: 2}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return order[changes[i].Kind] < order[changes[j].Kind]
+		}
+		return changes[i].Name < changes[j].Name
+	})
+}
+
+// DiffLines returns a line by line diff of old and new, with removed lines prefixed with "-", added
+// lines with "+" and unchanged lines with " "
+func DiffLines(old, new string) []string {
+	a := splitLines(old)
+	b := splitLines(new)
+
+	// Longest common subsequence of lines, policies are small enough for the quadratic table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := []string{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+a[i])
+			i++
+		default:
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+"+b[j])
+	}
+	return diff
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRenderPolicies(t *testing.T) {
+	set, err := RenderPolicies(policies["github"], "all", []string{"alice"}, map[string]Team{"sre": {}, "all": {}})
+	if err != nil {
+		t.Fatalf("unable to render policies: %v", err)
+	}
+
+	names := []string{}
+	for n := range set.Policies {
+		names = append(names, n)
+	}
+	expectedNames := []string{"psst", "psst-alice", "psst-all", "psst-sre"}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("got policies: %v, expected: %v", names, expectedNames)
+	}
+	if !strings.Contains(set.Policies["psst-alice"], `path "/secret/psst/alice/*"`) {
+		t.Errorf("got: %s, expected a policy for alice's drop", set.Policies["psst-alice"])
+	}
+	// Team drops are only written to through the scope of the team
+	general := set.Policies["psst"]
+	if !strings.Contains(general, `path "/secret/psst/alice/*"`) || strings.Contains(general, "/secret/psst/sre") || strings.Contains(general, `"/secret/psst/*"`) {
+		t.Errorf("got: %s, expected the general policy to only cover the drops of members", general)
+	}
+	if !reflect.DeepEqual(set.Users, map[string][]string{"alice": []string{"psst-alice"}}) {
+		t.Errorf("got users: %v", set.Users)
+	}
+	expectedTeams := map[string][]string{"all": []string{"psst", "psst-all"}, "sre": []string{"psst-sre"}}
+	if !reflect.DeepEqual(set.Teams, expectedTeams) {
+		t.Errorf("got teams: %v, expected: %v", set.Teams, expectedTeams)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	if _, err := Templates("github"); err != nil {
+		t.Errorf("got: %v, expected the github templates", err)
+	}
+	if _, err := Templates("gitlab"); err == nil {
+		t.Errorf("expected an error for an unknown directory backend")
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-templates-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := LoadTemplates(dir, policies["github"]); err == nil {
+		t.Errorf("expected an error for a directory without templates")
+	}
+
+	team := `path "{{.Mount}}/psst/{{.Entity}}/*" {
+	capabilities = ["read", "list"]
+}
+{{range .Members}}path "{{$.Mount}}/psst/{{.}}/*" {
+	capabilities = ["create"]
+}
+{{end}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "team.hcl.tmpl"), []byte(team), 0600); err != nil {
+		t.Fatalf("unable to write template: %v", err)
+	}
+	templates, err := LoadTemplates(dir, policies["github"])
+	if err != nil {
+		t.Fatalf("unable to load templates: %v", err)
+	}
+	if templates.MemberPolicyTemplate != policies["github"].MemberPolicyTemplate {
+		t.Errorf("got: %s, expected the built in member template", templates.MemberPolicyTemplate)
+	}
+
+	set, err := RenderPolicies(templates, "all", []string{"alice"}, map[string]Team{"sre": {Members: []string{"bob", "alice"}}})
+	if err != nil {
+		t.Fatalf("unable to render policies: %v", err)
+	}
+	expected := generatedMarker + `
+path "/secret/psst/sre/*" {
+	capabilities = ["read", "list"]
+}
+path "/secret/psst/alice/*" {
+	capabilities = ["create"]
+}
+path "/secret/psst/bob/*" {
+	capabilities = ["create"]
+}
+`
+	if set.Policies["psst-sre"] != expected {
+		t.Errorf("got: %s, expected: %s", set.Policies["psst-sre"], expected)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "member.hcl.tmpl"), []byte("{{.Nope}}"), 0600); err != nil {
+		t.Fatalf("unable to write template: %v", err)
+	}
+	templates, err = LoadTemplates(dir, policies["github"])
+	if err != nil {
+		t.Fatalf("unable to load templates: %v", err)
+	}
+	if _, err := RenderPolicies(templates, "all", []string{"alice"}, nil); err == nil {
+		t.Errorf("expected an error for a template using an unknown field")
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	cases := map[string]struct {
+		Rules string
+		Valid bool
+	}{
+		"ValidTest":         {Rules: `path "secret/*" { capabilities = ["read", "list"] }`, Valid: true},
+		"SyntaxTest":        {Rules: `path "secret/*" { capabilities = ["read" }`},
+		"EmptyTest":         {Rules: "# nothing"},
+		"NotPathTest":       {Rules: `name "secret/*" { capabilities = ["read"] }`},
+		"NoCapabilityTest":  {Rules: `path "secret/*" { capabilities = [] }`},
+		"BadCapabilityTest": {Rules: `path "secret/*" { capabilities = ["write"] }`},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidatePolicy(c.Rules)
+			if (err == nil) != c.Valid {
+				t.Errorf("Name: %s, got: %v, expected valid: %v", name, err, c.Valid)
+			}
+		})
+	}
+}
+
+func TestPlanMapping(t *testing.T) {
+	cases := map[string]struct {
+		Current  string
+		Desired  []string
+		Expected string
+		Change   bool
+	}{
+		"NewTest":        {Current: "", Desired: []string{"psst-alice"}, Expected: "psst-alice", Change: true},
+		"UpToDateTest":   {Current: "psst-alice", Desired: []string{"psst-alice"}},
+		"KeepOtherTest":  {Current: "admin, psst-alice", Desired: []string{"psst-alice"}},
+		"AddTest":        {Current: "admin", Desired: []string{"psst", "psst-all"}, Expected: "admin,psst,psst-all", Change: true},
+		"CaseTest":       {Current: "psst,psst-alice", Desired: []string{"psst", "psst-Alice"}},
+		"DemotedTest":    {Current: "admin,psst-alice,psst-sre-maintainers", Desired: []string{"psst-alice"}, Expected: "admin,psst-alice", Change: true},
+		"MaintainerTest": {Current: "psst-alice,psst-sre-maintainers", Desired: []string{"psst-alice", "psst-SRE-maintainers"}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			change, ok := planMapping(UserChange, "alice", c.Current, c.Desired)
+			if ok != c.Change || change.New != c.Expected {
+				t.Errorf("Name: %s, got: %v %v, expected: %v %v", name, change.New, ok, c.Expected, c.Change)
+			}
+		})
+	}
+}
+
+func TestPlanPolicy(t *testing.T) {
+	if _, ok := planPolicy("psst", "same", "same"); ok {
+		t.Errorf("expected no change for identical rules")
+	}
+	c, ok := planPolicy("psst", "", "rules")
+	if !ok || c.Action() != "create" {
+		t.Errorf("got: %v %v, expected a create", c, ok)
+	}
+	c, ok = planPolicy("psst", "old", "rules")
+	if !ok || c.Action() != "update" {
+		t.Errorf("got: %v %v, expected an update", c, ok)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	cases := map[string]struct {
+		Old      string
+		New      string
+		Expected []string
+	}{
+		"SameTest":   {Old: "a\nb\n", New: "a\nb\n", Expected: []string{" a", " b"}},
+		"CreateTest": {Old: "", New: "a\nb\n", Expected: []string{"+a", "+b"}},
+		"ChangeTest": {Old: "a\nb\nc\n", New: "a\nx\nc\n", Expected: []string{" a", "-b", "+x", " c"}},
+		"AppendTest": {Old: "a\n", New: "a\nb\n", Expected: []string{" a", "+b"}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := DiffLines(c.Old, c.New); !reflect.DeepEqual(got, c.Expected) {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestSortChanges(t *testing.T) {
+	changes := []Change{{Kind: TeamChange, Name: "sre"}, {Kind: UserChange, Name: "bob"}, {Kind: PolicyChange, Name: "psst-b"}, {Kind: PolicyChange, Name: "psst-a"}}
+	SortChanges(changes)
+	expected := []Change{{Kind: PolicyChange, Name: "psst-a"}, {Kind: PolicyChange, Name: "psst-b"}, {Kind: UserChange, Name: "bob"}, {Kind: TeamChange, Name: "sre"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("got: %v, expected: %v", changes, expected)
+	}
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const archiveTimeFormat = "20060102-150405"
+
+// generated checks if name is a policy in actual that psst generated. Policies named psst-* by hand
+// don't carry the marker and are never pruned. Vault lowercases policy names, so they are compared
+// ignoring case.
+func generated(name string, actual PolicySet) bool {
+	for p, rules := range actual.Policies {
+		if strings.EqualFold(p, name) {
+			return strings.HasPrefix(rules, generatedMarker)
+		}
+	}
+	return false
+}
+
+// stalePolicy checks if name is a drop policy psst generated in actual that set no longer has
+func stalePolicy(name string, actual, set PolicySet) bool {
+	if !strings.HasPrefix(name, filePrefix+"-") || !generated(name, actual) {
+		return false
+	}
+	for p := range set.Policies {
+		if strings.EqualFold(p, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// demoted checks if name is a maintainer policy psst generated in actual that set no longer maps to
+// the user login, because they are no longer a maintainer of the team or left
+func demoted(kind, login, name string, actual, set PolicySet) bool {
+	if kind != UserChange || !isMaintainerPolicy(name) || !generated(name, actual) {
+		return false
+	}
+	for u, policies := range set.Users {
+		if strings.EqualFold(u, login) {
+			return !containsFold(policies, name)
+		}
+	}
+	return true
+}
+
+// planPrune returns the changes removing stale psst policies and mappings from actual
+func planPrune(actual, set PolicySet) []Change {
+	changes := []Change{}
+	for name, rules := range actual.Policies {
+		if stalePolicy(name, actual, set) {
+			changes = append(changes, Change{Kind: PolicyChange, Name: name, Old: rules})
+		}
+	}
+	mappings := map[string]map[string][]string{UserChange: actual.Users, TeamChange: actual.Teams}
+	for kind, mapped := range mappings {
+		for name, policies := range mapped {
+			if c, ok := pruneMapping(kind, name, strings.Join(policies, ","), actual, set); ok {
+				changes = append(changes, c)
+			}
+		}
+	}
+	SortChanges(changes)
+	return changes
+}
+
+// pruneMapping returns the change removing stale psst policies, and maintainer policies of teams a
+// user no longer maintains, from the current mapping of a user or team. The mapping is deleted once
+// nothing is left in it, policies psst didn't generate are kept.
+func pruneMapping(kind, name, current string, actual, set PolicySet) (Change, bool) {
+	kept := []string{}
+	for _, p := range splitPolicies(current) {
+		if !stalePolicy(p, actual, set) && !demoted(kind, name, p, actual, set) {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(splitPolicies(current)) {
+		return Change{}, false
+	}
+	return Change{Kind: kind, Name: name, Old: current, New: strings.Join(kept, ",")}, true
+}
+
+// PlanFilePrune returns the changes removing stale policies from the files written by
+// GeneratePoliciesAndRoles in policyDir and roleDir
+func PlanFilePrune(policyDir, roleDir string, set PolicySet) ([]Change, error) {
+	changes := []Change{}
+	files, err := filepath.Glob(filepath.Join(policyDir, filePrefix+"-*.hcl"))
+	if err != nil {
+		return []Change{}, err
+	}
+	actual := PolicySet{Policies: map[string]string{}}
+	for _, f := range files {
+		buf, err := ioutil.ReadFile(f)
+		if err != nil {
+			return []Change{}, fmt.Errorf("unable to read %s: %+v", f, err)
+		}
+		actual.Policies[strings.TrimSuffix(filepath.Base(f), ".hcl")] = string(buf)
+	}
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".hcl")
+		if stalePolicy(name, actual, set) {
+			changes = append(changes, Change{Kind: PolicyChange, Name: name, Old: actual.Policies[name], File: f})
+		}
+	}
+
+	folders := map[string]string{UserChange: "users", TeamChange: "teams"}
+	for kind, folder := range folders {
+		files, err := filepath.Glob(filepath.Join(roleDir, folder, "*.json"))
+		if err != nil {
+			return []Change{}, err
+		}
+		for _, f := range files {
+			role, err := readRole(f)
+			if err != nil {
+				return []Change{}, err
+			}
+			if c, ok := pruneMapping(kind, strings.TrimSuffix(filepath.Base(f), ".json"), role.Value, actual, set); ok {
+				c.File = f
+				changes = append(changes, c)
+			}
+		}
+	}
+	SortChanges(changes)
+	return changes, nil
+}
+
+// ApplyFileChanges writes changes returned by PlanFilePrune. Policy files and role files left
+// without policies are removed.
+func ApplyFileChanges(changes []Change) error {
+	for _, c := range changes {
+		if c.New == "" {
+			if err := os.Remove(c.File); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("unable to remove %s: %+v", c.File, err)
+			}
+			continue
+		}
+		if c.Kind == PolicyChange {
+			return fmt.Errorf("only deleting policy files is supported, not updating %s", c.File)
+		}
+		b, err := json.Marshal(ghUserPolicy{Value: c.New})
+		if err != nil {
+			return fmt.Errorf("unable to marshal %s: %+v", c.File, err)
+		}
+		if err := ioutil.WriteFile(c.File, b, 0644); err != nil {
+			return fmt.Errorf("unable to write %s: %+v", c.File, err)
+		}
+	}
+	return nil
+}
+
+func readRole(filename string) (ghUserPolicy, error) {
+	role := ghUserPolicy{}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return role, fmt.Errorf("unable to read %s: %+v", filename, err)
+	}
+	if err := json.Unmarshal(b, &role); err != nil {
+		return role, fmt.Errorf("unable to unmarshal %s: %+v", filename, err)
+	}
+	return role, nil
+}
+
+// OrphanedDrops returns the drops in b that don't belong to any of entities. GitHub logins and team
+// names aren't case sensitive, so neither is the match.
+func OrphanedDrops(ctx context.Context, b Backend, entities []string) ([]string, error) {
+	// Listing the root of the store lists every drop as a folder
+	drops, err := b.List(ctx, "")
+	if err != nil {
+		return []string{}, err
+	}
+	orphaned := []string{}
+	for _, d := range drops {
+		if !IsFolder(d) {
+			continue
+		}
+		d = strings.TrimSuffix(d, "/")
+		if !containsFold(entities, d) {
+			orphaned = append(orphaned, d)
+		}
+	}
+	sort.Strings(orphaned)
+	return orphaned, nil
+}
+
+// ArchiveDrop moves every secret in the drop of entity to an archive only Vault administrators can
+// read and returns how many were moved. Revoked secrets and what psst kept in the drop, such as
+// receipts, are deleted without being archived.
+func ArchiveDrop(ctx context.Context, b Backend, entity string, at time.Time) (int, error) {
+	names, err := Walk(ctx, b, entity)
+	if err != nil {
+		return 0, err
+	}
+	folder := path.Join(entity, at.UTC().Format(archiveTimeFormat))
+
+	archived := 0
+	for _, n := range names {
+		p := b.SecretPath(entity, n)
+		secret, err := b.GetSecret(ctx, p)
+		if err != nil {
+			return archived, err
+		}
+		if secret.Revoked.IsZero() {
+			if err := b.ArchiveSecret(ctx, path.Join(folder, n), secret); err != nil {
+				return archived, fmt.Errorf("unable to archive %s: %w", p, err)
+			}
+			archived++
+		}
+		if err := b.Delete(ctx, p); err != nil {
+			return archived, err
+		}
+	}
+	return archived, deleteReserved(ctx, b, entity)
+}
+
+// WipeDrop deletes every secret in the drop of entity, along with what psst kept in it, and returns
+// how many secrets were deleted
+func WipeDrop(ctx context.Context, b Backend, entity string) (int, error) {
+	names, err := Walk(ctx, b, entity)
+	if err != nil {
+		return 0, err
+	}
+	for i, n := range names {
+		if err := b.Delete(ctx, b.SecretPath(entity, n)); err != nil {
+			return i, err
+		}
+	}
+	return len(names), deleteReserved(ctx, b, entity)
+}
+
+// deleteReserved deletes what psst kept in the reserved folder of the drop of entity, which Walk
+// doesn't list
+func deleteReserved(ctx context.Context, b Backend, entity string) error {
+	listed, err := b.List(ctx, path.Join(entity, reservedFolder))
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	if err := walk(ctx, b, entity, reservedFolder, listed, &names); err != nil {
+		return err
+	}
+	for _, n := range names {
+		if err := b.Delete(ctx, b.SecretPath(entity, n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memBackend keeps secrets in memory by path, listing folders like Vault does
+type memBackend struct {
+	Backend
+
+	secrets  map[string]Secret
+	archived map[string]Secret
+}
+
+func (m *memBackend) SecretPath(entity, name string) string {
+	return path.Join(entity, name)
+}
+
+func (m *memBackend) List(ctx context.Context, entity string) ([]string, error) {
+	prefix := ""
+	if entity != "" {
+		prefix = entity + "/"
+	}
+	seen := map[string]struct{}{}
+	names := []string{}
+	for p := range m.secrets {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		n := strings.TrimPrefix(p, prefix)
+		if i := strings.Index(n, "/"); i >= 0 {
+			n = n[:i+1]
+		}
+		if _, ok := seen[n]; !ok && !Hidden(n) {
+			seen[n] = struct{}{}
+			names = append(names, n)
+		}
+	}
+	return names, nil
+}
+
+func (m *memBackend) GetSecret(ctx context.Context, p string) (*Secret, error) {
+	s, ok := m.secrets[p]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (m *memBackend) WriteSecret(ctx context.Context, secret, name string, meta Metadata, targets map[string]struct{}) error {
+	for t := range targets {
+		m.secrets[path.Join(t, name)] = Secret{Value: secret, Metadata: meta}
+	}
+	return nil
+}
+
+func (m *memBackend) ArchiveSecret(ctx context.Context, name string, secret *Secret) error {
+	m.archived[name] = *secret
+	return nil
+}
+
+func (m *memBackend) Delete(ctx context.Context, p string) error {
+	delete(m.secrets, p)
+	return nil
+}
+
+func (m *memBackend) paths() []string {
+	paths := []string{}
+	for p := range m.secrets {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestPruneMapping(t *testing.T) {
+	set := PolicySet{
+		Policies: map[string]string{"psst": "", "psst-alice": "", "psst-bob": "", "psst-sre-maintainers": ""},
+		Users:    map[string][]string{"alice": []string{"psst-alice", "psst-sre-maintainers"}, "bob": []string{"psst-bob"}},
+	}
+	rules := generatedMarker + "\n"
+	actual := PolicySet{Policies: map[string]string{
+		"psst": rules, "psst-alice": rules, "psst-bob": rules, "psst-carol": rules, "psst-sre-maintainers": rules,
+		"psst-admins": "# Written by hand\n", "psst-web-maintainers": "# Written by hand\n",
+	}}
+
+	cases := map[string]struct {
+		Current  string
+		Expected string
+		Change   bool
+	}{
+		"CurrentTest":    {Current: "psst-alice"},
+		"StaleTest":      {Current: "psst-carol", Expected: "", Change: true},
+		"KeepOtherTest":  {Current: "admin,psst-carol", Expected: "admin", Change: true},
+		"GeneralTest":    {Current: "psst"},
+		"NotPsstTest":    {Current: "psstadmin"},
+		"MixedStaleTest": {Current: "psst,psst-carol,psst-alice", Expected: "psst,psst-alice", Change: true},
+		"DemotedTest":    {Current: "psst-bob,psst-sre-maintainers", Expected: "psst-bob", Change: true},
+		"HandMadeTest":   {Current: "psst-admins,psst-web-maintainers"},
+		"MissingTest":    {Current: "psst-dave"},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			change, ok := pruneMapping(UserChange, "bob", c.Current, actual, set)
+			if ok != c.Change || change.New != c.Expected {
+				t.Errorf("Name: %s, got: %q %v, expected: %q %v", name, change.New, ok, c.Expected, c.Change)
+			}
+		})
+	}
+}
+
+func TestPlanFilePrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-prune-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	policyDir := filepath.Join(dir, "policies")
+	roleDir := filepath.Join(dir, "roles")
+
+	set, err := RenderPolicies(policies["github"], "all", []string{"alice"}, map[string]Team{"sre": {}})
+	if err != nil {
+		t.Fatalf("unable to render policies: %v", err)
+	}
+	before, err := RenderPolicies(policies["github"], "all", []string{"alice", "bob"}, map[string]Team{"sre": {}, "web": {}})
+	if err != nil {
+		t.Fatalf("unable to render policies: %v", err)
+	}
+	if err := (&VaultStore{}).GeneratePoliciesAndRoles(before, roleDir, policyDir); err != nil {
+		t.Fatalf("unable to generate files: %v", err)
+	}
+	// Policies written by hand are never pruned, even when named like the ones psst generates
+	if err := ioutil.WriteFile(filepath.Join(policyDir, "psst-admins.hcl"), []byte("# Written by hand\n"), 0644); err != nil {
+		t.Fatalf("unable to write policy: %v", err)
+	}
+
+	changes, err := PlanFilePrune(policyDir, roleDir, set)
+	if err != nil {
+		t.Fatalf("unable to plan: %v", err)
+	}
+	got := []string{}
+	for _, c := range changes {
+		got = append(got, c.Action()+" "+c.Kind+" "+c.Name)
+	}
+	expected := []string{"delete policy psst-bob", "delete policy psst-web", "delete user bob", "delete team web"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+
+	if err := ApplyFileChanges(changes); err != nil {
+		t.Fatalf("unable to apply: %v", err)
+	}
+	changes, err = PlanFilePrune(policyDir, roleDir, set)
+	if err != nil {
+		t.Fatalf("unable to plan: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("got: %v, expected no changes after pruning", changes)
+	}
+	for _, name := range []string{"psst-alice.hcl", "psst-admins.hcl"} {
+		if _, err := os.Stat(filepath.Join(policyDir, name)); err != nil {
+			t.Errorf("expected %s to be kept: %v", name, err)
+		}
+	}
+}
+
+func TestPruneDrops(t *testing.T) {
+	at := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	newBackend := func() *memBackend {
+		return &memBackend{archived: map[string]Secret{}, secrets: map[string]Secret{
+			"alice/db":                 {Value: "a"},
+			"bob/db":                   {Value: "b", Metadata: Metadata{Sender: "alice"}},
+			"bob/prod/api":             {Value: "c"},
+			"bob/revoked":              {Metadata: Metadata{Revoked: at}},
+			"bob/.psst/retrieved/x/db": {},
+			"sre/db":                   {Value: "d"},
+		}}
+	}
+
+	b := newBackend()
+	orphaned, err := OrphanedDrops(context.Background(), b, []string{"Alice", "sre"})
+	if err != nil {
+		t.Fatalf("unable to find orphaned drops: %v", err)
+	}
+	if !reflect.DeepEqual(orphaned, []string{"bob"}) {
+		t.Errorf("got: %v, expected: [bob]", orphaned)
+	}
+
+	n, err := ArchiveDrop(context.Background(), b, "bob", at)
+	if err != nil {
+		t.Fatalf("unable to archive: %v", err)
+	}
+	expected := []string{"alice/db", "sre/db"}
+	if n != 2 || !reflect.DeepEqual(b.paths(), expected) {
+		t.Errorf("got: %d %v, expected: 2 %v", n, b.paths(), expected)
+	}
+	if s, ok := b.archived["bob/20180601-120000/db"]; !ok || s.Sender != "alice" {
+		t.Errorf("got: %+v, expected the secret to be archived with its metadata", b.archived)
+	}
+	if _, ok := b.archived["bob/20180601-120000/prod/api"]; !ok || len(b.archived) != 2 {
+		t.Errorf("got: %+v, expected only the secrets that weren't revoked to be archived", b.archived)
+	}
+
+	b = newBackend()
+	n, err = WipeDrop(context.Background(), b, "bob")
+	if err != nil {
+		t.Fatalf("unable to wipe: %v", err)
+	}
+	if n != 3 || !reflect.DeepEqual(b.paths(), expected) || len(b.archived) != 0 {
+		t.Errorf("got: %d %v, expected: 3 %v", n, b.paths(), expected)
+	}
+}
+
+func TestPlanPrune(t *testing.T) {
+	set := PolicySet{Policies: map[string]string{"psst": "", "psst-Alice": ""}}
+	rules := generatedMarker + "\nrules"
+	actual := PolicySet{
+		Policies: map[string]string{"psst": rules, "psst-alice": rules, "psst-bob": rules, "psst-audit": "rules", "admin": "rules"},
+		Users:    map[string][]string{"alice": []string{"psst-alice", "psst-audit"}, "bob": []string{"admin", "psst-bob"}},
+		Teams:    map[string][]string{"all": []string{"psst"}},
+	}
+
+	got := []string{}
+	for _, c := range planPrune(actual, set) {
+		got = append(got, c.Action()+" "+c.Kind+" "+c.Name)
+	}
+	expected := []string{"delete policy psst-bob", "update user bob"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got: %v, expected: %v", got, expected)
+	}
+}
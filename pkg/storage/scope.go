@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// defaultScopeKey is the entry of a scope file applied to teams without their own
+const defaultScopeKey = "*"
+
+// TeamScope is what the members and the maintainers of a team may do in the team drop, as Vault
+// capabilities
+type TeamScope struct {
+	Members     []string `json:"members"`
+	Maintainers []string `json:"maintainers,omitempty"`
+}
+
+// DefaultTeamScope lets every member of a team manage the secrets in the team drop
+var DefaultTeamScope = TeamScope{
+	Members:     []string{"create", "update", "read", "list", "delete"},
+	Maintainers: []string{"create", "update", "read", "list", "delete"},
+}
+
+// Team is a team with a drop, its maintainers and what they and the other members may do in it
+type Team struct {
+	Members     []string
+	Maintainers []string
+	Scope       TeamScope
+}
+
+// Validate checks that the scope only uses capabilities Vault knows. Maintainers get the same
+// capabilities as members unless they are given their own.
+func (s TeamScope) Validate() error {
+	if len(s.Members) == 0 {
+		return fmt.Errorf("members need at least one capability")
+	}
+	for _, c := range append(append([]string{}, s.Members...), s.Maintainers...) {
+		if _, ok := capabilities[c]; !ok {
+			return fmt.Errorf("unknown capability %q", c)
+		}
+	}
+	return nil
+}
+
+// split checks if maintainers get different capabilities than the other members and need a policy
+// of their own
+func (s TeamScope) split() bool {
+	if len(s.Maintainers) == 0 {
+		return false
+	}
+	members := append([]string{}, s.Members...)
+	maintainers := append([]string{}, s.Maintainers...)
+	sort.Strings(members)
+	sort.Strings(maintainers)
+	return strings.Join(members, ",") != strings.Join(maintainers, ",")
+}
+
+// LoadTeamScopes reads team scopes by team name from a JSON file such as
+//
+//	{"sre": {"members": ["read", "list"], "maintainers": ["create", "update", "read", "list", "delete"]}}
+//
+// The "*" entry applies to every team without its own.
+func LoadTeamScopes(filename string) (map[string]TeamScope, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read team scopes: %+v", err)
+	}
+	scopes := map[string]TeamScope{}
+	if err := json.Unmarshal(b, &scopes); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal team scopes %s: %+v", filename, err)
+	}
+	for team, s := range scopes {
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid scope for team %s: %v", team, err)
+		}
+	}
+	return scopes, nil
+}
+
+// ScopeFor returns the scope of team in scopes, falling back to the "*" entry and then to
+// DefaultTeamScope. Team names are compared ignoring case like GitHub does.
+func ScopeFor(scopes map[string]TeamScope, team string) TeamScope {
+	for name, s := range scopes {
+		if strings.EqualFold(name, team) {
+			return s
+		}
+	}
+	if s, ok := scopes[defaultScopeKey]; ok {
+		return s
+	}
+	return DefaultTeamScope
+}
+
+// RequireCapability checks that the current token may use capability on the drop of entity, so
+// actions a team scope doesn't allow fail with a clear error before reaching Vault
+func RequireCapability(ctx context.Context, b Backend, entity, capability string) error {
+	caps, err := b.DropCapabilities(ctx, entity)
+	if err != nil {
+		return err
+	}
+	for _, c := range caps {
+		if c == capability || c == "root" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the scope of %s doesn't let you %s its secrets (you have %s), ask a maintainer of the team",
+		ErrForbidden, entity, capability, strings.Join(caps, ", "))
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// capsBackend answers capability checks from a fixed map of drops
+type capsBackend struct {
+	Backend
+
+	caps map[string][]string
+}
+
+func (c *capsBackend) DropCapabilities(ctx context.Context, entity string) ([]string, error) {
+	return c.caps[entity], nil
+}
+
+func TestLoadTeamScopes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-scopes-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := map[string]struct {
+		Content string
+		Valid   bool
+	}{
+		"ValidTest":         {Content: `{"sre": {"members": ["read", "list"], "maintainers": ["read", "list", "delete"]}}`, Valid: true},
+		"DefaultTest":       {Content: `{"*": {"members": ["create", "update"]}}`, Valid: true},
+		"NoMembersTest":     {Content: `{"sre": {"maintainers": ["read"]}}`},
+		"BadCapabilityTest": {Content: `{"sre": {"members": ["write"]}}`},
+		"SyntaxTest":        {Content: `{"sre": `},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := filepath.Join(dir, name+".json")
+			if err := ioutil.WriteFile(f, []byte(c.Content), 0600); err != nil {
+				t.Fatalf("unable to write scopes: %v", err)
+			}
+			_, err := LoadTeamScopes(f)
+			if (err == nil) != c.Valid {
+				t.Errorf("Name: %s, got: %v, expected valid: %v", name, err, c.Valid)
+			}
+		})
+	}
+}
+
+func TestScopeFor(t *testing.T) {
+	readOnly := TeamScope{Members: []string{"read", "list"}}
+	writeOnly := TeamScope{Members: []string{"create", "update"}}
+
+	if s := ScopeFor(map[string]TeamScope{"SRE": readOnly}, "sre"); !reflect.DeepEqual(s, readOnly) {
+		t.Errorf("got: %v, expected: %v", s, readOnly)
+	}
+	if s := ScopeFor(map[string]TeamScope{"sre": readOnly, "*": writeOnly}, "web"); !reflect.DeepEqual(s, writeOnly) {
+		t.Errorf("got: %v, expected: %v", s, writeOnly)
+	}
+	if s := ScopeFor(map[string]TeamScope{}, "web"); !reflect.DeepEqual(s, DefaultTeamScope) {
+		t.Errorf("got: %v, expected: %v", s, DefaultTeamScope)
+	}
+}
+
+func TestRenderScopedTeam(t *testing.T) {
+	scope := TeamScope{Members: []string{"read", "list"}, Maintainers: []string{"create", "update", "read", "list", "delete"}}
+	teams := map[string]Team{
+		"sre": {Members: []string{"alice", "bob"}, Maintainers: []string{"bob"}, Scope: scope},
+		"web": {Members: []string{"alice"}},
+	}
+	set, err := RenderPolicies(policies["github"], "all", []string{"alice", "bob"}, teams)
+	if err != nil {
+		t.Fatalf("unable to render policies: %v", err)
+	}
+
+	expected := generatedMarker + `
+# Allows a team to read and write secrets to and from drop keyspace
+path "/secret/psst/sre/*" {
+	capabilities = ["read", "list"]
+}
+`
+	if set.Policies["psst-sre"] != expected {
+		t.Errorf("got: %s, expected: %s", set.Policies["psst-sre"], expected)
+	}
+	expected = generatedMarker + `
+# Allows the maintainers of a team to manage secrets in drop keyspace
+path "/secret/psst/sre/*" {
+	capabilities = ["create", "update", "read", "list", "delete"]
+}
+`
+	if set.Policies["psst-sre-maintainers"] != expected {
+		t.Errorf("got: %s, expected: %s", set.Policies["psst-sre-maintainers"], expected)
+	}
+	expected = generatedMarker + `
+# Allows a team to read and write secrets to and from drop keyspace
+path "/secret/psst/web/*" {
+	capabilities = ["create", "update", "read", "list", "delete"]
+}
+`
+	if set.Policies["psst-web"] != expected {
+		t.Errorf("got: %s, expected the default scope: %s", set.Policies["psst-web"], expected)
+	}
+	if _, ok := set.Policies["psst-web-maintainers"]; ok {
+		t.Errorf("expected no maintainer policy for a team with the default scope")
+	}
+
+	expectedUsers := map[string][]string{"alice": []string{"psst-alice"}, "bob": []string{"psst-bob", "psst-sre-maintainers"}}
+	if !reflect.DeepEqual(set.Users, expectedUsers) {
+		t.Errorf("got users: %v, expected: %v", set.Users, expectedUsers)
+	}
+}
+
+func TestRequireCapability(t *testing.T) {
+	b := &capsBackend{caps: map[string][]string{"sre": []string{"read", "list"}, "ops": []string{"root"}}}
+
+	cases := map[string]struct {
+		Entity     string
+		Capability string
+		Allowed    bool
+	}{
+		"AllowedTest": {Entity: "sre", Capability: "read", Allowed: true},
+		"DeniedTest":  {Entity: "sre", Capability: "delete"},
+		"RootTest":    {Entity: "ops", Capability: "delete", Allowed: true},
+		"NoneTest":    {Entity: "web", Capability: "list"},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := RequireCapability(context.Background(), b, c.Entity, c.Capability)
+			if (err == nil) != c.Allowed {
+				t.Errorf("Name: %s, got: %v, expected allowed: %v", name, err, c.Allowed)
+			}
+			if err != nil && !errors.Is(err, ErrForbidden) {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, err, ErrForbidden)
+			}
+		})
+	}
+}
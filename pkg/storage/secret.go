@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// reservedFolder is the hidden folder in each drop holding what psst keeps for itself. Secret
+	// names can't start with it.
+	reservedFolder = ".psst"
+	// retrievedFolder is where, in a sender's drop, recipients mark secrets as retrieved
+	retrievedFolder = reservedFolder + "/retrieved"
+
+	senderKey      = "sender"
+	createdKey     = "created"
+	expiresKey     = "expires"
+	revokedKey     = "revoked"
+	originKey      = "origin"
+	viaKey         = "via"
+	retrievedByKey = "retrieved_by"
+	retrievedKey   = "retrieved"
+	hostKey        = "host"
+)
+
+// Metadata describes who shared a secret, when, and for how long it is valid
+type Metadata struct {
+	Sender  string
+	Created time.Time
+	// Expires is zero for secrets that never expire
+	Expires time.Time
+	// Revoked is set when the sender revoked the secret, the value is removed
+	Revoked time.Time
+	// Origin is who first shared a forwarded secret
+	Origin string
+	// Via lists who forwarded the secret, oldest first
+	Via []string
+}
+
+// Forward returns the metadata for a secret forwarded by login, keeping its provenance and expiry
+func (m Metadata) Forward(login string, now time.Time) Metadata {
+	origin := m.Origin
+	if origin == "" {
+		origin = m.Sender
+	}
+	return Metadata{
+		Sender:  login,
+		Created: now,
+		Expires: m.Expires,
+		Origin:  origin,
+		Via:     append(append([]string{}, m.Via...), login),
+	}
+}
+
+// Secret is a stored secret value along with its metadata
+type Secret struct {
+	Value string
+	Metadata
+}
+
+// Receipt records that a recipient retrieved a secret
+type Receipt struct {
+	By   string
+	Host string
+	At   time.Time
+}
+
+// Expired checks if the secret has expired at now
+func (m Metadata) Expired(now time.Time) bool {
+	return !m.Expires.IsZero() && now.After(m.Expires)
+}
+
+// Hidden checks if a listed name is used by psst itself and shouldn't be shown to users
+func Hidden(name string) bool {
+	return name == reservedFolder || strings.HasPrefix(name, reservedFolder+"/")
+}
+
+// IsFolder checks if a listed name is a folder holding secrets with hierarchical names such as prod/db
+func IsFolder(name string) bool {
+	return strings.HasSuffix(name, "/")
+}
+
+// ValidateName checks that a secret name stays inside the drop it is written to. Names may be
+// hierarchical, but no part can be empty, '.', '..' or .psst, the folder psst keeps its own data in.
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("secret name can't be empty")
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" {
+			return fmt.Errorf("invalid secret name '%s': parts of a name can't be empty", name)
+		}
+		if part == "." || part == ".." {
+			return fmt.Errorf("invalid secret name '%s': parts of a name can't be '%s'", name, part)
+		}
+		if part == reservedFolder {
+			return fmt.Errorf("invalid secret name '%s': %s is reserved for psst", name, reservedFolder)
+		}
+	}
+	return nil
+}
+
+// RetrievedName returns the name, relative to the sender's drop, of the marker a recipient writes
+// after retrieving the secret name from the drop of target
+func RetrievedName(target, name string) string {
+	return path.Join(retrievedFolder, target, name)
+}
+
+func (m Metadata) data() map[string]interface{} {
+	data := make(map[string]interface{})
+	if m.Sender != "" {
+		data[senderKey] = m.Sender
+	}
+	setTime(data, createdKey, m.Created)
+	setTime(data, expiresKey, m.Expires)
+	setTime(data, revokedKey, m.Revoked)
+	if m.Origin != "" {
+		data[originKey] = m.Origin
+	}
+	if len(m.Via) > 0 {
+		data[viaKey] = m.Via
+	}
+	return data
+}
+
+func metadataFromData(data map[string]interface{}) Metadata {
+	m := Metadata{}
+	m.Sender, _ = data[senderKey].(string)
+	m.Created = getTime(data, createdKey)
+	m.Expires = getTime(data, expiresKey)
+	m.Revoked = getTime(data, revokedKey)
+	m.Origin, _ = data[originKey].(string)
+	m.Via = getStrings(data, viaKey)
+	return m
+}
+
+// getStrings reads a list, which comes back from Vault as []interface{}
+func getStrings(data map[string]interface{}, key string) []string {
+	var out []string
+	switch l := data[key].(type) {
+	case []interface{}:
+		for _, v := range l {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+	case []string:
+		out = append(out, l...)
+	}
+	return out
+}
+
+func (r Receipt) data() map[string]interface{} {
+	data := map[string]interface{}{retrievedByKey: r.By}
+	if r.Host != "" {
+		data[hostKey] = r.Host
+	}
+	setTime(data, retrievedKey, r.At)
+	return data
+}
+
+func receiptFromData(data map[string]interface{}) Receipt {
+	r := Receipt{}
+	r.By, _ = data[retrievedByKey].(string)
+	r.Host, _ = data[hostKey].(string)
+	r.At = getTime(data, retrievedKey)
+	return r
+}
+
+func setTime(data map[string]interface{}, key string, t time.Time) {
+	if !t.IsZero() {
+		data[key] = t.UTC().Format(time.RFC3339)
+	}
+}
+
+// getTime returns the zero time for missing or malformed values so old secrets still load
+func getTime(data map[string]interface{}, key string) time.Time {
+	s, ok := data[key].(string)
+	if !ok {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetadataData(t *testing.T) {
+	created := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]Metadata{
+		"EmptyTest":   Metadata{},
+		"SenderTest":  Metadata{Sender: "alice", Created: created},
+		"ExpiresTest": Metadata{Sender: "alice", Created: created, Expires: created.Add(time.Hour)},
+		"RevokedTest": Metadata{Sender: "alice", Created: created, Revoked: created.Add(time.Minute)},
+		"ForwardTest": Metadata{Sender: "carol", Created: created, Origin: "alice", Via: []string{"bob", "carol"}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Round trip through JSON like Vault does
+			buf, err := json.Marshal(c.data())
+			if err != nil {
+				t.Fatalf("Name: %s, unable to marshal: %v", name, err)
+			}
+			data := map[string]interface{}{}
+			if err := json.Unmarshal(buf, &data); err != nil {
+				t.Fatalf("Name: %s, unable to unmarshal: %v", name, err)
+			}
+			got := metadataFromData(data)
+			if got.Sender != c.Sender || !got.Created.Equal(c.Created) || !got.Expires.Equal(c.Expires) || !got.Revoked.Equal(c.Revoked) ||
+				got.Origin != c.Origin || strings.Join(got.Via, ",") != strings.Join(c.Via, ",") {
+				t.Errorf("Name: %s, got: %+v, expected: %+v", name, got, c)
+			}
+		})
+	}
+
+	// Secrets written before metadata existed only hold the value
+	old := metadataFromData(map[string]interface{}{vaultSecretName: "value", createdKey: "yesterday"})
+	if old.Sender != "" || !old.Created.IsZero() {
+		t.Errorf("got: %+v, expected empty metadata", old)
+	}
+}
+
+func TestForward(t *testing.T) {
+	created := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	now := created.Add(time.Hour)
+
+	cases := map[string]struct {
+		Metadata Metadata
+		Expected Metadata
+	}{
+		"FirstForwardTest": {
+			Metadata: Metadata{Sender: "alice", Created: created, Expires: created.Add(24 * time.Hour)},
+			Expected: Metadata{Sender: "bob", Created: now, Expires: created.Add(24 * time.Hour), Origin: "alice", Via: []string{"bob"}},
+		},
+		"SecondForwardTest": {
+			Metadata: Metadata{Sender: "bob", Created: created, Origin: "alice", Via: []string{"bob"}},
+			Expected: Metadata{Sender: "bob", Created: now, Origin: "alice", Via: []string{"bob", "bob"}},
+		},
+		"UnknownSenderTest": {
+			Metadata: Metadata{},
+			Expected: Metadata{Sender: "bob", Created: now, Via: []string{"bob"}},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := c.Metadata.Forward("bob", now)
+			if got.Sender != c.Expected.Sender || !got.Created.Equal(c.Expected.Created) || !got.Expires.Equal(c.Expected.Expires) ||
+				got.Origin != c.Expected.Origin || strings.Join(got.Via, ",") != strings.Join(c.Expected.Via, ",") {
+				t.Errorf("Name: %s, got: %+v, expected: %+v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		Expires  time.Time
+		Expected bool
+	}{
+		"NeverTest":  {Expected: false},
+		"FutureTest": {Expires: now.Add(time.Second), Expected: false},
+		"PastTest":   {Expires: now.Add(-time.Second), Expected: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := (Metadata{Expires: c.Expires}).Expired(now); got != c.Expected {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestReceiptData(t *testing.T) {
+	r := Receipt{By: "bob", Host: "bobs-laptop", At: time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)}
+	got := receiptFromData(r.data())
+	if got.By != r.By || got.Host != r.Host || !got.At.Equal(r.At) {
+		t.Errorf("got: %+v, expected: %+v", got, r)
+	}
+
+	for _, name := range []string{RetrievedName("sre", "db"), reservedFolder + "/"} {
+		if !Hidden(name) {
+			t.Errorf("got: %s, expected a hidden name", name)
+		}
+	}
+	for _, name := range []string{".env", ".psstrc", "prod/.npmrc"} {
+		if Hidden(name) {
+			t.Errorf("got: %s, expected a name shown to users", name)
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	cases := map[string]struct {
+		Name string
+		Err  bool
+	}{
+		"PlainTest":        {Name: "db"},
+		"NestedTest":       {Name: "prod/db"},
+		"EmptyTest":        {Name: "", Err: true},
+		"ParentTest":       {Name: "../bob/db", Err: true},
+		"ReservedTest":     {Name: ".psst/retrieved/bob/db", Err: true},
+		"AbsoluteTest":     {Name: "/db", Err: true},
+		"TrailingTest":     {Name: "prod/", Err: true},
+		"EmptyPartTest":    {Name: "prod//db", Err: true},
+		"ReservedPartTest": {Name: "prod/.psst/db", Err: true},
+		"DotPartTest":      {Name: "prod/../db", Err: true},
+		"DotFileTest":      {Name: ".env"},
+		"DotNestedTest":    {Name: "prod/.npmrc"},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateName(c.Name); (err != nil) != c.Err {
+				t.Errorf("Name: %s, got: %v, expected error: %v", name, err, c.Err)
+			}
+		})
+	}
+}
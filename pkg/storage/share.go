@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxParallelWrites bounds the number of targets written to at once
+	maxParallelWrites = 8
+
+	// RollbackTimeout bounds how long revoking the copies of a failed share can take. Shares are
+	// often interrupted with Ctrl-C, so rolling back doesn't use the caller's context.
+	RollbackTimeout = 30 * time.Second
+)
+
+// WriteResult is the outcome of writing a secret to a single target
+type WriteResult struct {
+	Target string
+	Err    error
+	// RolledBack is set when the write succeeded but was revoked because another target failed
+	RolledBack bool
+}
+
+// Results are the per-target outcomes of a share, sorted by target
+type Results []WriteResult
+
+// Received returns the targets that ended up with the secret
+func (r Results) Received() []string {
+	targets := []string{}
+	for _, w := range r {
+		if w.Err == nil && !w.RolledBack {
+			targets = append(targets, w.Target)
+		}
+	}
+	return targets
+}
+
+// Failed returns the results of the targets that couldn't be written to
+func (r Results) Failed() Results {
+	failed := Results{}
+	for _, w := range r {
+		if w.Err != nil {
+			failed = append(failed, w)
+		}
+	}
+	return failed
+}
+
+// Share writes a secret to every target concurrently. Unless bestEffort is set, a failure for any
+// target revokes the copies already written so either everyone or no one receives the secret.
+// Senders can't delete from other drops, so rolled back copies are left as tombstones the recipient
+// removes on their next get.
+func Share(ctx context.Context, b Backend, secret, name string, meta Metadata, targets map[string]struct{}, bestEffort bool) (Results, error) {
+	results := make(Results, 0, len(targets))
+	for t := range targets {
+		results = append(results, WriteResult{Target: t})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Target < results[j].Target })
+
+	sem := make(chan struct{}, maxParallelWrites)
+	wg := sync.WaitGroup{}
+	for i := range results {
+		wg.Add(1)
+		go func(r *WriteResult) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			r.Err = b.WriteSecret(ctx, secret, name, meta, map[string]struct{}{r.Target: struct{}{}})
+		}(&results[i])
+	}
+	wg.Wait()
+
+	failed := results.Failed()
+	if len(failed) == 0 {
+		return results, nil
+	}
+
+	if bestEffort {
+		return results, fmt.Errorf("unable to share %s with %d of %d targets: %w", name, len(failed), len(results), targetErrors(failed))
+	}
+
+	written := make(map[string]struct{})
+	for _, t := range results.Received() {
+		written[t] = struct{}{}
+	}
+	if len(written) > 0 {
+		revoked := meta
+		revoked.Revoked = time.Now()
+		rollbackCtx, cancel := context.WithTimeout(context.Background(), RollbackTimeout)
+		defer cancel()
+		if err := b.Revoke(rollbackCtx, name, revoked, written); err != nil {
+			return results, fmt.Errorf("unable to share %s (%v) and unable to roll back: %w", name, targetErrors(failed), err)
+		}
+		for i := range results {
+			if _, ok := written[results[i].Target]; ok {
+				results[i].RolledBack = true
+			}
+		}
+	}
+	return results, fmt.Errorf("unable to share %s, no one received it: %w", name, targetErrors(failed))
+}
+
+// targetErrors joins the errors of failed targets. It unwraps to the first one so callers can
+// still check why sharing failed with errors.Is.
+type targetErrors Results
+
+func (t targetErrors) Error() string {
+	errs := []string{}
+	for _, f := range t {
+		errs = append(errs, fmt.Sprintf("%s: %v", f.Target, f.Err))
+	}
+	return strings.Join(errs, "; ")
+}
+
+func (t targetErrors) Unwrap() error {
+	if len(t) == 0 {
+		return nil
+	}
+	return t[0].Err
+}
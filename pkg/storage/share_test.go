@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// shareBackend records writes and revocations, failing writes for some targets
+type shareBackend struct {
+	Backend
+
+	fail map[string]bool
+	// interrupt is called when a write fails, like Ctrl-C in the middle of a share
+	interrupt context.CancelFunc
+
+	mu      sync.Mutex
+	written map[string]string
+	revoked map[string]bool
+	active  int
+	peak    int
+}
+
+func newShareBackend(fail ...string) *shareBackend {
+	b := &shareBackend{fail: map[string]bool{}, written: map[string]string{}, revoked: map[string]bool{}}
+	for _, f := range fail {
+		b.fail[f] = true
+	}
+	return b
+}
+
+func (b *shareBackend) WriteSecret(ctx context.Context, secret, name string, meta Metadata, targets map[string]struct{}) error {
+	b.mu.Lock()
+	b.active++
+	if b.active > b.peak {
+		b.peak = b.active
+	}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.active--
+		b.mu.Unlock()
+	}()
+
+	for t := range targets {
+		if b.fail[t] {
+			if b.interrupt != nil {
+				b.interrupt()
+				return ctx.Err()
+			}
+			return errors.New("permission denied")
+		}
+		b.mu.Lock()
+		b.written[t] = secret
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+func (b *shareBackend) Revoke(ctx context.Context, name string, meta Metadata, targets map[string]struct{}) error {
+	if meta.Revoked.IsZero() {
+		return errors.New("expected a revocation time")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for t := range targets {
+		b.revoked[t] = true
+	}
+	return nil
+}
+
+func targetSet(targets ...string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, t := range targets {
+		set[t] = struct{}{}
+	}
+	return set
+}
+
+func TestShare(t *testing.T) {
+	cases := map[string]struct {
+		Fail       []string
+		BestEffort bool
+		Received   []string
+		Failed     []string
+		Revoked    []string
+		Err        bool
+	}{
+		"SuccessTest": {
+			Received: []string{"alice", "bob", "sre"},
+			Failed:   []string{},
+			Revoked:  []string{},
+		},
+		"RollbackTest": {
+			Fail:     []string{"bob"},
+			Received: []string{},
+			Failed:   []string{"bob"},
+			Revoked:  []string{"alice", "sre"},
+			Err:      true,
+		},
+		"BestEffortTest": {
+			Fail:       []string{"bob"},
+			BestEffort: true,
+			Received:   []string{"alice", "sre"},
+			Failed:     []string{"bob"},
+			Revoked:    []string{},
+			Err:        true,
+		},
+		"AllFailTest": {
+			Fail:     []string{"alice", "bob", "sre"},
+			Received: []string{},
+			Failed:   []string{"alice", "bob", "sre"},
+			Revoked:  []string{},
+			Err:      true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			b := newShareBackend(c.Fail...)
+			results, err := Share(context.Background(), b, "value", "db", Metadata{Sender: "carol"}, targetSet("sre", "alice", "bob"), c.BestEffort)
+			if (err != nil) != c.Err {
+				t.Errorf("Name: %s, got: %v, expected error: %v", name, err, c.Err)
+			}
+			if got := results.Received(); !reflect.DeepEqual(got, c.Received) {
+				t.Errorf("Name: %s, got received: %v, expected: %v", name, got, c.Received)
+			}
+			failed := []string{}
+			for _, f := range results.Failed() {
+				failed = append(failed, f.Target)
+			}
+			if !reflect.DeepEqual(failed, c.Failed) {
+				t.Errorf("Name: %s, got failed: %v, expected: %v", name, failed, c.Failed)
+			}
+			revoked := []string{}
+			for _, r := range results {
+				if r.RolledBack {
+					revoked = append(revoked, r.Target)
+					if !b.revoked[r.Target] {
+						t.Errorf("Name: %s, %s marked rolled back but not revoked", name, r.Target)
+					}
+				}
+			}
+			if !reflect.DeepEqual(revoked, c.Revoked) {
+				t.Errorf("Name: %s, got revoked: %v, expected: %v", name, revoked, c.Revoked)
+			}
+		})
+	}
+}
+
+func TestShareParallelism(t *testing.T) {
+	targets := []string{}
+	for i := 0; i < 3*maxParallelWrites; i++ {
+		targets = append(targets, string(rune('a'+i)))
+	}
+	b := newShareBackend()
+	results, err := Share(context.Background(), b, "value", "db", Metadata{}, targetSet(targets...), false)
+	if err != nil {
+		t.Fatalf("unable to share: %v", err)
+	}
+	if len(results.Received()) != len(targets) {
+		t.Errorf("got: %d received, expected: %d", len(results.Received()), len(targets))
+	}
+	if b.peak > maxParallelWrites {
+		t.Errorf("got: %d parallel writes, expected at most %d", b.peak, maxParallelWrites)
+	}
+}
+
+func TestShareInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newShareBackend("bob")
+	b.interrupt = cancel
+
+	results, err := Share(ctx, b, "value", "db", Metadata{}, targetSet("alice", "bob"), false)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got: %v, expected: %v", err, context.Canceled)
+	}
+	if len(results.Received()) != 0 || !b.revoked["alice"] {
+		t.Errorf("got: %+v, expected alice's copy to be rolled back after the interruption", results)
+	}
+}
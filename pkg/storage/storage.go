@@ -1,15 +1,30 @@
 package storage
 
+import (
+	"context"
+)
+
 const (
 	filePrefix = "psst"
 )
 
-// Backend gives us basic methods for storing secrets
+// Backend gives us basic methods for storing secrets. Errors wrap ErrNotFound, ErrForbidden,
+// ErrExpired, ErrRevoked or ErrUnavailable where they apply.
 type Backend interface {
-	Delete(string) error
-	Get(string) (string, error)
-	List(string) ([]string, error)
-	GeneratePoliciesAndRoles(string, string, string, string, []string) error
+	Delete(context.Context, string) error
+	Get(context.Context, string) (string, error)
+	GetSecret(context.Context, string) (*Secret, error)
+	GetReceipt(context.Context, string, string, string) (*Receipt, error)
+	List(context.Context, string) ([]string, error)
+	GeneratePoliciesAndRoles(PolicySet, string, string) error
 	SecretPath(string, string) string
-	Write(string, string, map[string]struct{}) error
+	WriteSecret(context.Context, string, string, Metadata, map[string]struct{}) error
+	ArchiveSecret(context.Context, string, *Secret) error
+	WriteReceipt(context.Context, string, string, string, Receipt) error
+	Revoke(context.Context, string, Metadata, map[string]struct{}) error
+	PlanPolicies(context.Context, PolicySet, string) ([]Change, error)
+	PlanPrune(context.Context, PolicySet, string) ([]Change, error)
+	ReadPolicies(context.Context, string) (PolicySet, error)
+	ApplyPolicies(context.Context, []Change, string) error
+	DropCapabilities(context.Context, string) ([]string, error)
 }
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var unsafeResourceName = regexp.MustCompile(`[^a-z0-9_-]`)
+
+type tfPolicy struct {
+	Name   string `json:"name"`
+	Policy string `json:"policy"`
+}
+
+type tfGitHubUser struct {
+	Backend  string   `json:"backend"`
+	User     string   `json:"user"`
+	Policies []string `json:"policies"`
+}
+
+type tfGitHubTeam struct {
+	Backend  string   `json:"backend"`
+	Team     string   `json:"team"`
+	Policies []string `json:"policies"`
+}
+
+type tfConfig struct {
+	Resource struct {
+		Policies map[string]tfPolicy     `json:"vault_policy,omitempty"`
+		Users    map[string]tfGitHubUser `json:"vault_github_user,omitempty"`
+		Teams    map[string]tfGitHubTeam `json:"vault_github_team,omitempty"`
+	} `json:"resource"`
+}
+
+// resourceName returns a Terraform resource name for a policy, user or team. Vault lowercases
+// them, so names only differing in case map to the same resource and renaming one doesn't churn.
+func resourceName(name string) string {
+	n := unsafeResourceName.ReplaceAllString(strings.ToLower(name), "_")
+	if n == "" || (n[0] >= '0' && n[0] <= '9') || n[0] == '-' {
+		n = "_" + n
+	}
+	return n
+}
+
+// TerraformConfig renders set as a Terraform JSON configuration of vault_policy, vault_github_user
+// and vault_github_team resources for the GitHub auth method mounted at authPath. Resources are
+// named after the policy, user or team they are for and keys are sorted, so the output only
+// changes where the directory did.
+func TerraformConfig(set PolicySet, authPath string) ([]byte, error) {
+	config := tfConfig{}
+	config.Resource.Policies = make(map[string]tfPolicy)
+	config.Resource.Users = make(map[string]tfGitHubUser)
+	config.Resource.Teams = make(map[string]tfGitHubTeam)
+	backend := strings.TrimPrefix(strings.Trim(authPath, "/"), "auth/")
+
+	for name, rules := range set.Policies {
+		r := resourceName(name)
+		if _, ok := config.Resource.Policies[r]; ok {
+			return nil, fmt.Errorf("policy %s clashes with another policy on the resource name %s", name, r)
+		}
+		config.Resource.Policies[r] = tfPolicy{Name: name, Policy: rules}
+	}
+
+	for user, policies := range set.Users {
+		r := resourceName(user)
+		if _, ok := config.Resource.Users[r]; ok {
+			return nil, fmt.Errorf("user %s clashes with another user on the resource name %s", user, r)
+		}
+		config.Resource.Users[r] = tfGitHubUser{Backend: backend, User: user, Policies: policyReferences(policies, set)}
+	}
+	for team, policies := range set.Teams {
+		r := resourceName(team)
+		if _, ok := config.Resource.Teams[r]; ok {
+			return nil, fmt.Errorf("team %s clashes with another team on the resource name %s", team, r)
+		}
+		config.Resource.Teams[r] = tfGitHubTeam{Backend: backend, Team: team, Policies: policyReferences(policies, set)}
+	}
+
+	b, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal Terraform configuration: %+v", err)
+	}
+	return append(b, '\n'), nil
+}
+
+// policyReferences returns the sorted policies of a mapping, referring to the vault_policy resources
+// of set so Terraform creates the policies first
+func policyReferences(policies []string, set PolicySet) []string {
+	refs := []string{}
+	for _, p := range policies {
+		if _, ok := set.Policies[p]; ok {
+			p = fmt.Sprintf("${vault_policy.%s.name}", resourceName(p))
+		}
+		refs = append(refs, p)
+	}
+	sort.Strings(refs)
+	return refs
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestResourceName(t *testing.T) {
+	cases := map[string]struct {
+		Name     string
+		Expected string
+	}{
+		"PolicyTest": {Name: "psst-alice", Expected: "psst-alice"},
+		"CaseTest":   {Name: "Alice", Expected: "alice"},
+		"DigitTest":  {Name: "42ops", Expected: "_42ops"},
+		"SpaceTest":  {Name: "site reliability", Expected: "site_reliability"},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := resourceName(c.Name); got != c.Expected {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestTerraformConfig(t *testing.T) {
+	set, err := RenderPolicies(policies["github"], "all", []string{"Alice"}, map[string]Team{"sre": {Members: []string{"Alice"}}})
+	if err != nil {
+		t.Fatalf("unable to render policies: %v", err)
+	}
+	set.Users["Alice"] = append(set.Users["Alice"], "admin")
+
+	b, err := TerraformConfig(set, "auth/github")
+	if err != nil {
+		t.Fatalf("unable to render Terraform configuration: %v", err)
+	}
+	again, err := TerraformConfig(set, "auth/github")
+	if err != nil || string(again) != string(b) {
+		t.Errorf("expected the same configuration when rendered twice")
+	}
+
+	config := tfConfig{}
+	if err := json.Unmarshal(b, &config); err != nil {
+		t.Fatalf("unable to unmarshal %s: %v", b, err)
+	}
+	if p := config.Resource.Policies["psst-alice"]; p.Name != "psst-Alice" || p.Policy != set.Policies["psst-Alice"] {
+		t.Errorf("got: %+v, expected alice's policy", p)
+	}
+	expectedUser := tfGitHubUser{Backend: "github", User: "Alice", Policies: []string{"${vault_policy.psst-alice.name}", "admin"}}
+	if u := config.Resource.Users["alice"]; !reflect.DeepEqual(u, expectedUser) {
+		t.Errorf("got: %+v, expected: %+v", u, expectedUser)
+	}
+	expectedTeam := tfGitHubTeam{Backend: "github", Team: "all", Policies: []string{"${vault_policy.psst.name}"}}
+	if team := config.Resource.Teams["all"]; !reflect.DeepEqual(team, expectedTeam) {
+		t.Errorf("got: %+v, expected: %+v", team, expectedTeam)
+	}
+}
@@ -1,14 +1,16 @@
 package storage
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"html/template"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/api"
 	"github.com/pkg/errors"
@@ -18,6 +20,14 @@ const (
 	keyPrefix       = "/secret/psst"
 	vaultSecretName = "secret"
 
+	// archivePrefix is where archived drops are moved. It is outside keyPrefix so the general policy
+	// doesn't let members write to it, and no psst policy grants reading it, so only Vault
+	// administrators can read archived secrets.
+	archivePrefix = "/secret/psst-archive"
+
+	// capabilityCheckName is a placeholder secret name used when asking Vault what a token can do
+	capabilityCheckName = "psst-capability-check"
+
 	filePerms = 0750
 )
 
@@ -31,26 +41,24 @@ type PolicyTemplates struct {
 type ghUserPolicy struct {
 	Value string `json:"value"`
 }
-type space struct {
-	Path string
-}
 
 var (
 	policies = map[string]PolicyTemplates{
 		"github": PolicyTemplates{
 			GeneralPolicyTemplate: `# Allows all users to write secrets to other users
-path "{{.Path}}/*" {
+{{range .Members}}path "{{$.Path}}/{{.}}/*" {
 	capabilities = ["create", "update"]
 }
-`,
+{{end}}`,
 			MemberPolicyTemplate: `# Allows a user to read secrets from personal drop keyspace
 path "{{.Path}}/*" {
 	capabilities = ["read", "list", "delete"]
 }
 `,
-			TeamPolicyTemplate: `# Allows a team to read and write secrets to and from drop keyspace
-path "{{.Path}}/*" {
-	capabilities = ["create", "update", "read", "list", "delete"]
+			TeamPolicyTemplate: `{{if .Maintainer}}# Allows the maintainers of a team to manage secrets in drop keyspace
+{{else}}# Allows a team to read and write secrets to and from drop keyspace
+{{end}}path "{{.Path}}/*" {
+	capabilities = [{{range $i, $c := .Capabilities}}{{if $i}}, {{end}}"{{$c}}"{{end}}]
 }
 `,
 		},
@@ -62,147 +70,252 @@ type VaultStore struct {
 	*api.Client
 }
 
-// NewVault will connect to a Vault server using VAULT_ADDR and VAUL_TOKEN variables
-func NewVault() (*VaultStore, error) {
-	client, err := api.NewClient(api.DefaultConfig())
+// NewVault will connect to a Vault server using conf over the VAULT_ADDR and VAULT_TOKEN variables,
+// or the token saved in ~/.vault-token. Without a token, EnsureToken logs in.
+func NewVault(conf VaultConfig) (*VaultStore, error) {
+	config, err := conf.apiConfig()
+	if err != nil {
+		return &VaultStore{}, fmt.Errorf("unable to configure Vault client: %+v", err)
+	}
+	client, err := api.NewClient(config)
 	if err != nil {
 		return &VaultStore{}, fmt.Errorf("unable to get Vault client: %+v", err)
 	}
+	client.SetHeaders(conf.headers())
 
 	if os.Getenv("VAULT_TOKEN") == "" {
-		tokenPath := path.Join(os.Getenv("HOME"), ".vault-token")
-		token, err := ioutil.ReadFile(tokenPath)
-		if err != nil {
-			return &VaultStore{}, fmt.Errorf("please login to vault and try again")
+		token, err := ioutil.ReadFile(TokenPath())
+		if err != nil && !os.IsNotExist(err) {
+			return &VaultStore{}, fmt.Errorf("unable to read Vault token: %+v", err)
 		}
-		client.SetToken(string(token))
-
+		client.SetToken(strings.TrimSpace(string(token)))
 	}
 	return &VaultStore{client}, nil
 }
 
-// Get will return the stored secret at a given path
-func (v *VaultStore) Get(path string) (string, error) {
-	secret, err := v.Client.Logical().Read(path)
-	if err != nil {
-		return "", fmt.Errorf("unable to read secret from vault: %+v", err)
-	}
-	if secret == nil {
-		return "", errors.New("no secret found")
+// request sends a request to Vault and parses the response. The Vault client doesn't support
+// contexts, so the request is abandoned rather than interrupted when ctx is done.
+func (v *VaultStore) request(ctx context.Context, method, p string, data map[string]interface{}) (*api.Secret, error) {
+	r := v.NewRequest(method, "/v1/"+strings.TrimPrefix(p, "/"))
+	if method == "LIST" {
+		r.Method = "GET"
+		r.Params.Set("list", "true")
 	}
-	if data, ok := secret.Data[vaultSecretName]; ok {
-		return data.(string), nil
+	if data != nil {
+		if err := r.SetJSONBody(data); err != nil {
+			return nil, fmt.Errorf("unable to encode request: %w", err)
+		}
 	}
-	return "", errors.New("improperly formatted secret")
+	return v.do(ctx, r)
 }
 
-// Write will write the provided secret to the given user
-func (v *VaultStore) Write(filename, name string, targets map[string]struct{}) error {
-	buf, err := ioutil.ReadFile(filename)
+// do sends r to Vault and parses the response, abandoning the request when ctx is done
+func (v *VaultStore) do(ctx context.Context, r *api.Request) (*api.Secret, error) {
+	resp, err := v.send(ctx, r)
 	if err != nil {
-		return fmt.Errorf("unable to read file %s: %+v", filename, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	secret, err := api.ParseSecret(resp.Body)
+	if err == io.EOF {
+		return nil, nil
 	}
+	return secret, err
+}
 
-	data := make(map[string]interface{})
-	data[vaultSecretName] = string(buf)
+// send sends r to Vault, abandoning the request when ctx is done. Failed requests are classified,
+// otherwise the caller closes the body of the response.
+func (v *VaultStore) send(ctx context.Context, r *api.Request) (*api.Response, error) {
+	type result struct {
+		resp *api.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := v.RawRequest(r)
+		done <- result{resp, err}
+	}()
+
+	var res result
+	select {
+	case <-ctx.Done():
+		go func() {
+			if res := <-done; res.resp != nil {
+				res.resp.Body.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	case res = <-done:
+	}
 
-	for t := range targets {
-		_, err := v.Logical().Write(path.Join(keyPrefix, t, name), data)
-		if err != nil {
-			return fmt.Errorf("unable to add secret for target %s: %+v", t, err)
+	if res.err != nil {
+		if res.resp != nil {
+			defer res.resp.Body.Close()
 		}
+		return nil, classify(res.resp, res.err)
 	}
-	return nil
+	return res.resp, nil
 }
 
-// List will list a set of secrets available
-func (v *VaultStore) List(login string) ([]string, error) {
-	path := getSecretPathPrefix(login)
-	secret, err := v.Client.Logical().List(path)
+// Get will return the stored secret at a given path
+func (v *VaultStore) Get(ctx context.Context, path string) (string, error) {
+	secret, err := v.GetSecret(ctx, path)
 	if err != nil {
-		return []string{}, fmt.Errorf("unable to list secrets at %s: %v", path, err)
+		return "", err
+	}
+	if !secret.Revoked.IsZero() {
+		return "", ErrRevoked
 	}
+	if secret.Expired(time.Now()) {
+		return "", ErrExpired
+	}
+	return secret.Value, nil
+}
 
+// GetSecret will return the stored secret at a given path along with its metadata. Revoked and
+// expired secrets are returned as is so the caller can decide what to do with them.
+func (v *VaultStore) GetSecret(ctx context.Context, path string) (*Secret, error) {
+	secret, err := v.request(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secret from vault: %w", err)
+	}
 	if secret == nil {
-		return []string{}, nil
+		return nil, fmt.Errorf("no secret found at %s: %w", path, ErrNotFound)
 	}
 
-	names := []string{}
-	for _, s := range secret.Data {
-		for _, v := range s.([]interface{}) {
-			names = append(names, v.(string))
+	s := &Secret{Metadata: metadataFromData(secret.Data)}
+	if data, ok := secret.Data[vaultSecretName]; ok {
+		s.Value, ok = data.(string)
+		if !ok {
+			return nil, errors.New("improperly formatted secret")
 		}
+	} else if s.Revoked.IsZero() {
+		return nil, errors.New("improperly formatted secret")
 	}
-	return names, nil
+	return s, nil
 }
 
-// Delete will delete a secret from Vault
-func (v *VaultStore) Delete(path string) error {
-	_, err := v.Client.Logical().Delete(path)
-	if err != nil {
-		return fmt.Errorf("unable to delete secret %s: %+v", path, err)
+// WriteSecret will write the provided secret value and its metadata to the given targets without
+// touching disk
+func (v *VaultStore) WriteSecret(ctx context.Context, secret, name string, meta Metadata, targets map[string]struct{}) error {
+	data := meta.data()
+	data[vaultSecretName] = secret
+
+	for t := range targets {
+		if _, err := v.request(ctx, "PUT", path.Join(keyPrefix, t, name), data); err != nil {
+			return fmt.Errorf("unable to add secret for target %s: %w", t, err)
+		}
 	}
 	return nil
 }
 
-// GeneratePoliciesAndRoles will generate a set of policies for a given directory of entities
-func (v *VaultStore) GeneratePoliciesAndRoles(directoryBackend, roleDir, policyDir, defaultTeam string, entities []string) error {
-	policies, ok := policies[directoryBackend]
-	if !ok {
-		return fmt.Errorf("unknown directory backend %s", directoryBackend)
+// ArchiveSecret writes secret along with its metadata to name in the archive
+func (v *VaultStore) ArchiveSecret(ctx context.Context, name string, secret *Secret) error {
+	data := secret.Metadata.data()
+	data[vaultSecretName] = secret.Value
+	if _, err := v.request(ctx, "PUT", path.Join(archivePrefix, name), data); err != nil {
+		return fmt.Errorf("unable to archive secret %s: %w", name, err)
 	}
+	return nil
+}
 
-	buf := bytes.NewBuffer([]byte{})
-
-	gt := template.Must(template.New("generalPolicy").Parse(policies.GeneralPolicyTemplate))
-	s := space{Path: keyPrefix}
-	if err := gt.Execute(buf, s); err != nil {
-		return fmt.Errorf("unable to execute template for general policy: %+v", err)
+// Revoke replaces the secret in each target's drop with a tombstone. Senders can only create and
+// update secrets in other drops, so the recipient removes the tombstone when they next read it.
+func (v *VaultStore) Revoke(ctx context.Context, name string, meta Metadata, targets map[string]struct{}) error {
+	if meta.Revoked.IsZero() {
+		meta.Revoked = time.Now()
 	}
-
-	_, err := os.Stat(roleDir)
-	if err != nil && os.IsNotExist(err) {
-		if err := os.MkdirAll(roleDir, 0700); err != nil {
-			return fmt.Errorf("unable to create role directory: %+v", err)
+	for t := range targets {
+		if _, err := v.request(ctx, "PUT", path.Join(keyPrefix, t, name), meta.data()); err != nil {
+			return fmt.Errorf("unable to revoke secret for target %s: %w", t, err)
 		}
 	}
+	return nil
+}
 
-	_, err = os.Stat(policyDir)
-	if err != nil && os.IsNotExist(err) {
-		if err := os.MkdirAll(policyDir, 0700); err != nil {
-			return fmt.Errorf("unable to create policy directory: %+v", err)
-		}
+// WriteReceipt marks the secret name in the drop of target as retrieved in the sender's drop
+func (v *VaultStore) WriteReceipt(ctx context.Context, sender, target, name string, receipt Receipt) error {
+	p := v.SecretPath(sender, RetrievedName(target, name))
+	if _, err := v.request(ctx, "PUT", p, receipt.data()); err != nil {
+		return fmt.Errorf("unable to write receipt for %s: %w", sender, err)
+	}
+	return nil
+}
+
+// GetReceipt returns the receipt for the secret name sent by sender to target, or nil if the secret
+// has not been retrieved
+func (v *VaultStore) GetReceipt(ctx context.Context, sender, target, name string) (*Receipt, error) {
+	p := v.SecretPath(sender, RetrievedName(target, name))
+	secret, err := v.request(ctx, "GET", p, nil)
+	if isNotFound(err) {
+		return nil, nil
 	}
-	p := path.Join(policyDir, fmt.Sprintf("%s.hcl", filePrefix))
-	if err := ioutil.WriteFile(p, buf.Bytes(), filePerms); err != nil {
-		return fmt.Errorf("unable to write general psst policy file: %+v", err)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read receipt %s: %w", p, err)
 	}
+	if secret == nil {
+		return nil, nil
+	}
+	r := receiptFromData(secret.Data)
+	return &r, nil
+}
 
-	t := template.Must(template.New("policy").Parse(policies.MemberPolicyTemplate))
+// List will list a set of secrets available. login may include a folder in the drop, such as
+// alice/prod, and folders are listed with a trailing slash.
+func (v *VaultStore) List(ctx context.Context, login string) ([]string, error) {
+	path := getSecretPathPrefix(login)
+	keys, err := v.listKeys(ctx, path)
+	if err != nil {
+		return []string{}, fmt.Errorf("unable to list secrets at %s: %w", path, err)
+	}
 
-	// Adds default role for the "all" team in GH
-	teamRoles := roleDir
-	if path.Base(roleDir) == "teams" {
-		if err := checkRole(defaultTeam, filePrefix, teamRoles); err != nil {
-			return fmt.Errorf(`unable to write "all" team role: %v`, err)
+	names := []string{}
+	for _, k := range keys {
+		if !Hidden(k) {
+			names = append(names, k)
 		}
-		t = template.Must(template.New("policy").Parse(policies.TeamPolicyTemplate))
 	}
+	return names, nil
+}
+
+// Delete will delete a secret from Vault
+func (v *VaultStore) Delete(ctx context.Context, path string) error {
+	if _, err := v.request(ctx, "DELETE", path, nil); err != nil {
+		return fmt.Errorf("unable to delete secret %s: %w", path, err)
+	}
+	return nil
+}
 
-	for _, e := range entities {
-		buf.Reset()
-		s = space{Path: getSecretPathPrefix(e)}
-		if err := t.Execute(buf, s); err != nil {
-			return fmt.Errorf("unable to execute template for user %s: %+v", e, err)
+// GeneratePoliciesAndRoles will write the policies of set to policyDir and the roles of its users and
+// teams to the users and teams folders of roleDir
+func (v *VaultStore) GeneratePoliciesAndRoles(set PolicySet, roleDir, policyDir string) error {
+	folders := map[string]map[string][]string{"users": set.Users, "teams": set.Teams}
+	for folder := range folders {
+		if err := os.MkdirAll(path.Join(roleDir, folder), 0700); err != nil {
+			return fmt.Errorf("unable to create role directory: %+v", err)
 		}
-		roleName := fmt.Sprintf("%s-%s", filePrefix, e)
-		p = path.Join(policyDir, fmt.Sprintf("%s.hcl", roleName))
-		if err := ioutil.WriteFile(p, buf.Bytes(), filePerms); err != nil {
-			return fmt.Errorf("unable to write policy file for user %s: %+v", e, err)
+	}
+	if err := os.MkdirAll(policyDir, 0700); err != nil {
+		return fmt.Errorf("unable to create policy directory: %+v", err)
+	}
+
+	for name, rules := range set.Policies {
+		p := path.Join(policyDir, fmt.Sprintf("%s.hcl", name))
+		if err := ioutil.WriteFile(p, []byte(rules), filePerms); err != nil {
+			return fmt.Errorf("unable to write policy file %s: %+v", p, err)
 		}
+	}
 
-		if err := checkRole(e, roleName, roleDir); err != nil {
-			return fmt.Errorf("Unable to setup role for %s: %v", e, err)
+	for folder, mapped := range folders {
+		for e, roles := range mapped {
+			for _, roleName := range roles {
+				if err := checkRole(e, roleName, path.Join(roleDir, folder)); err != nil {
+					return fmt.Errorf("Unable to setup role for %s: %v", e, err)
+				}
+			}
 		}
 	}
 	return nil
@@ -252,6 +365,156 @@ func checkRole(login, roleName, roleDir string) error {
 	return nil
 }
 
+// PlanPolicies compares the policies and GitHub mappings in Vault with set and returns the changes
+// needed to apply it. authPath is where the GitHub auth method is mounted, such as auth/github.
+func (v *VaultStore) PlanPolicies(ctx context.Context, set PolicySet, authPath string) ([]Change, error) {
+	changes := []Change{}
+	for name, rules := range set.Policies {
+		current, err := v.readField(ctx, path.Join("sys/policies/acl", name), "policy")
+		if err != nil {
+			return []Change{}, fmt.Errorf("unable to read policy %s: %w", name, err)
+		}
+		if c, ok := planPolicy(name, current, rules); ok {
+			changes = append(changes, c)
+		}
+	}
+
+	mappings := map[string]map[string][]string{UserChange: set.Users, TeamChange: set.Teams}
+	for kind, entities := range mappings {
+		for name, desired := range entities {
+			current, err := v.readField(ctx, mappingPath(authPath, kind, name), "value")
+			if err != nil {
+				return []Change{}, fmt.Errorf("unable to read policies mapped to %s %s: %w", kind, name, err)
+			}
+			if c, ok := planMapping(kind, name, current, desired); ok {
+				changes = append(changes, c)
+			}
+		}
+	}
+	SortChanges(changes)
+	return changes, nil
+}
+
+// PlanPrune returns the changes removing psst policies, and their mappings to GitHub users and teams,
+// that are no longer in set because the member or team left the organization. Maintainer policies
+// are also removed from users who no longer maintain the team.
+func (v *VaultStore) PlanPrune(ctx context.Context, set PolicySet, authPath string) ([]Change, error) {
+	actual, err := v.ReadPolicies(ctx, authPath)
+	if err != nil {
+		return []Change{}, err
+	}
+	return planPrune(actual, set), nil
+}
+
+// ReadPolicies returns the psst policies in Vault and the policies mapped to every GitHub user and
+// team. authPath is where the GitHub auth method is mounted, such as auth/github.
+func (v *VaultStore) ReadPolicies(ctx context.Context, authPath string) (PolicySet, error) {
+	actual := PolicySet{Policies: map[string]string{}, Users: map[string][]string{}, Teams: map[string][]string{}}
+	names, err := v.listKeys(ctx, "sys/policies/acl")
+	if err != nil {
+		return PolicySet{}, fmt.Errorf("unable to list policies: %w", err)
+	}
+	for _, name := range names {
+		if !isPsstPolicy(name) {
+			continue
+		}
+		if actual.Policies[name], err = v.readField(ctx, path.Join("sys/policies/acl", name), "policy"); err != nil {
+			return PolicySet{}, fmt.Errorf("unable to read policy %s: %w", name, err)
+		}
+	}
+
+	mappings := map[string]map[string][]string{UserChange: actual.Users, TeamChange: actual.Teams}
+	for kind, mapped := range mappings {
+		folder := path.Dir(mappingPath(authPath, kind, "x"))
+		entities, err := v.listKeys(ctx, folder)
+		if err != nil {
+			return PolicySet{}, fmt.Errorf("unable to list %s mappings: %w", kind, err)
+		}
+		for _, e := range entities {
+			value, err := v.readField(ctx, mappingPath(authPath, kind, e), "value")
+			if err != nil {
+				return PolicySet{}, fmt.Errorf("unable to read policies mapped to %s %s: %w", kind, e, err)
+			}
+			mapped[e] = splitPolicies(value)
+		}
+	}
+	return actual, nil
+}
+
+// listKeys lists the keys at p, or nothing if there is nothing there
+func (v *VaultStore) listKeys(ctx context.Context, p string) ([]string, error) {
+	secret, err := v.request(ctx, "LIST", p, nil)
+	if isNotFound(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return []string{}, err
+	}
+	if secret == nil {
+		return []string{}, nil
+	}
+	keys := []string{}
+	listed, _ := secret.Data["keys"].([]interface{})
+	for _, k := range listed {
+		if n, ok := k.(string); ok {
+			keys = append(keys, n)
+		}
+	}
+	return keys, nil
+}
+
+// ApplyPolicies writes changes returned by PlanPolicies or PlanPrune to Vault
+func (v *VaultStore) ApplyPolicies(ctx context.Context, changes []Change, authPath string) error {
+	for _, c := range changes {
+		var p string
+		var data map[string]interface{}
+		switch c.Kind {
+		case PolicyChange:
+			p, data = path.Join("sys/policies/acl", c.Name), map[string]interface{}{"policy": c.New}
+		case UserChange, TeamChange:
+			p, data = mappingPath(authPath, c.Kind, c.Name), map[string]interface{}{"value": c.New}
+		default:
+			return fmt.Errorf("unknown change %s", c.Kind)
+		}
+
+		var err error
+		if c.New == "" {
+			_, err = v.request(ctx, "DELETE", p, nil)
+		} else {
+			_, err = v.request(ctx, "PUT", p, data)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to %s %s %s: %w", c.Action(), c.Kind, c.Name, err)
+		}
+	}
+	return nil
+}
+
+// readField returns a string field of the data at p, or an empty string if there is nothing there
+func (v *VaultStore) readField(ctx context.Context, p, field string) (string, error) {
+	secret, err := v.request(ctx, "GET", p, nil)
+	if isNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", nil
+	}
+	value, _ := secret.Data[field].(string)
+	return value, nil
+}
+
+// mappingPath returns the path mapping policies to a GitHub user or team
+func mappingPath(authPath, kind, name string) string {
+	folder := "users"
+	if kind == TeamChange {
+		folder = "teams"
+	}
+	return path.Join(authPath, "map", folder, name)
+}
+
 func getSecretPathPrefix(login string) string {
 	return path.Join(keyPrefix, login)
 }
@@ -260,3 +523,27 @@ func getSecretPathPrefix(login string) string {
 func (v *VaultStore) SecretPath(login, name string) string {
 	return path.Join(getSecretPathPrefix(login), name)
 }
+
+// DropCapabilities returns the capabilities the current token has on secrets in the drop of entity
+func (v *VaultStore) DropCapabilities(ctx context.Context, entity string) ([]string, error) {
+	p := strings.TrimPrefix(v.SecretPath(entity, capabilityCheckName), "/")
+
+	// The capabilities are returned outside of the data of the response, so it isn't parsed as a
+	// secret
+	r := v.NewRequest("POST", "/v1/sys/capabilities-self")
+	if err := r.SetJSONBody(map[string]string{"path": p}); err != nil {
+		return []string{}, fmt.Errorf("unable to encode request: %w", err)
+	}
+	resp, err := v.send(ctx, r)
+	if err != nil {
+		return []string{}, fmt.Errorf("unable to get capabilities on %s: %w", p, err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Capabilities []string `json:"capabilities"`
+	}
+	if err := resp.DecodeJSON(&result); err != nil {
+		return []string{}, fmt.Errorf("unable to decode capabilities on %s: %w", p, err)
+	}
+	return result.Capabilities, nil
+}
@@ -1,10 +1,11 @@
 package storage
 
 import (
-	"io/ioutil"
+	"context"
 	"testing"
 
 	"github.com/dollarshaveclub/psst/pkg/storage/testhelper"
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/vault"
 )
 
@@ -28,35 +29,22 @@ func TestVault(t *testing.T) {
 
 	// Setup useful variables used across tests
 	v := &VaultStore{vClient}
+	ctx := context.Background()
 	login := "test-user"
 	name := "test-secret"
 	secretText := "this is a secret"
 	path := v.SecretPath(login, name)
 
-	// Setup a file with the secret
-	tmpFile, err := ioutil.TempFile("", "vault-test-")
-	if err != nil {
-		t.Fatalf("unable to create temporary file")
-	}
-	filename := tmpFile.Name()
-	if err := tmpFile.Close(); err != nil {
-		t.Fatalf("unable to close temporary file")
-	}
-	if err := ioutil.WriteFile(filename, []byte(secretText), 755); err != nil {
-		t.Fatalf("unable to populate data into temporary file")
-	}
-
 	targets := make(map[string]struct{})
 	targets[login] = struct{}{}
 
-	// Test Write
-	err = v.Write(filename, name, targets)
-	if err != nil {
-		t.Fatalf("write error: %+v", err)
+	// Test Share
+	if _, err := Share(ctx, v, secretText, name, Metadata{}, targets, false); err != nil {
+		t.Fatalf("share error: %+v", err)
 	}
 
 	// Test List
-	secrets, err := v.List(login)
+	secrets, err := v.List(ctx, login)
 	if err != nil {
 		t.Fatalf("unable to list: %+v", err)
 	}
@@ -71,7 +59,7 @@ func TestVault(t *testing.T) {
 	}
 
 	// Test Get
-	sec, err := v.Get(path)
+	sec, err := v.Get(ctx, path)
 	if err != nil {
 		t.Fatalf("get error: %+v", err)
 	}
@@ -80,11 +68,11 @@ func TestVault(t *testing.T) {
 	}
 
 	// Test Delete
-	if err := v.Delete(path); err != nil {
+	if err := v.Delete(ctx, path); err != nil {
 		t.Fatalf("unable to delete secret: %+v", err)
 	}
 
-	secrets2, err := v.List(login)
+	secrets2, err := v.List(ctx, login)
 	if err != nil {
 		t.Fatalf("unable to list: %+v", err)
 	}
@@ -95,3 +83,114 @@ func TestVault(t *testing.T) {
 	}
 
 }
+
+// TestApplyPolicies runs against a Vault cluster. The test cluster has no GitHub auth method, so the
+// mappings are written to a KV mount laid out the same way.
+func TestApplyPolicies(t *testing.T) {
+	testCluster, err := testhelper.BuildGoodCluster(t)
+	if err != nil {
+		t.Fatalf("unable to create test cluster: %v", err)
+	}
+	testCluster.Start()
+	defer testCluster.Cleanup()
+
+	core := testCluster.Cores[0].Core
+	vClient := testCluster.Cores[0].Client
+
+	vault.TestWaitActive(t, core)
+	if err := testCluster.UnsealWithStoredKeys(t); err != nil {
+		t.Fatalf("unsealing error: %+v", err)
+	}
+
+	v := &VaultStore{vClient}
+	ctx := context.Background()
+	authPath := "test-github"
+	if err := vClient.Sys().Mount(authPath, &api.MountInput{Type: "kv"}); err != nil {
+		t.Fatalf("unable to mount %s: %+v", authPath, err)
+	}
+	// Policies mapped by hand have to be kept
+	if _, err := vClient.Logical().Write(authPath+"/map/users/alice", map[string]interface{}{"value": "admin"}); err != nil {
+		t.Fatalf("unable to map alice: %+v", err)
+	}
+
+	set, err := RenderPolicies(policies["github"], "all", []string{"alice", "bob"}, map[string]Team{"sre": {Members: []string{"alice"}}})
+	if err != nil {
+		t.Fatalf("unable to render policies: %+v", err)
+	}
+
+	changes, err := v.PlanPolicies(ctx, set, authPath)
+	if err != nil {
+		t.Fatalf("unable to plan: %+v", err)
+	}
+	// 4 policies, 2 users and the sre and all teams
+	if len(changes) != 8 {
+		t.Fatalf("got: %d changes, expected: 8", len(changes))
+	}
+	if err := v.ApplyPolicies(ctx, changes, authPath); err != nil {
+		t.Fatalf("unable to apply: %+v", err)
+	}
+
+	rules, err := vClient.Sys().GetPolicy("psst-alice")
+	if err != nil {
+		t.Fatalf("unable to read policy: %+v", err)
+	}
+	if rules != set.Policies["psst-alice"] {
+		t.Errorf("got: %s, expected: %s", rules, set.Policies["psst-alice"])
+	}
+	mapped, err := v.readField(ctx, authPath+"/map/users/alice", "value")
+	if err != nil {
+		t.Fatalf("unable to read mapping: %+v", err)
+	}
+	if mapped != "admin,psst-alice" {
+		t.Errorf("got: %s, expected: admin,psst-alice", mapped)
+	}
+
+	changes, err = v.PlanPolicies(ctx, set, authPath)
+	if err != nil {
+		t.Fatalf("unable to plan: %+v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("got: %v, expected no changes after applying", changes)
+	}
+}
+
+// TestVaultTLS connects to the TLS listener of the test cluster with the psst Vault config
+func TestVaultTLS(t *testing.T) {
+	testCluster, err := testhelper.BuildGoodCluster(t)
+	if err != nil {
+		t.Fatalf("unable to create test cluster: %v", err)
+	}
+	testCluster.Start()
+	defer testCluster.Cleanup()
+	vault.TestWaitActive(t, testCluster.Cores[0].Core)
+	address := testCluster.Cores[0].Client.Address()
+
+	cases := map[string]struct {
+		Config VaultConfig
+		Valid  bool
+	}{
+		"CACertTest":     {Config: VaultConfig{Address: address, TLS: TLSConfig{CACert: testCluster.CACertPEMFile}}, Valid: true},
+		"ServerNameTest": {Config: VaultConfig{Address: address, TLS: TLSConfig{CACert: testCluster.CACertPEMFile, ServerName: "localhost"}}, Valid: true},
+		"WrongNameTest":  {Config: VaultConfig{Address: address, TLS: TLSConfig{CACert: testCluster.CACertPEMFile, ServerName: "vault.example.com"}}},
+		"UnknownCATest":  {Config: VaultConfig{Address: address}},
+	}
+
+	retries := 0
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			c.Config.Timeout = "5s"
+			c.Config.MaxRetries = &retries
+			if err := c.Config.Validate(); err != nil {
+				t.Fatalf("Name: %s, invalid config: %v", name, err)
+			}
+			v, err := NewVault(c.Config)
+			if err != nil {
+				t.Fatalf("Name: %s, unable to create client: %v", name, err)
+			}
+			_, err = v.Sys().Health()
+			if (err == nil) != c.Valid {
+				t.Errorf("Name: %s, got: %v, expected to connect: %v", name, err, c.Valid)
+			}
+		})
+	}
+}
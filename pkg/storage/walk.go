@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Walk lists every secret in the drop of entity, descending into folders, and returns the names
+// relative to the drop sorted alphabetically
+func Walk(ctx context.Context, b Backend, entity string) ([]string, error) {
+	// The cache walks the drop itself, walking it again here would list every folder twice
+	if c, ok := b.(*ListCache); ok {
+		return c.walk(ctx, entity)
+	}
+	listed, err := b.List(ctx, entity)
+	if err != nil {
+		return []string{}, err
+	}
+	return walkFolders(ctx, b, entity, listed)
+}
+
+// walkFolders descends into the folders in listed, the names at the top of the drop of entity, and
+// returns the names of every secret sorted alphabetically
+func walkFolders(ctx context.Context, b Backend, entity string, listed []string) ([]string, error) {
+	names := []string{}
+	if err := walk(ctx, b, entity, "", listed, &names); err != nil {
+		return []string{}, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func walk(ctx context.Context, b Backend, entity, folder string, listed []string, names *[]string) error {
+	for _, n := range listed {
+		if !IsFolder(n) {
+			*names = append(*names, path.Join(folder, n))
+			continue
+		}
+		sub := path.Join(folder, strings.TrimSuffix(n, "/"))
+		inner, err := b.List(ctx, path.Join(entity, sub))
+		if err != nil {
+			return err
+		}
+		if err := walk(ctx, b, entity, sub, inner, names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasFolder checks if any of names is a folder
+func hasFolder(names []string) bool {
+	for _, n := range names {
+		if IsFolder(n) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	backend := listBackend{names: map[string][]string{
+		"test-user":         []string{"prod/", "db", "api"},
+		"test-user/prod":    []string{"db", "eu/"},
+		"test-user/prod/eu": []string{"cache"},
+		"test-team":         []string{},
+	}}
+
+	cases := map[string]struct {
+		Entity   string
+		Expected []string
+	}{
+		"NestedTest": {Entity: "test-user", Expected: []string{"api", "db", "prod/db", "prod/eu/cache"}},
+		"EmptyTest":  {Entity: "test-team", Expected: []string{}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Walk(context.Background(), backend, c.Entity)
+			if err != nil {
+				t.Fatalf("Name: %s, unable to walk: %v", name, err)
+			}
+			if !reflect.DeepEqual(got, c.Expected) {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
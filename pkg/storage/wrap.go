@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// invalidWrapToken is how Vault answers for a wrapping token that was used, expired or never
+	// existed
+	invalidWrapToken = "wrapping token is not valid or does not exist"
+	// wrappedFolder is where, in the sender's drop, wrapping tokens are kept by accessor until they
+	// are seen used. Only the sender can read their drop.
+	wrappedFolder = reservedFolder + "/wrapped"
+	// wrapTokenField holds the wrapping token in the secret kept in the sender's drop
+	wrapTokenField = "token"
+)
+
+// WrappedSecret is a secret stored with Vault response wrapping. The token can be unwrapped once,
+// by anyone holding it, until it expires. The accessor identifies the token without unwrapping it.
+type WrappedSecret struct {
+	Token    string
+	Accessor string
+	Created  time.Time
+	Expires  time.Time
+}
+
+// Wrap stores value in a single use wrapping token valid for ttl, for recipients who aren't in the
+// directory and have no drop
+func (v *VaultStore) Wrap(ctx context.Context, value string, ttl time.Duration) (*WrappedSecret, error) {
+	r := v.NewRequest("POST", "/v1/sys/wrapping/wrap")
+	r.WrapTTL = fmt.Sprintf("%ds", int(ttl.Seconds()))
+	if err := r.SetJSONBody(map[string]interface{}{"value": value}); err != nil {
+		return nil, fmt.Errorf("unable to encode secret: %+v", err)
+	}
+	secret, err := v.do(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to wrap secret: %w", err)
+	}
+	if secret == nil || secret.WrapInfo == nil || secret.WrapInfo.Token == "" {
+		return nil, fmt.Errorf("unable to wrap secret: no wrapping token in the response")
+	}
+	info := secret.WrapInfo
+	created := info.CreationTime
+	if created.IsZero() {
+		created = time.Now()
+	}
+	return &WrappedSecret{
+		Token:    info.Token,
+		Accessor: info.Accessor,
+		Created:  created,
+		Expires:  created.Add(time.Duration(info.TTL) * time.Second),
+	}, nil
+}
+
+// Unwrap returns the secret in a wrapping token, which can't be used again afterwards. The wrapping
+// token is all that is needed, so this works without logging in to Vault.
+func (v *VaultStore) Unwrap(ctx context.Context, token string) (string, error) {
+	r := v.NewRequest("POST", "/v1/sys/wrapping/unwrap")
+	r.ClientToken = token
+	secret, err := v.do(ctx, r)
+	if err != nil {
+		if strings.Contains(err.Error(), invalidWrapToken) {
+			return "", fmt.Errorf("%w: the wrapping token was already used, has expired or is mistyped", ErrExpired)
+		}
+		return "", fmt.Errorf("unable to unwrap secret: %w", err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("unable to unwrap secret: empty response")
+	}
+	value, ok := secret.Data["value"].(string)
+	if !ok {
+		return "", fmt.Errorf("the wrapping token doesn't hold a secret shared with psst")
+	}
+	return value, nil
+}
+
+// WrapValid checks if a wrapping token can still be unwrapped without using it up. Tokens are
+// invalid once unwrapped or expired.
+func (v *VaultStore) WrapValid(ctx context.Context, token string) (bool, error) {
+	_, err := v.request(ctx, "POST", "sys/wrapping/lookup", map[string]interface{}{"token": token})
+	switch {
+	case err == nil:
+		return true, nil
+	case strings.Contains(err.Error(), invalidWrapToken):
+		return false, nil
+	}
+	return false, fmt.Errorf("unable to look up wrapping token: %w", err)
+}
+
+// KeepWrap keeps the token of wrapped in the drop of sender, where only they can read it, so psst
+// sent can look it up and psst revoke can use it up
+func (v *VaultStore) KeepWrap(ctx context.Context, sender string, wrapped *WrappedSecret) error {
+	p := v.SecretPath(sender, path.Join(wrappedFolder, wrapped.Accessor))
+	if _, err := v.request(ctx, "PUT", p, map[string]interface{}{wrapTokenField: wrapped.Token}); err != nil {
+		return fmt.Errorf("unable to keep wrapping token: %w", err)
+	}
+	return nil
+}
+
+// WrapPending checks if the wrapping token with accessor that sender kept can still be unwrapped.
+// Once it can't, the token is deleted from the drop of sender.
+func (v *VaultStore) WrapPending(ctx context.Context, sender, accessor string) (bool, error) {
+	p := v.SecretPath(sender, path.Join(wrappedFolder, accessor))
+	token, err := v.readField(ctx, p, wrapTokenField)
+	if err != nil {
+		return false, fmt.Errorf("unable to read wrapping token: %w", err)
+	}
+	if token == "" {
+		return false, fmt.Errorf("%w: no wrapping token kept at %s", ErrNotFound, p)
+	}
+	valid, err := v.WrapValid(ctx, token)
+	if err != nil || valid {
+		return valid, err
+	}
+	return false, v.Delete(ctx, p)
+}
+
+// RevokeWrap uses up the wrapping token with accessor that sender kept, so the secret can't be
+// unwrapped by anyone else, and deletes it from the drop of sender
+func (v *VaultStore) RevokeWrap(ctx context.Context, sender, accessor string) error {
+	p := v.SecretPath(sender, path.Join(wrappedFolder, accessor))
+	token, err := v.readField(ctx, p, wrapTokenField)
+	if err != nil {
+		return fmt.Errorf("unable to read wrapping token: %w", err)
+	}
+	if token == "" {
+		return fmt.Errorf("%w: no wrapping token kept at %s", ErrNotFound, p)
+	}
+	if _, err := v.Unwrap(ctx, token); err != nil {
+		return err
+	}
+	return v.Delete(ctx, p)
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// fakeWrapping answers the sys/wrapping endpoints of Vault, each token unwrapping once, and keeps
+// secrets written to the drops of psst
+func fakeWrapping(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	tokens := map[string]string{}
+	drops := map[string]string{}
+	invalid := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"errors": ["%s"]}`, invalidWrapToken)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if strings.HasPrefix(r.URL.Path, "/v1"+keyPrefix+"/") {
+			switch r.Method {
+			case "PUT":
+				drops[r.URL.Path] = body[wrapTokenField]
+				w.WriteHeader(http.StatusNoContent)
+			case "DELETE":
+				delete(drops, r.URL.Path)
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				token, ok := drops[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprintf(w, `{"data": {"%s": %q}}`, wrapTokenField, token)
+			}
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/sys/wrapping/wrap":
+			if r.Header.Get("X-Vault-Wrap-TTL") != "3600s" {
+				t.Errorf("got wrap TTL: %s, expected: 3600s", r.Header.Get("X-Vault-Wrap-TTL"))
+			}
+			token := fmt.Sprintf("s.wrap%d", len(tokens))
+			tokens[token] = body["value"]
+			fmt.Fprintf(w, `{"wrap_info": {"token": "%s", "accessor": "acc%d", "ttl": 3600, "creation_time": "2018-06-01T12:00:00Z"}}`, token, len(tokens))
+		case "/v1/sys/wrapping/lookup":
+			if _, ok := tokens[body["token"]]; !ok {
+				invalid(w)
+				return
+			}
+			fmt.Fprint(w, `{"data": {"creation_ttl": 3600}}`)
+		case "/v1/sys/wrapping/unwrap":
+			token := r.Header.Get("X-Vault-Token")
+			value, ok := tokens[token]
+			if !ok {
+				invalid(w)
+				return
+			}
+			delete(tokens, token)
+			fmt.Fprintf(w, `{"data": {"value": %q}}`, value)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestWrap(t *testing.T) {
+	srv := fakeWrapping(t)
+	defer srv.Close()
+
+	client, err := api.NewClient(&api.Config{Address: srv.URL, HttpClient: srv.Client()})
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	client.SetToken("sender")
+	v := &VaultStore{client}
+	ctx := context.Background()
+
+	wrapped, err := v.Wrap(ctx, "hunter2", time.Hour)
+	if err != nil {
+		t.Fatalf("unable to wrap secret: %v", err)
+	}
+	created := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	if !wrapped.Created.Equal(created) || !wrapped.Expires.Equal(created.Add(time.Hour)) {
+		t.Errorf("got: %v to %v, expected: %v to %v", wrapped.Created, wrapped.Expires, created, created.Add(time.Hour))
+	}
+
+	if valid, err := v.WrapValid(ctx, wrapped.Token); err != nil || !valid {
+		t.Errorf("got: %v %v, expected an unused token to be valid", valid, err)
+	}
+	if _, err := v.WrapPending(ctx, "alice", wrapped.Accessor); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got: %v, expected: %v before the token is kept", err, ErrNotFound)
+	}
+	if err := v.KeepWrap(ctx, "alice", wrapped); err != nil {
+		t.Fatalf("unable to keep wrapping token: %v", err)
+	}
+	if pending, err := v.WrapPending(ctx, "alice", wrapped.Accessor); err != nil || !pending {
+		t.Errorf("got: %v %v, expected an unused token to be pending", pending, err)
+	}
+
+	// Recipients have no token of their own
+	recipient := &VaultStore{client}
+	client.ClearToken()
+	value, err := recipient.Unwrap(ctx, wrapped.Token)
+	if err != nil || value != "hunter2" {
+		t.Errorf("got: %q %v, expected: hunter2", value, err)
+	}
+
+	if valid, err := v.WrapValid(ctx, wrapped.Token); err != nil || valid {
+		t.Errorf("got: %v %v, expected a used token to be invalid", valid, err)
+	}
+	client.SetToken("sender")
+	if pending, err := v.WrapPending(ctx, "alice", wrapped.Accessor); err != nil || pending {
+		t.Errorf("got: %v %v, expected a used token not to be pending", pending, err)
+	}
+	// A used token is deleted from the drop once seen
+	if err := v.RevokeWrap(ctx, "alice", wrapped.Accessor); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got: %v, expected: %v", err, ErrNotFound)
+	}
+
+	revoked, err := v.Wrap(ctx, "hunter3", time.Hour)
+	if err != nil {
+		t.Fatalf("unable to wrap secret: %v", err)
+	}
+	if err := v.KeepWrap(ctx, "alice", revoked); err != nil {
+		t.Fatalf("unable to keep wrapping token: %v", err)
+	}
+	if err := v.RevokeWrap(ctx, "alice", revoked.Accessor); err != nil {
+		t.Errorf("got: %v, expected the token to be used up", err)
+	}
+	if _, err := recipient.Unwrap(ctx, revoked.Token); !errors.Is(err, ErrExpired) {
+		t.Errorf("got: %v, expected a revoked token not to unwrap", err)
+	}
+	if _, err := recipient.Unwrap(ctx, wrapped.Token); !errors.Is(err, ErrExpired) {
+		t.Errorf("got: %v, expected: %v", err, ErrExpired)
+	}
+}
@@ -0,0 +1,539 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dollarshaveclub/psst/pkg/audit"
+	"github.com/dollarshaveclub/psst/pkg/directory"
+	"github.com/dollarshaveclub/psst/pkg/storage"
+)
+
+const (
+	minPaneWidth  = 24
+	maxShareRows  = 5
+	previewRatio  = 3
+	maskCharacter = '*'
+	fileMode      = 0600
+)
+
+type mode int
+
+const (
+	modeBrowse mode = iota
+	modeConfirmDelete
+	modeShare
+	modeCopy
+)
+
+// pane lists the secrets in a single drop
+type pane struct {
+	entity   string
+	secrets  []string
+	selected int
+	err      error
+}
+
+// App is an interactive browser for the current user's drop and the drops of their teams
+type App struct {
+	Storage   storage.Backend
+	Directory directory.Backend
+	Login     string
+	// Audit records gets, deletes and shares in the audit trail when set
+	Audit func(audit.Event) error
+	// HashValue returns the hash of a secret value recorded in the audit trail when set
+	HashValue func(string) string
+
+	// ctx is passed to storage calls and set for the duration of Run
+	ctx    context.Context
+	screen Screen
+	panes  []pane
+	focus  int
+	mode   mode
+	quit   bool
+
+	// message is a one line status shown at the bottom of the screen
+	message string
+
+	// preview holds the value of the selected secret once the user asks to see it
+	preview  *string
+	revealed bool
+
+	// input is used for the share search and the copy filename prompts
+	input    string
+	matches  []string
+	matchIdx int
+}
+
+// NewApp returns an interface for login drawn on screen
+func NewApp(screen Screen, storageClient storage.Backend, dirState directory.Backend, login string) *App {
+	return &App{
+		Storage:   storageClient,
+		Directory: dirState,
+		Login:     login,
+		ctx:       context.Background(),
+		screen:    screen,
+	}
+}
+
+// Run draws the interface and handles key presses until the user quits, the screen runs out
+// of input or ctx is cancelled
+func (a *App) Run(ctx context.Context) error {
+	a.ctx = ctx
+	a.load()
+	for !a.quit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.draw(); err != nil {
+			return err
+		}
+		k, err := a.screen.ReadKey()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		a.handle(k)
+	}
+	return nil
+}
+
+// load lists the secrets for the user's drop and every team drop they belong to
+func (a *App) load() {
+	entities := append([]string{a.Login}, a.Directory.GetActiveMemberTeams()...)
+	a.panes = []pane{}
+	for _, e := range entities {
+		a.panes = append(a.panes, a.loadPane(e))
+	}
+	if a.focus >= len(a.panes) {
+		a.focus = 0
+	}
+}
+
+func (a *App) loadPane(entity string) pane {
+	p := pane{entity: entity}
+	p.secrets, p.err = storage.Walk(a.ctx, a.Storage, entity)
+	return p
+}
+
+func (a *App) reloadFocused() {
+	selected := a.panes[a.focus].selected
+	a.panes[a.focus] = a.loadPane(a.panes[a.focus].entity)
+	if selected >= len(a.panes[a.focus].secrets) {
+		selected = len(a.panes[a.focus].secrets) - 1
+	}
+	if selected < 0 {
+		selected = 0
+	}
+	a.panes[a.focus].selected = selected
+}
+
+// selected returns the entity and secret name under the cursor
+func (a *App) selected() (string, string, bool) {
+	if len(a.panes) == 0 {
+		return "", "", false
+	}
+	p := a.panes[a.focus]
+	if len(p.secrets) == 0 {
+		return p.entity, "", false
+	}
+	return p.entity, p.secrets[p.selected], true
+}
+
+func (a *App) value() (string, error) {
+	if a.preview != nil {
+		return *a.preview, nil
+	}
+	entity, name, ok := a.selected()
+	if !ok {
+		return "", fmt.Errorf("no secret selected")
+	}
+	v, err := a.Storage.Get(a.ctx, a.Storage.SecretPath(entity, name))
+	if err != nil {
+		return "", err
+	}
+	a.preview = &v
+	a.record(audit.Event{Action: audit.Get, Secret: name, Targets: []string{entity}, ValueHash: a.hashValue(v)})
+	return v, nil
+}
+
+// record adds e to the audit trail, showing failures as the status message
+func (a *App) record(e audit.Event) {
+	if a.Audit == nil {
+		return
+	}
+	e.Actor = a.Login
+	if err := a.Audit(e); err != nil {
+		a.message = fmt.Sprintf("unable to record audit event: %v", err)
+	}
+}
+
+// hashValue returns the hash of v to record in the audit trail, if any
+func (a *App) hashValue(v string) string {
+	if a.HashValue == nil {
+		return ""
+	}
+	return a.HashValue(v)
+}
+
+func (a *App) clearPreview() {
+	a.preview = nil
+	a.revealed = false
+}
+
+func (a *App) handle(k Key) {
+	if k.Code == KeyCtrlC {
+		a.quit = true
+		return
+	}
+
+	switch a.mode {
+	case modeConfirmDelete:
+		a.handleConfirmDelete(k)
+	case modeShare:
+		a.handleShare(k)
+	case modeCopy:
+		a.handleCopy(k)
+	default:
+		a.handleBrowse(k)
+	}
+}
+
+func (a *App) handleBrowse(k Key) {
+	a.message = ""
+	if len(a.panes) == 0 {
+		a.quit = k.Code == KeyEsc || k.Rune == 'q'
+		return
+	}
+	p := &a.panes[a.focus]
+
+	switch {
+	case k.Code == KeyUp || k.Rune == 'k':
+		if p.selected > 0 {
+			p.selected--
+			a.clearPreview()
+		}
+	case k.Code == KeyDown || k.Rune == 'j':
+		if p.selected < len(p.secrets)-1 {
+			p.selected++
+			a.clearPreview()
+		}
+	case k.Code == KeyLeft || k.Rune == 'h':
+		if a.focus > 0 {
+			a.focus--
+			a.clearPreview()
+		}
+	case k.Code == KeyRight || k.Code == KeyTab || k.Rune == 'l':
+		a.focus = (a.focus + 1) % len(a.panes)
+		a.clearPreview()
+	case k.Code == KeyEnter || k.Rune == 'p':
+		if _, err := a.value(); err != nil {
+			a.message = fmt.Sprintf("unable to get secret: %v", err)
+		}
+	case k.Rune == 'r':
+		if a.preview != nil {
+			a.revealed = !a.revealed
+		}
+	case k.Code == KeyEsc:
+		a.clearPreview()
+	case k.Rune == 'd':
+		if _, _, ok := a.selected(); ok {
+			a.mode = modeConfirmDelete
+		}
+	case k.Rune == 's':
+		if _, _, ok := a.selected(); ok {
+			a.mode = modeShare
+			a.input = ""
+			a.updateMatches()
+		}
+	case k.Rune == 'c':
+		if _, name, ok := a.selected(); ok {
+			a.mode = modeCopy
+			a.input = name
+		}
+	case k.Rune == 'g':
+		a.load()
+		a.clearPreview()
+	case k.Rune == 'q':
+		a.quit = true
+	}
+}
+
+func (a *App) handleConfirmDelete(k Key) {
+	a.mode = modeBrowse
+	if k.Rune != 'y' && k.Rune != 'Y' {
+		a.message = "delete cancelled"
+		return
+	}
+
+	entity, name, _ := a.selected()
+	if err := a.Storage.Delete(a.ctx, a.Storage.SecretPath(entity, name)); err != nil {
+		a.message = fmt.Sprintf("unable to delete %s: %v", name, err)
+		return
+	}
+	a.clearPreview()
+	a.reloadFocused()
+	a.message = fmt.Sprintf("deleted %s from %s", name, entity)
+	a.record(audit.Event{Action: audit.Delete, Secret: name, Targets: []string{entity}})
+}
+
+func (a *App) handleShare(k Key) {
+	switch k.Code {
+	case KeyEsc:
+		a.mode = modeBrowse
+		a.message = "share cancelled"
+	case KeyUp:
+		if a.matchIdx > 0 {
+			a.matchIdx--
+		}
+	case KeyDown:
+		if a.matchIdx < len(a.matches)-1 && a.matchIdx < maxShareRows-1 {
+			a.matchIdx++
+		}
+	case KeyBackspace:
+		a.input = trimLastRune(a.input)
+		a.updateMatches()
+	case KeyEnter:
+		if len(a.matches) == 0 {
+			return
+		}
+		a.mode = modeBrowse
+		target := a.matches[a.matchIdx]
+		_, name, _ := a.selected()
+		v, err := a.value()
+		if err != nil {
+			a.message = fmt.Sprintf("unable to get secret: %v", err)
+			return
+		}
+		meta := storage.Metadata{Sender: a.Login, Created: time.Now()}
+		if err := a.Storage.WriteSecret(a.ctx, v, name, meta, map[string]struct{}{target: struct{}{}}); err != nil {
+			a.message = fmt.Sprintf("unable to share %s: %v", name, err)
+			return
+		}
+		a.message = fmt.Sprintf("shared %s with %s", name, target)
+		a.record(audit.Event{Action: audit.Share, Time: meta.Created, Secret: name, Targets: []string{target}, ValueHash: a.hashValue(v)})
+	case KeyRune:
+		a.input += string(k.Rune)
+		a.updateMatches()
+	}
+}
+
+// updateMatches searches the directory for members and teams to share with
+func (a *App) updateMatches() {
+	lookup := a.input
+	if lookup == "" {
+		lookup = "*"
+	}
+	m := a.Directory.GetMatches(lookup)
+	a.matches = []string{}
+	for _, mem := range m.Members {
+		a.matches = append(a.matches, mem.Login)
+	}
+	for _, t := range m.Teams {
+		a.matches = append(a.matches, t.Name)
+	}
+	a.matchIdx = 0
+}
+
+func (a *App) handleCopy(k Key) {
+	switch k.Code {
+	case KeyEsc:
+		a.mode = modeBrowse
+		a.message = "copy cancelled"
+	case KeyBackspace:
+		a.input = trimLastRune(a.input)
+	case KeyEnter:
+		a.mode = modeBrowse
+		if a.input == "" {
+			a.message = "copy cancelled"
+			return
+		}
+		v, err := a.value()
+		if err != nil {
+			a.message = fmt.Sprintf("unable to get secret: %v", err)
+			return
+		}
+		if _, err := os.Stat(a.input); err == nil {
+			a.message = fmt.Sprintf("%s already exists, not overwriting", a.input)
+			return
+		}
+		if err := ioutil.WriteFile(a.input, []byte(v), fileMode); err != nil {
+			a.message = fmt.Sprintf("unable to write %s: %v", a.input, err)
+			return
+		}
+		a.message = fmt.Sprintf("copied to %s", a.input)
+	case KeyRune:
+		a.input += string(k.Rune)
+	}
+}
+
+func (a *App) draw() error {
+	w, h := a.screen.Size()
+	bottom := a.bottom(w, h)
+
+	lines := []string{fit(fmt.Sprintf("psst - %s", a.Login), w)}
+	rows := h - len(lines) - len(bottom)
+	lines = append(lines, a.drawPanes(w, rows)...)
+	lines = append(lines, bottom...)
+	return a.screen.Draw(lines)
+}
+
+// drawPanes lays out the panes side by side, scrolling horizontally to keep the focused pane visible
+func (a *App) drawPanes(w, rows int) []string {
+	if rows <= 0 {
+		return []string{}
+	}
+	lines := make([]string, rows)
+	if len(a.panes) == 0 {
+		lines[0] = fit("no drops available", w)
+		return lines
+	}
+
+	visible := w / minPaneWidth
+	if visible < 1 {
+		visible = 1
+	}
+	if visible > len(a.panes) {
+		visible = len(a.panes)
+	}
+	start := 0
+	if a.focus >= visible {
+		start = a.focus - visible + 1
+	}
+	colWidth := w / visible
+
+	for i := start; i < start+visible; i++ {
+		col := a.drawPane(i, colWidth, rows)
+		for r := range lines {
+			lines[r] += col[r]
+		}
+	}
+	return lines
+}
+
+func (a *App) drawPane(i, width, rows int) []string {
+	p := a.panes[i]
+	col := make([]string, rows)
+
+	header := fmt.Sprintf(" %s (%d)", p.entity, len(p.secrets))
+	if i == a.focus {
+		header = fmt.Sprintf("[%s ]", header)
+	}
+	col[0] = fit(header, width)
+	if rows > 1 {
+		col[1] = fit(" "+strings.Repeat("-", width), width-1) + " "
+	}
+
+	body := rows - 2
+	offset := 0
+	if p.selected >= body && body > 0 {
+		offset = p.selected - body + 1
+	}
+	for r := 2; r < rows; r++ {
+		idx := offset + r - 2
+		switch {
+		case p.err != nil && r == 2:
+			col[r] = fit(fmt.Sprintf("   error: %v", p.err), width)
+		case idx < len(p.secrets):
+			cursor := "   "
+			if i == a.focus && idx == p.selected {
+				cursor = " > "
+			}
+			col[r] = fit(cursor+p.secrets[idx], width)
+		default:
+			col[r] = fit("", width)
+		}
+	}
+	return col
+}
+
+// bottom returns the lines drawn under the panes for the current mode
+func (a *App) bottom(w, h int) []string {
+	lines := []string{fit(strings.Repeat("=", w), w)}
+	_, name, _ := a.selected()
+
+	switch a.mode {
+	case modeConfirmDelete:
+		entity, _, _ := a.selected()
+		lines = append(lines, fit(fmt.Sprintf("Delete %s from %s? (y/n)", name, entity), w))
+	case modeShare:
+		lines = append(lines, fit(fmt.Sprintf("Share %s with: %s_", name, a.input), w))
+		for i, m := range a.matches {
+			if i >= maxShareRows {
+				break
+			}
+			cursor := "   "
+			if i == a.matchIdx {
+				cursor = " > "
+			}
+			lines = append(lines, fit(cursor+m, w))
+		}
+		lines = append(lines, fit("type to search  up/down select  enter share  esc cancel", w))
+	case modeCopy:
+		lines = append(lines, fit(fmt.Sprintf("Save %s to file: %s_", name, a.input), w))
+		lines = append(lines, fit("enter save  esc cancel", w))
+	default:
+		if a.preview != nil {
+			lines = append(lines, a.drawPreview(w, h/previewRatio)...)
+		}
+		if a.message != "" {
+			lines = append(lines, fit(a.message, w))
+		}
+		help := "j/k select  h/l drop  enter preview  d delete  s share  c copy  g reload  q quit"
+		if a.preview != nil {
+			help = "r reveal/hide  esc close  d delete  s share  c copy  q quit"
+		}
+		lines = append(lines, fit(help, w))
+	}
+	return lines
+}
+
+func (a *App) drawPreview(w, rows int) []string {
+	v := *a.preview
+	if !a.revealed {
+		v = mask(v)
+	}
+	lines := []string{}
+	for _, l := range strings.Split(strings.TrimRight(v, "\n"), "\n") {
+		if len(lines) >= rows {
+			lines = append(lines, fit("  ...", w))
+			break
+		}
+		lines = append(lines, fit("  "+l, w))
+	}
+	return lines
+}
+
+// mask hides every character of a secret while keeping its shape
+func mask(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' {
+			return r
+		}
+		return maskCharacter
+	}, s)
+}
+
+// fit pads or truncates s to exactly w runes
+func fit(s string, w int) string {
+	r := []rune(s)
+	if len(r) > w {
+		return string(r[:w])
+	}
+	return s + strings.Repeat(" ", w-len(r))
+}
+
+func trimLastRune(s string) string {
+	r := []rune(s)
+	if len(r) == 0 {
+		return s
+	}
+	return string(r[:len(r)-1])
+}
@@ -0,0 +1,288 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dollarshaveclub/psst/pkg/audit"
+	"github.com/dollarshaveclub/psst/pkg/directory"
+	"github.com/dollarshaveclub/psst/pkg/storage"
+)
+
+// memStorage is an in-memory storage backend keyed by secret path
+type memStorage struct {
+	secrets map[string]string
+}
+
+func (m *memStorage) Delete(ctx context.Context, p string) error {
+	if _, ok := m.secrets[p]; !ok {
+		return errors.New("no secret found")
+	}
+	delete(m.secrets, p)
+	return nil
+}
+
+func (m *memStorage) Get(ctx context.Context, p string) (string, error) {
+	v, ok := m.secrets[p]
+	if !ok {
+		return "", errors.New("no secret found")
+	}
+	return v, nil
+}
+
+func (m *memStorage) List(ctx context.Context, entity string) ([]string, error) {
+	names := []string{}
+	for p := range m.secrets {
+		if path.Dir(p) == entity {
+			names = append(names, path.Base(p))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *memStorage) GeneratePoliciesAndRoles(storage.PolicySet, string, string) error {
+	return nil
+}
+
+func (m *memStorage) PlanPolicies(context.Context, storage.PolicySet, string) ([]storage.Change, error) {
+	return []storage.Change{}, nil
+}
+
+func (m *memStorage) PlanPrune(context.Context, storage.PolicySet, string) ([]storage.Change, error) {
+	return []storage.Change{}, nil
+}
+
+func (m *memStorage) ReadPolicies(context.Context, string) (storage.PolicySet, error) {
+	return storage.PolicySet{}, nil
+}
+
+func (m *memStorage) ApplyPolicies(context.Context, []storage.Change, string) error {
+	return nil
+}
+
+func (m *memStorage) DropCapabilities(context.Context, string) ([]string, error) {
+	return []string{"root"}, nil
+}
+
+func (m *memStorage) SecretPath(entity, name string) string {
+	return path.Join(entity, name)
+}
+
+func (m *memStorage) WriteSecret(ctx context.Context, secret, name string, meta storage.Metadata, targets map[string]struct{}) error {
+	for t := range targets {
+		m.secrets[path.Join(t, name)] = secret
+	}
+	return nil
+}
+
+func (m *memStorage) ArchiveSecret(context.Context, string, *storage.Secret) error {
+	return errors.New("not supported")
+}
+
+func (m *memStorage) GetSecret(ctx context.Context, p string) (*storage.Secret, error) {
+	v, err := m.Get(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.Secret{Value: v}, nil
+}
+
+func (m *memStorage) GetReceipt(ctx context.Context, sender, target, name string) (*storage.Receipt, error) {
+	return nil, nil
+}
+
+func (m *memStorage) WriteReceipt(ctx context.Context, sender, target, name string, receipt storage.Receipt) error {
+	return nil
+}
+
+func (m *memStorage) Revoke(ctx context.Context, name string, meta storage.Metadata, targets map[string]struct{}) error {
+	for t := range targets {
+		delete(m.secrets, path.Join(t, name))
+	}
+	return nil
+}
+
+// memDirectory is a directory backend with a fixed set of members and teams
+type memDirectory struct {
+	directory.Info
+	login string
+}
+
+func (d *memDirectory) GetMatches(lookup string) directory.Matches {
+	return directory.RankMatches(lookup, d.Members, d.Teams)
+}
+func (d *memDirectory) GetMembers() []directory.Member         { return d.Members }
+func (d *memDirectory) GetTeams() []directory.Team             { return d.Teams }
+func (d *memDirectory) GetTeamMembers(string) []string         { return []string{} }
+func (d *memDirectory) GetActiveMemberTeams() []string         { return d.ActiveMemberTeams }
+func (d *memDirectory) IsMember(lookup string) (string, bool)  { return lookup, true }
+func (d *memDirectory) IsTeam(lookup string) (string, bool)    { return lookup, true }
+func (d *memDirectory) Whoami(context.Context) (string, error) { return d.login, nil }
+
+func newTestApp(keys ...Key) (*App, *SimScreen, *memStorage) {
+	store := &memStorage{secrets: map[string]string{
+		"test1/db-password": "hunter2",
+		"test1/api-key":     "abc123",
+		"team1/deploy-key":  "ssh-key",
+	}}
+	dir := &memDirectory{login: "test1"}
+	dir.ActiveMemberTeams = []string{"team1"}
+	dir.Members = []directory.Member{
+		directory.Member{Login: "test1", Name: "Test One"},
+		directory.Member{Login: "jsmith", Name: "John Smith"},
+	}
+	dir.Teams = []directory.Team{directory.Team{Name: "team1"}}
+
+	screen := NewSimScreen(80, 20, keys...)
+	return NewApp(screen, store, dir, "test1"), screen, store
+}
+
+func keys(groups ...[]Key) []Key {
+	all := []Key{}
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
+}
+
+func TestPanes(t *testing.T) {
+	app, screen, _ := newTestApp()
+	if err := app.Run(context.Background()); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+
+	text := screen.Text()
+	for _, expected := range []string{"test1 (2)", "team1 (1)", "> api-key", "db-password", "deploy-key"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected %q on screen, got:\n%s", expected, text)
+		}
+	}
+}
+
+func TestPreviewMasking(t *testing.T) {
+	app, screen, _ := newTestApp(Key{Code: KeyDown}, Key{Code: KeyEnter})
+	if err := app.Run(context.Background()); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	if text := screen.Text(); strings.Contains(text, "hunter2") || !strings.Contains(text, "*******") {
+		t.Errorf("expected masked preview, got:\n%s", text)
+	}
+
+	app, screen, _ = newTestApp(Key{Code: KeyDown}, Key{Code: KeyEnter}, Runes("r")[0])
+	if err := app.Run(context.Background()); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	if text := screen.Text(); !strings.Contains(text, "hunter2") {
+		t.Errorf("expected revealed preview, got:\n%s", text)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := map[string]struct {
+		Keys     []Key
+		Expected bool
+	}{
+		"ConfirmTest": {
+			Keys:     Runes("dy"),
+			Expected: false,
+		},
+		"CancelTest": {
+			Keys:     Runes("dn"),
+			Expected: true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			app, screen, store := newTestApp(c.Keys...)
+			if err := app.Run(context.Background()); err != nil {
+				t.Fatalf("run error: %v", err)
+			}
+			_, got := store.secrets["test1/api-key"]
+			if got != c.Expected {
+				t.Errorf("Name: %s, secret exists: %v, expected: %v\n%s", name, got, c.Expected, screen.Text())
+			}
+		})
+	}
+}
+
+func TestShare(t *testing.T) {
+	app, screen, store := newTestApp(keys(Runes("s"), Runes("jsmi"), []Key{Key{Code: KeyEnter}})...)
+	if err := app.Run(context.Background()); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	if store.secrets["jsmith/api-key"] != "abc123" {
+		t.Errorf("expected secret to be shared with jsmith, got: %+v", store.secrets)
+	}
+	if !strings.Contains(screen.Text(), "shared api-key with jsmith") {
+		t.Errorf("expected share message, got:\n%s", screen.Text())
+	}
+}
+
+func TestAudit(t *testing.T) {
+	app, _, _ := newTestApp(keys(Runes("s"), Runes("jsmi"), []Key{Key{Code: KeyEnter}}, Runes("dy"))...)
+	recorded := []string{}
+	app.Audit = func(e audit.Event) error {
+		if e.Actor != "test1" || e.Secret != "api-key" {
+			t.Errorf("got: %+v, expected test1 acting on api-key", e)
+		}
+		recorded = append(recorded, fmt.Sprintf("%s %v %s", e.Action, e.Targets, e.ValueHash))
+		return nil
+	}
+	app.HashValue = func(v string) string { return "hash" }
+	if err := app.Run(context.Background()); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+
+	expected := []string{"get [test1] hash", "share [jsmith] hash", "delete [test1] "}
+	if !reflect.DeepEqual(recorded, expected) {
+		t.Errorf("got: %v, expected: %v", recorded, expected)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-tui-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "secret")
+
+	// Clear the default filename before typing the new one
+	input := []Key{Key{Code: KeyRune, Rune: 'c'}}
+	for range "api-key" {
+		input = append(input, Key{Code: KeyBackspace})
+	}
+	input = append(input, Runes(filename)...)
+	input = append(input, Key{Code: KeyEnter})
+
+	app, _, _ := newTestApp(input...)
+	if err := app.Run(context.Background()); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("unable to read copied secret: %v", err)
+	}
+	if string(buf) != "abc123" {
+		t.Errorf("got: %s, expected: abc123", buf)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("unable to stat copied secret: %v", err)
+	}
+	if info.Mode().Perm() != fileMode {
+		t.Errorf("got mode: %v, expected: %v", info.Mode().Perm(), os.FileMode(fileMode))
+	}
+}
@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// KeyCode identifies special keys that do not map to a printable rune
+type KeyCode int
+
+// Keys understood by the interface
+const (
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyEnter
+	KeyEsc
+	KeyBackspace
+	KeyTab
+	KeyCtrlC
+)
+
+// Key is a single key press read from a screen
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// Screen is the surface the interface is drawn on and the source of key presses
+type Screen interface {
+	Size() (int, int)
+	Draw([]string) error
+	ReadKey() (Key, error)
+}
+
+// Runes converts a string into a set of key presses, one for each rune
+func Runes(s string) []Key {
+	keys := []Key{}
+	for _, r := range s {
+		keys = append(keys, Key{Code: KeyRune, Rune: r})
+	}
+	return keys
+}
+
+// SimScreen is a simulated terminal used for testing. Key presses are replayed in order and every
+// frame drawn is kept so tests can inspect what the user would have seen.
+type SimScreen struct {
+	Width  int
+	Height int
+
+	mu     sync.Mutex
+	keys   []Key
+	frames [][]string
+}
+
+// NewSimScreen returns a simulated terminal of the given size that will replay keys
+func NewSimScreen(width, height int, keys ...Key) *SimScreen {
+	return &SimScreen{Width: width, Height: height, keys: keys}
+}
+
+// Size returns the dimensions of the simulated terminal
+func (s *SimScreen) Size() (int, int) {
+	return s.Width, s.Height
+}
+
+// Draw records a frame
+func (s *SimScreen) Draw(lines []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frame := make([]string, len(lines))
+	copy(frame, lines)
+	s.frames = append(s.frames, frame)
+	return nil
+}
+
+// ReadKey returns the next queued key press or io.EOF once every key has been replayed
+func (s *SimScreen) ReadKey() (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.keys) == 0 {
+		return Key{}, io.EOF
+	}
+	k := s.keys[0]
+	s.keys = s.keys[1:]
+	return k, nil
+}
+
+// Frames returns every frame drawn so far
+func (s *SimScreen) Frames() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.frames
+}
+
+// Text returns the last frame drawn as a single string
+func (s *SimScreen) Text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.frames) == 0 {
+		return ""
+	}
+	return strings.Join(s.frames[len(s.frames)-1], "\n")
+}
@@ -0,0 +1,127 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	escAltScreenOn  = "\x1b[?1049h"
+	escAltScreenOff = "\x1b[?1049l"
+	escCursorHide   = "\x1b[?25l"
+	escCursorShow   = "\x1b[?25h"
+	escHome         = "\x1b[H"
+	escClearLine    = "\x1b[K"
+
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// Terminal is a Screen backed by the user's terminal
+type Terminal struct {
+	in    *os.File
+	out   *bufio.Writer
+	state *terminal.State
+}
+
+// NewTerminal puts the terminal into raw mode and switches to the alternate screen. Close must be
+// called to restore the terminal.
+func NewTerminal() (*Terminal, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, errors.New("psst ui must be run in a terminal")
+	}
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to put terminal into raw mode")
+	}
+
+	t := &Terminal{in: os.Stdin, out: bufio.NewWriter(os.Stdout), state: state}
+	t.out.WriteString(escAltScreenOn + escCursorHide)
+	return t, t.out.Flush()
+}
+
+// Close restores the terminal to the state it was in before the interface started
+func (t *Terminal) Close() error {
+	t.out.WriteString(escCursorShow + escAltScreenOff)
+	if err := t.out.Flush(); err != nil {
+		return err
+	}
+	return terminal.Restore(int(t.in.Fd()), t.state)
+}
+
+// Size returns the current size of the terminal
+func (t *Terminal) Size() (int, int) {
+	w, h, err := terminal.GetSize(int(os.Stdout.Fd()))
+	if err != nil || w <= 0 || h <= 0 {
+		return defaultWidth, defaultHeight
+	}
+	return w, h
+}
+
+// Draw redraws the whole terminal with the provided lines
+func (t *Terminal) Draw(lines []string) error {
+	t.out.WriteString(escHome)
+	for i, l := range lines {
+		t.out.WriteString(l + escClearLine)
+		if i < len(lines)-1 {
+			t.out.WriteString("\r\n")
+		}
+	}
+	return t.out.Flush()
+}
+
+// ReadKey blocks until a key is pressed
+func (t *Terminal) ReadKey() (Key, error) {
+	buf := make([]byte, 16)
+	n, err := t.in.Read(buf)
+	if err != nil {
+		return Key{}, err
+	}
+	return parseKey(buf[:n]), nil
+}
+
+func parseKey(b []byte) Key {
+	if len(b) >= 3 && b[0] == 0x1b && (b[1] == '[' || b[1] == 'O') {
+		switch b[2] {
+		case 'A':
+			return Key{Code: KeyUp}
+		case 'B':
+			return Key{Code: KeyDown}
+		case 'C':
+			return Key{Code: KeyRight}
+		case 'D':
+			return Key{Code: KeyLeft}
+		}
+	}
+
+	switch b[0] {
+	case 0x1b:
+		return Key{Code: KeyEsc}
+	case '\r', '\n':
+		return Key{Code: KeyEnter}
+	case 0x7f, 0x08:
+		return Key{Code: KeyBackspace}
+	case '\t':
+		return Key{Code: KeyTab}
+	case 0x03:
+		return Key{Code: KeyCtrlC}
+	}
+
+	r := []rune(string(b))
+	if len(r) == 0 {
+		return Key{Code: KeyEsc}
+	}
+	return Key{Code: KeyRune, Rune: r[0]}
+}
+
+func (k Key) String() string {
+	if k.Code == KeyRune {
+		return string(k.Rune)
+	}
+	return fmt.Sprintf("key(%d)", k.Code)
+}
@@ -0,0 +1,113 @@
+// Package watch tracks which secrets have been seen in each drop so new arrivals can be reported
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	filePerms   = 0600
+	dirPerms    = 0700
+	hookTimeout = 30 * time.Second
+)
+
+// State maps each drop to the secret names last seen in it
+type State map[string][]string
+
+// Arrival is a secret that showed up in a drop since the last poll
+type Arrival struct {
+	Entity string `json:"entity"`
+	Name   string `json:"name"`
+}
+
+// LoadState reads the state saved at path. A missing file returns a nil State, meaning nothing has
+// been seen yet.
+func LoadState(path string) (State, error) {
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read watch state")
+	}
+	s := State{}
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal watch state")
+	}
+	return s, nil
+}
+
+// Save writes the state to path, readable only by the current user
+func (s State) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), dirPerms); err != nil {
+		return errors.Wrap(err, "unable to create watch state directory")
+	}
+	buf, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal watch state")
+	}
+	if err := ioutil.WriteFile(path, buf, filePerms); err != nil {
+		return errors.Wrap(err, "unable to write watch state")
+	}
+	return nil
+}
+
+// Arrivals returns the secrets in current that weren't in previous, sorted by drop and name. Drops
+// previous has never seen, such as a team the user just joined, are taken as a baseline so existing
+// secrets aren't reported as new.
+func Arrivals(previous, current State) []Arrival {
+	arrivals := []Arrival{}
+	for entity, names := range current {
+		seen, ok := previous[entity]
+		if !ok {
+			continue
+		}
+		old := make(map[string]struct{})
+		for _, n := range seen {
+			old[n] = struct{}{}
+		}
+		for _, n := range names {
+			if _, ok := old[n]; !ok {
+				arrivals = append(arrivals, Arrival{Entity: entity, Name: n})
+			}
+		}
+	}
+
+	sort.Slice(arrivals, func(i, j int) bool {
+		if arrivals[i].Entity != arrivals[j].Entity {
+			return arrivals[i].Entity < arrivals[j].Entity
+		}
+		return arrivals[i].Name < arrivals[j].Name
+	})
+	return arrivals
+}
+
+// RunHook runs a shell command for an arrival with PSST_ENTITY and PSST_SECRET set and the arrival as
+// JSON on stdin
+func RunHook(command string, a Arrival) error {
+	buf, err := json.Marshal(a)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal arrival")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(buf)
+	cmd.Env = append(os.Environ(), "PSST_ENTITY="+a.Entity, "PSST_SECRET="+a.Name)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("hook failed: %s", out))
+	}
+	return nil
+}
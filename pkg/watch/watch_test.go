@@ -0,0 +1,111 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArrivals(t *testing.T) {
+	cases := map[string]struct {
+		Previous State
+		Current  State
+		Expected []Arrival
+	}{
+		"FirstRunTest": {
+			Previous: nil,
+			Current:  State{"alice": {"one"}},
+			Expected: []Arrival{},
+		},
+		"NothingNewTest": {
+			Previous: State{"alice": {"one"}},
+			Current:  State{"alice": {"one"}},
+			Expected: []Arrival{},
+		},
+		"NewSecretTest": {
+			Previous: State{"alice": {"one"}, "sre": {}},
+			Current:  State{"alice": {"one", "two"}, "sre": {"db"}},
+			Expected: []Arrival{{Entity: "alice", Name: "two"}, {Entity: "sre", Name: "db"}},
+		},
+		"DeletedSecretTest": {
+			Previous: State{"alice": {"one", "two"}},
+			Current:  State{"alice": {"two"}},
+			Expected: []Arrival{},
+		},
+		"NewTeamTest": {
+			Previous: State{"alice": {}},
+			Current:  State{"alice": {}, "sre": {"db"}},
+			Expected: []Arrival{},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Arrivals(c.Previous, c.Current)
+			if !reflect.DeepEqual(got, c.Expected) {
+				t.Errorf("Name: %s, got: %v, expected: %v", name, got, c.Expected)
+			}
+		})
+	}
+}
+
+func TestState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-watch-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "psst", "watch.json")
+
+	s, err := LoadState(path)
+	if err != nil || s != nil {
+		t.Fatalf("got: %v, %v, expected no state", s, err)
+	}
+
+	expected := State{"alice": {"one"}, "sre": {}}
+	if err := expected.Save(path); err != nil {
+		t.Fatalf("unable to save state: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unable to stat state: %v", err)
+	}
+	if info.Mode().Perm() != filePerms {
+		t.Errorf("got mode: %v, expected: %v", info.Mode().Perm(), os.FileMode(filePerms))
+	}
+
+	s, err = LoadState(path)
+	if err != nil {
+		t.Fatalf("unable to load state: %v", err)
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("got: %v, expected: %v", s, expected)
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psst-watch-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	if err := RunHook(`echo "$PSST_ENTITY $PSST_SECRET" > `+out, Arrival{Entity: "sre", Name: "db"}); err != nil {
+		t.Fatalf("unable to run hook: %v", err)
+	}
+	buf, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("unable to read hook output: %v", err)
+	}
+	if strings.TrimSpace(string(buf)) != "sre db" {
+		t.Errorf("got: %q, expected: %q", buf, "sre db")
+	}
+
+	if err := RunHook("exit 1", Arrival{}); err == nil {
+		t.Errorf("expected an error from a failing hook")
+	}
+}
@@ -8,6 +8,7 @@ Create:
 - homebrew bottle
 - linux tarball
 - GitHub release with asset link(s)
+- SHA256SUMS (and SHA256SUMS.sig if RELEASE_SIGNING_KEY is set) for psst upgrade
 
 Update:
 - Homebrew formula tap with new release & SHAs
@@ -19,6 +20,7 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -33,6 +35,7 @@ import (
 	"github.com/mholt/archiver"
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
+	"golang.org/x/crypto/ed25519"
 	"golang.org/x/oauth2"
 )
 
@@ -234,7 +237,7 @@ const (
 	linuxBinName = "psst-linux-amd64"
 )
 
-var buildopts = []string{"-ldflags", "-X github.com/dollarshaveclub/psst/cmd.CommitSHA=%v -X github.com/dollarshaveclub/psst/cmd.Version=%v -X github.com/dollarshaveclub/psst/cmd.CompiledDirectory=github -X github.com/dollarshaveclub/psst/cmd.CompiledStorage=vault -X github.com/dollarshaveclub/psst/cmd.Org=dollarshaveclub"}
+var buildopts = []string{"-ldflags", "-X github.com/dollarshaveclub/psst/internal/version.CommitSHA=%v -X github.com/dollarshaveclub/psst/internal/version.Version=%v -X github.com/dollarshaveclub/psst/cmd.CompiledDirectory=github -X github.com/dollarshaveclub/psst/cmd.CompiledStorage=vault -X github.com/dollarshaveclub/psst/cmd.Org=dollarshaveclub"}
 
 func buildBins() error {
 	if err := os.MkdirAll("bins", os.ModeDir|0755); err != nil {
@@ -246,6 +249,15 @@ func buildBins() error {
 	}
 	wd := filepath.Join(cwd, "..")
 	buildopts[1] = fmt.Sprintf(buildopts[1], commitsha, rname)
+	// releases built with a public key refuse to upgrade to releases that aren't signed, and signed
+	// releases are useless to binaries without the key, so the key follows the signing key
+	pk, err := publicKey()
+	if err != nil {
+		return err
+	}
+	if pk != "" {
+		buildopts[1] += " -X github.com/dollarshaveclub/psst/internal/update.PublicKey=" + pk
+	}
 	build := func(osn string) ([]byte, error) {
 		cmd := exec.Command("go", append([]string{"build"}, buildopts...)...)
 		cmd.Env = append(os.Environ(), []string{fmt.Sprintf("GOOS=%v", osn), "GOARCH=amd64"}...)
@@ -397,6 +409,45 @@ func createBottle() ([]bottleDefinition, []string, error) {
 	return bdefs, lps, nil
 }
 
+const (
+	checksumsName = "SHA256SUMS"
+	signatureName = checksumsName + ".sig"
+)
+
+// createChecksums writes a sha256sum compatible list of the assets, signing it with the base64
+// ed25519 private key in RELEASE_SIGNING_KEY if set. Returns the paths of the files to upload.
+func createChecksums(assetpaths []string) ([]string, error) {
+	logger.Printf("Creating checksums")
+	buf := bytes.NewBuffer([]byte{})
+	for _, ap := range assetpaths {
+		d, err := ioutil.ReadFile(ap)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading asset")
+		}
+		fmt.Fprintf(buf, "%x  %v\n", sha256.Sum256(d), filepath.Base(ap))
+	}
+	sp := filepath.Join("bins", checksumsName)
+	if err := ioutil.WriteFile(sp, buf.Bytes(), 0644); err != nil {
+		return nil, errors.Wrap(err, "error writing checksums")
+	}
+	paths := []string{sp}
+
+	key, err := signingKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		logger.Printf("RELEASE_SIGNING_KEY not set, checksums will not be signed")
+		return paths, nil
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, buf.Bytes()))
+	sigp := filepath.Join("bins", signatureName)
+	if err := ioutil.WriteFile(sigp, []byte(sig+"\n"), 0644); err != nil {
+		return nil, errors.Wrap(err, "error writing signature")
+	}
+	return append(paths, sigp), nil
+}
+
 func createGHRelease(assetpaths []string) error {
 	rel := github.RepositoryRelease{
 		TagName: &rname,
@@ -469,6 +520,11 @@ func main() {
 		ferr("error getting working directory: %v", err)
 	}
 	assetpaths := append([]string{filepath.Join(cwd, "bins", linuxBinName+".gz")}, lps...)
+	sps, err := createChecksums(assetpaths)
+	if err != nil {
+		ferr("error creating checksums: %v", err)
+	}
+	assetpaths = append(assetpaths, sps...)
 	if err = createGHRelease(assetpaths); err != nil {
 		ferr("error creating GitHub release: %v", err)
 	}
@@ -477,3 +533,41 @@ func main() {
 	}
 	logger.Printf("Done")
 }
+
+// signingKey returns the base64 ed25519 private key or seed in RELEASE_SIGNING_KEY, or nil if it
+// isn't set
+func signingKey() (ed25519.PrivateKey, error) {
+	sk := os.Getenv("RELEASE_SIGNING_KEY")
+	if sk == "" {
+		return nil, nil
+	}
+	kd, err := base64.StdEncoding.DecodeString(sk)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding signing key")
+	}
+	switch len(kd) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(kd), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(kd), nil
+	}
+	return nil, fmt.Errorf("signing key has unexpected length: %v", len(kd))
+}
+
+// publicKey returns the base64 public key built into the binaries: RELEASE_PUBLIC_KEY, or the public
+// half of RELEASE_SIGNING_KEY. Both must match when both are set.
+func publicKey() (string, error) {
+	key, err := signingKey()
+	if err != nil {
+		return "", err
+	}
+	pk := os.Getenv("RELEASE_PUBLIC_KEY")
+	if key == nil {
+		return pk, nil
+	}
+	derived := base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	if pk != "" && pk != derived {
+		return "", fmt.Errorf("RELEASE_PUBLIC_KEY doesn't match RELEASE_SIGNING_KEY")
+	}
+	return derived, nil
+}